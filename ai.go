@@ -0,0 +1,704 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChatMessage 对话消息
+type ChatMessage struct {
+	Role    string `json:"role"` // system, user, assistant
+	Content string `json:"content"`
+}
+
+// CompletionRequest 一次补全请求，模型参数由服务商配置决定
+type CompletionRequest struct {
+	Messages []ChatMessage
+}
+
+// Provider 大模型服务商
+type Provider interface {
+	// Name 服务商名称，用于日志和错误信息
+	Name() string
+	// Complete 返回模型的完整回复
+	Complete(ctx context.Context, req *CompletionRequest) (string, error)
+}
+
+// StreamingProvider 支持流式输出的服务商
+type StreamingProvider interface {
+	Provider
+	// Stream 每收到一段增量内容就调用 onDelta，返回完整回复；onDelta 返回错误时中止
+	Stream(ctx context.Context, req *CompletionRequest, onDelta func(delta string) error) (string, error)
+}
+
+// AISettings AI优化相关配置
+type AISettings struct {
+	Provider        string                     `json:"provider"`         // 默认使用的服务商名称
+	Providers       map[string]*ProviderConfig `json:"providers"`        // 服务商配置，名称为内置类型时可省略 type
+	DeadlineSeconds int                        `json:"deadline_seconds"` // 一次优化（含流式输出）的总时限
+}
+
+// ProviderConfig 单个服务商的配置，未填写的字段使用该类型的默认值
+type ProviderConfig struct {
+	Type           string   `json:"type"`            // openai, zhipu, deepseek, ollama, fake
+	BaseURL        string   `json:"base_url"`        // 接口地址，如 https://api.deepseek.com/v1
+	APIKey         string   `json:"api_key"`         // API密钥，建议使用 api_key_env
+	APIKeyEnv      string   `json:"api_key_env"`     // 读取API密钥的环境变量
+	Model          string   `json:"model"`           // 模型名称
+	Temperature    *float64 `json:"temperature"`     // 采样温度
+	MaxTokens      int      `json:"max_tokens"`      // 回复的最大token数
+	ContextTokens  int      `json:"context_tokens"`  // 模型上下文窗口，输入和回复共用
+	ChunkTokens    int      `json:"chunk_tokens"`    // 报告超出上下文窗口时每段的最大token数，0 表示按窗口自动计算
+	TimeoutSeconds int      `json:"timeout_seconds"` // 单次请求超时时间；流式请求为等待响应头的超时时间
+}
+
+// TokenBudget 单次请求的token预算
+type TokenBudget struct {
+	ContextTokens int // 上下文窗口
+	MaxTokens     int // 回复上限
+	ChunkTokens   int // 每段内容的上限，0 表示不额外限制
+}
+
+// 内置的服务商类型
+const (
+	ProviderOpenAI   = "openai"
+	ProviderZhipu    = "zhipu"
+	ProviderDeepSeek = "deepseek"
+	ProviderOllama   = "ollama"
+	ProviderFake     = "fake"
+)
+
+// AI相关的环境变量，兼容早期只支持 AI_API_URL 和 AI_API_KEY 的配置方式
+const (
+	EnvAIProvider = "AI_PROVIDER"
+	EnvAIAPIURL   = "AI_API_URL"
+	EnvAIAPIKey   = "AI_API_KEY"
+	EnvAIModel    = "AI_MODEL"
+)
+
+// providerDefaults 各类型服务商的默认配置
+var providerDefaults = map[string]ProviderConfig{
+	ProviderOpenAI:   {BaseURL: "https://api.openai.com/v1", APIKeyEnv: "OPENAI_API_KEY", Model: "gpt-4o-mini", ContextTokens: 128000},
+	ProviderZhipu:    {BaseURL: "https://open.bigmodel.cn/api/paas/v4", APIKeyEnv: "ZHIPU_API_KEY", Model: "glm-4-flash", ContextTokens: 128000},
+	ProviderDeepSeek: {BaseURL: "https://api.deepseek.com/v1", APIKeyEnv: "DEEPSEEK_API_KEY", Model: "deepseek-chat", ContextTokens: 64000},
+	ProviderOllama:   {BaseURL: "http://localhost:11434", Model: "qwen2.5", ContextTokens: 8192},
+	ProviderFake:     {Model: "fake", ContextTokens: 8192},
+}
+
+// ErrAPIKeyMissing 服务商需要API密钥但没有配置
+var ErrAPIKeyMissing = errors.New("AI API key not configured")
+
+// 未配置时的默认模型参数
+const (
+	defaultAITemperature    = 0.7
+	defaultAIMaxTokens      = 2000
+	defaultAITimeoutSeconds = 60
+)
+
+// DefaultAISettings 返回默认AI配置，使用 DeepSeek
+func DefaultAISettings() AISettings {
+	return AISettings{Provider: ProviderDeepSeek, DeadlineSeconds: 300}
+}
+
+// Deadline 返回一次优化的总时限
+func (s *AISettings) Deadline() time.Duration {
+	if s.DeadlineSeconds <= 0 {
+		return 300 * time.Second
+	}
+	return time.Duration(s.DeadlineSeconds) * time.Second
+}
+
+// applyEnv 应用AI相关环境变量
+// 只设置了 AI_API_URL 时根据地址推断服务商，避免出现 DeepSeek 地址配智谱模型的情况
+func (s *AISettings) applyEnv() {
+	url := os.Getenv(EnvAIAPIURL)
+	if v := os.Getenv(EnvAIProvider); v != "" {
+		s.Provider = v
+	} else if url != "" {
+		s.Provider = inferProviderType(url)
+	}
+
+	if url == "" && os.Getenv(EnvAIAPIKey) == "" && os.Getenv(EnvAIModel) == "" {
+		return
+	}
+
+	if s.Providers == nil {
+		s.Providers = make(map[string]*ProviderConfig)
+	}
+	cfg, ok := s.Providers[s.Provider]
+	if !ok {
+		cfg = &ProviderConfig{}
+		s.Providers[s.Provider] = cfg
+	}
+
+	if url != "" {
+		// 早期配置填写的是完整的 chat/completions 地址
+		cfg.BaseURL = strings.TrimSuffix(strings.TrimSuffix(url, "/"), "/chat/completions")
+	}
+	if v := os.Getenv(EnvAIAPIKey); v != "" {
+		cfg.APIKey = v
+	}
+	if v := os.Getenv(EnvAIModel); v != "" {
+		cfg.Model = v
+	}
+}
+
+// inferProviderType 根据接口地址推断服务商类型
+func inferProviderType(url string) string {
+	switch {
+	case strings.Contains(url, "bigmodel.cn"):
+		return ProviderZhipu
+	case strings.Contains(url, "deepseek.com"):
+		return ProviderDeepSeek
+	case strings.Contains(url, ":11434") || strings.Contains(url, "ollama"):
+		return ProviderOllama
+	default:
+		return ProviderOpenAI
+	}
+}
+
+// validate 校验AI配置，返回发现的问题
+func (s *AISettings) validate() []string {
+	var problems []string
+
+	if s.DeadlineSeconds < 0 {
+		problems = append(problems, "ai.deadline_seconds 不能为负数")
+	}
+	if s.Provider != "" {
+		if _, err := s.resolve(s.Provider); err != nil {
+			problems = append(problems, fmt.Sprintf("ai.provider 无效: %v", err))
+		}
+	}
+
+	names := make([]string, 0, len(s.Providers))
+	for name := range s.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		cfg := s.Providers[name]
+		if _, err := s.resolve(name); err != nil {
+			problems = append(problems, fmt.Sprintf("ai.providers.%s 无效: %v", name, err))
+			continue
+		}
+		if cfg.Temperature != nil && (*cfg.Temperature < 0 || *cfg.Temperature > 2) {
+			problems = append(problems, fmt.Sprintf("ai.providers.%s.temperature 必须在0到2之间", name))
+		}
+		if cfg.MaxTokens < 0 {
+			problems = append(problems, fmt.Sprintf("ai.providers.%s.max_tokens 不能为负数", name))
+		}
+		if cfg.TimeoutSeconds < 0 {
+			problems = append(problems, fmt.Sprintf("ai.providers.%s.timeout_seconds 不能为负数", name))
+		}
+		if cfg.ContextTokens < 0 || cfg.ChunkTokens < 0 {
+			problems = append(problems, fmt.Sprintf("ai.providers.%s 的 context_tokens 和 chunk_tokens 不能为负数", name))
+		} else if budget, _ := s.Budget(name); budget.InputTokens() < minChunkTokens {
+			problems = append(problems, fmt.Sprintf("ai.providers.%s.context_tokens 过小，扣除 max_tokens 后可用的输入不足 %d", name, minChunkTokens))
+		}
+	}
+
+	return problems
+}
+
+// resolve 合并服务商配置与其类型的默认值
+func (s *AISettings) resolve(name string) (*ProviderConfig, error) {
+	cfg := ProviderConfig{}
+	if configured, ok := s.Providers[name]; ok && configured != nil {
+		cfg = *configured
+	}
+	if cfg.Type == "" {
+		cfg.Type = name
+	}
+
+	defaults, ok := providerDefaults[cfg.Type]
+	if !ok {
+		return nil, fmt.Errorf("未知的服务商类型 %s，可选 openai, zhipu, deepseek, ollama, fake", cfg.Type)
+	}
+
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaults.BaseURL
+	}
+	if cfg.APIKeyEnv == "" {
+		cfg.APIKeyEnv = defaults.APIKeyEnv
+	}
+	if cfg.APIKey == "" && cfg.APIKeyEnv != "" {
+		cfg.APIKey = os.Getenv(cfg.APIKeyEnv)
+	}
+	if cfg.Model == "" {
+		cfg.Model = defaults.Model
+	}
+	if cfg.Temperature == nil {
+		temperature := defaultAITemperature
+		cfg.Temperature = &temperature
+	}
+	if cfg.MaxTokens == 0 {
+		cfg.MaxTokens = defaultAIMaxTokens
+	}
+	if cfg.TimeoutSeconds == 0 {
+		cfg.TimeoutSeconds = defaultAITimeoutSeconds
+	}
+	if cfg.ContextTokens == 0 {
+		cfg.ContextTokens = defaults.ContextTokens
+	}
+
+	return &cfg, nil
+}
+
+// providerName 返回实际使用的服务商名称，name 为空时使用默认服务商
+func (s *AISettings) providerName(name string) string {
+	if name == "" {
+		name = s.Provider
+	}
+	if name == "" {
+		name = ProviderDeepSeek
+	}
+	return name
+}
+
+// Budget 返回服务商的token预算，name 为空时使用默认服务商
+func (s *AISettings) Budget(name string) (TokenBudget, error) {
+	cfg, err := s.resolve(s.providerName(name))
+	if err != nil {
+		return TokenBudget{}, err
+	}
+	return TokenBudget{ContextTokens: cfg.ContextTokens, MaxTokens: cfg.MaxTokens, ChunkTokens: cfg.ChunkTokens}, nil
+}
+
+// InputTokens 一次请求中输入可用的token数，预留一成余量抵消估算误差
+func (b TokenBudget) InputTokens() int {
+	return (b.ContextTokens - b.MaxTokens) * 9 / 10
+}
+
+// NewProvider 按名称创建服务商，name 为空时使用默认服务商
+func (s *AISettings) NewProvider(name string) (Provider, error) {
+	name = s.providerName(name)
+
+	cfg, err := s.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Type {
+	case ProviderOllama:
+		return NewOllamaProvider(name, cfg), nil
+	case ProviderFake:
+		return NewFakeProvider(""), nil
+	default:
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("%w for provider %s. Please set %s or AI_API_KEY environment variable", ErrAPIKeyMissing, name, cfg.APIKeyEnv)
+		}
+		return NewOpenAIProvider(name, cfg), nil
+	}
+}
+
+// OpenAIProvider OpenAI 兼容的 Chat Completions 接口，智谱、DeepSeek 等也使用该接口
+type OpenAIProvider struct {
+	name         string
+	config       *ProviderConfig
+	client       *http.Client
+	streamClient *http.Client
+}
+
+// NewOpenAIProvider 创建 OpenAI 兼容的服务商
+func NewOpenAIProvider(name string, config *ProviderConfig) *OpenAIProvider {
+	return &OpenAIProvider{
+		name:         name,
+		config:       config,
+		client:       &http.Client{Timeout: time.Duration(config.TimeoutSeconds) * time.Second},
+		streamClient: streamClientFor(config.TimeoutSeconds),
+	}
+}
+
+// Name 服务商名称
+func (p *OpenAIProvider) Name() string {
+	return p.name
+}
+
+// Complete 调用 /chat/completions 接口
+func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (string, error) {
+	requestBody := map[string]interface{}{
+		"model":       p.config.Model,
+		"messages":    req.Messages,
+		"max_tokens":  p.config.MaxTokens,
+		"temperature": *p.config.Temperature,
+	}
+
+	var response struct {
+		Choices []struct {
+			Message ChatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	headers := map[string]string{"Authorization": "Bearer " + p.config.APIKey}
+	if err := postJSON(ctx, p.client, strings.TrimSuffix(p.config.BaseURL, "/")+"/chat/completions", headers, requestBody, &response); err != nil {
+		return "", err
+	}
+
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("invalid response format: no choices found")
+	}
+
+	return strings.TrimSpace(response.Choices[0].Message.Content), nil
+}
+
+// Stream 以 stream: true 调用 /chat/completions 接口，解析 SSE 中的增量内容
+func (p *OpenAIProvider) Stream(ctx context.Context, req *CompletionRequest, onDelta func(delta string) error) (string, error) {
+	requestBody := map[string]interface{}{
+		"model":       p.config.Model,
+		"messages":    req.Messages,
+		"max_tokens":  p.config.MaxTokens,
+		"temperature": *p.config.Temperature,
+		"stream":      true,
+	}
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + p.config.APIKey,
+		"Accept":        "text/event-stream",
+	}
+	body, err := postStream(ctx, p.streamClient, strings.TrimSuffix(p.config.BaseURL, "/")+"/chat/completions", headers, requestBody)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	var content strings.Builder
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta ChatMessage `json:"delta"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", fmt.Errorf("failed to parse stream chunk: %v", err)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		content.WriteString(delta)
+		if err := onDelta(delta); err != nil {
+			return "", err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read stream: %v", err)
+	}
+
+	return strings.TrimSpace(content.String()), nil
+}
+
+// OllamaProvider 本地 Ollama 服务
+type OllamaProvider struct {
+	name         string
+	config       *ProviderConfig
+	client       *http.Client
+	streamClient *http.Client
+}
+
+// NewOllamaProvider 创建 Ollama 服务商
+func NewOllamaProvider(name string, config *ProviderConfig) *OllamaProvider {
+	return &OllamaProvider{
+		name:         name,
+		config:       config,
+		client:       &http.Client{Timeout: time.Duration(config.TimeoutSeconds) * time.Second},
+		streamClient: streamClientFor(config.TimeoutSeconds),
+	}
+}
+
+// Name 服务商名称
+func (p *OllamaProvider) Name() string {
+	return p.name
+}
+
+// Complete 调用 /api/chat 接口
+func (p *OllamaProvider) Complete(ctx context.Context, req *CompletionRequest) (string, error) {
+	requestBody := map[string]interface{}{
+		"model":    p.config.Model,
+		"messages": req.Messages,
+		"stream":   false,
+		"options": map[string]interface{}{
+			"temperature": *p.config.Temperature,
+			"num_predict": p.config.MaxTokens,
+		},
+	}
+
+	var response struct {
+		Message ChatMessage `json:"message"`
+	}
+	if err := postJSON(ctx, p.client, strings.TrimSuffix(p.config.BaseURL, "/")+"/api/chat", nil, requestBody, &response); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(response.Message.Content), nil
+}
+
+// Stream 以 stream: true 调用 /api/chat 接口，响应为每行一个JSON对象
+func (p *OllamaProvider) Stream(ctx context.Context, req *CompletionRequest, onDelta func(delta string) error) (string, error) {
+	requestBody := map[string]interface{}{
+		"model":    p.config.Model,
+		"messages": req.Messages,
+		"stream":   true,
+		"options": map[string]interface{}{
+			"temperature": *p.config.Temperature,
+			"num_predict": p.config.MaxTokens,
+		},
+	}
+
+	body, err := postStream(ctx, p.streamClient, strings.TrimSuffix(p.config.BaseURL, "/")+"/api/chat", nil, requestBody)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	var content strings.Builder
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var chunk struct {
+			Message ChatMessage `json:"message"`
+			Done    bool        `json:"done"`
+			Error   string      `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return "", fmt.Errorf("failed to parse stream chunk: %v", err)
+		}
+		if chunk.Error != "" {
+			return "", fmt.Errorf("API stream failed: %s", chunk.Error)
+		}
+
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			if err := onDelta(chunk.Message.Content); err != nil {
+				return "", err
+			}
+		}
+		if chunk.Done {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read stream: %v", err)
+	}
+
+	return strings.TrimSpace(content.String()), nil
+}
+
+// FakeProvider 用于测试和本地开发的服务商，不访问网络
+// Response 为空时原样返回最后一条用户消息
+type FakeProvider struct {
+	Response string
+	Err      error
+
+	mu       sync.Mutex
+	requests []*CompletionRequest
+}
+
+// NewFakeProvider 创建返回固定内容的服务商
+func NewFakeProvider(response string) *FakeProvider {
+	return &FakeProvider{Response: response}
+}
+
+// Name 服务商名称
+func (p *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+// Complete 记录请求并返回预设内容
+func (p *FakeProvider) Complete(ctx context.Context, req *CompletionRequest) (string, error) {
+	p.mu.Lock()
+	p.requests = append(p.requests, req)
+	p.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if p.Err != nil {
+		return "", p.Err
+	}
+	if p.Response != "" {
+		return p.Response, nil
+	}
+
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			return req.Messages[i].Content, nil
+		}
+	}
+	return "", nil
+}
+
+// Stream 按空白切分预设内容逐段输出
+func (p *FakeProvider) Stream(ctx context.Context, req *CompletionRequest, onDelta func(delta string) error) (string, error) {
+	content, err := p.Complete(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	for _, delta := range splitKeepSpace(content) {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if err := onDelta(delta); err != nil {
+			return "", err
+		}
+	}
+	return content, nil
+}
+
+// splitKeepSpace 按空白切分字符串，每段保留其后的空白，拼接后与原文相同
+func splitKeepSpace(s string) []string {
+	var parts []string
+	start := 0
+	for i := 1; i < len(s); i++ {
+		if (s[i-1] == ' ' || s[i-1] == '\n') && s[i] != ' ' && s[i] != '\n' {
+			parts = append(parts, s[start:i])
+			start = i
+		}
+	}
+	if start < len(s) {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
+
+// Requests 返回收到的所有请求
+func (p *FakeProvider) Requests() []*CompletionRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*CompletionRequest(nil), p.requests...)
+}
+
+// postJSON 发送JSON请求并解析JSON响应
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body, result interface{}) error {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(data))
+	}
+
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	return nil
+}
+
+// StreamCompletion 流式获取回复，服务商不支持流式输出时一次性输出完整回复
+func StreamCompletion(ctx context.Context, provider Provider, req *CompletionRequest, onDelta func(delta string) error) (string, error) {
+	if streaming, ok := provider.(StreamingProvider); ok {
+		return streaming.Stream(ctx, req, onDelta)
+	}
+
+	content, err := provider.Complete(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if err := onDelta(content); err != nil {
+		return "", err
+	}
+	return content, nil
+}
+
+// streamClients 按等待响应头的超时缓存的流式请求客户端
+// 服务商按请求创建，共用客户端避免每次请求都留下一个空闲连接池
+var (
+	streamClientsMu sync.Mutex
+	streamClients   = make(map[int]*http.Client)
+)
+
+// streamClientFor 返回流式请求使用的客户端，超时相同的服务商共用同一个客户端
+// 流式响应持续时间不定，不设置整体超时，只限制等待响应头的时间，整体时限由 context 控制
+func streamClientFor(timeoutSeconds int) *http.Client {
+	streamClientsMu.Lock()
+	defer streamClientsMu.Unlock()
+
+	if client, ok := streamClients[timeoutSeconds]; ok {
+		return client
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = time.Duration(timeoutSeconds) * time.Second
+	client := &http.Client{Transport: transport}
+	streamClients[timeoutSeconds] = client
+	return client
+}
+
+// postStream 发送JSON请求并返回响应体，调用方负责关闭
+func postStream(ctx context.Context, client *http.Client, url string, headers map[string]string, body interface{}) (io.ReadCloser, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(data))
+	}
+
+	return resp.Body, nil
+}
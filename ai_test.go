@@ -0,0 +1,480 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestProvider 创建指向测试服务器的服务商
+func newTestProvider(t *testing.T, cfg *ProviderConfig, handler http.Handler) Provider {
+	t.Helper()
+	cfg.BaseURL = startTestServer(t, handler) + "/v1/"
+	settings := &AISettings{Providers: map[string]*ProviderConfig{"test": cfg}}
+	provider, err := settings.NewProvider("test")
+	if err != nil {
+		t.Fatalf("NewProvider 返回错误: %v", err)
+	}
+	return provider
+}
+
+var testCompletionRequest = &CompletionRequest{
+	Messages: []ChatMessage{
+		{Role: "system", Content: "你是技术写作助手"},
+		{Role: "user", Content: "优化这份周报"},
+	},
+}
+
+// checkMessages 检查请求体中的 messages 与 testCompletionRequest 一致
+func checkMessages(t *testing.T, body map[string]interface{}) {
+	t.Helper()
+	messages, _ := body["messages"].([]interface{})
+	if len(messages) != 2 {
+		t.Fatalf("messages = %v", body["messages"])
+	}
+	for i, want := range testCompletionRequest.Messages {
+		message, _ := messages[i].(map[string]interface{})
+		if message["role"] != want.Role || message["content"] != want.Content {
+			t.Errorf("messages[%d] = %v, 期望 %+v", i, message, want)
+		}
+	}
+}
+
+func TestOpenAIProviderComplete(t *testing.T) {
+	temperature := 0.2
+	recorder := &requestRecorder{responses: []string{`{"choices":[{"message":{"role":"assistant","content":"  优化后的周报\n"}}]}`}}
+	provider := newTestProvider(t, &ProviderConfig{
+		Type:        ProviderOpenAI,
+		APIKey:      "sk-test",
+		Model:       "gpt-test",
+		Temperature: &temperature,
+		MaxTokens:   512,
+	}, recorder)
+
+	content, err := provider.Complete(context.Background(), testCompletionRequest)
+	if err != nil {
+		t.Fatalf("Complete 返回错误: %v", err)
+	}
+	if content != "优化后的周报" {
+		t.Errorf("content = %q", content)
+	}
+
+	if recorder.paths[0] != "/v1/chat/completions" {
+		t.Errorf("请求路径 = %q", recorder.paths[0])
+	}
+	if got := recorder.headers[0].Get("Authorization"); got != "Bearer sk-test" {
+		t.Errorf("Authorization = %q", got)
+	}
+	body := recorder.bodies[0]
+	if body["model"] != "gpt-test" || body["temperature"] != 0.2 || body["max_tokens"] != float64(512) {
+		t.Errorf("模型参数 = model %v, temperature %v, max_tokens %v", body["model"], body["temperature"], body["max_tokens"])
+	}
+	if _, ok := body["stream"]; ok {
+		t.Error("非流式请求不应带 stream")
+	}
+	checkMessages(t, body)
+}
+
+func TestOpenAIProviderCompleteErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		wantErr string
+	}{
+		{"HTTP 错误", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"error":{"message":"invalid api key"}}`, http.StatusUnauthorized)
+		}, "401"},
+		{"没有 choices", func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `{"choices":[]}`)
+		}, "no choices"},
+		{"响应不是 JSON", func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "<html>bad gateway</html>")
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestProvider(t, &ProviderConfig{Type: ProviderOpenAI, APIKey: "sk-test"}, tt.handler)
+			_, err := provider.Complete(context.Background(), testCompletionRequest)
+			if err == nil {
+				t.Fatal("期望返回错误")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("错误 %q 中缺少 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpenAIProviderStream(t *testing.T) {
+	// 包含注释行、空行、没有内容的增量和 [DONE] 之后的数据
+	stream := ": keep-alive\n\n" +
+		`data: {"choices":[{"delta":{"role":"assistant"}}]}` + "\n\n" +
+		`data: {"choices":[{"delta":{"content":"本周"}}]}` + "\n\n" +
+		`data:{"choices":[{"delta":{"content":"完成了"}}]}` + "\n\n" +
+		`data: {"choices":[]}` + "\n\n" +
+		`data: {"choices":[{"delta":{"content":" [[NAME_1]] 的需求\n"}}]}` + "\n\n" +
+		"data: [DONE]\n\n" +
+		`data: {"choices":[{"delta":{"content":"不应输出"}}]}` + "\n\n"
+	recorder := &requestRecorder{responses: []string{stream}}
+	provider := newTestProvider(t, &ProviderConfig{Type: ProviderDeepSeek, APIKey: "sk-test"}, recorder)
+
+	var deltas []string
+	content, err := StreamCompletion(context.Background(), provider, testCompletionRequest, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamCompletion 返回错误: %v", err)
+	}
+
+	wantDeltas := []string{"本周", "完成了", " [[NAME_1]] 的需求\n"}
+	if strings.Join(deltas, "|") != strings.Join(wantDeltas, "|") {
+		t.Errorf("deltas = %q, 期望 %q", deltas, wantDeltas)
+	}
+	if content != "本周完成了 [[NAME_1]] 的需求" {
+		t.Errorf("content = %q", content)
+	}
+
+	body := recorder.bodies[0]
+	if body["stream"] != true || body["model"] != "deepseek-chat" {
+		t.Errorf("stream = %v, model = %v", body["stream"], body["model"])
+	}
+	if got := recorder.headers[0].Get("Accept"); got != "text/event-stream" {
+		t.Errorf("Accept = %q", got)
+	}
+	checkMessages(t, body)
+}
+
+func TestOpenAIProviderStreamErrors(t *testing.T) {
+	t.Run("无法解析的数据", func(t *testing.T) {
+		recorder := &requestRecorder{responses: []string{"data: {not json}\n\n"}}
+		provider := newTestProvider(t, &ProviderConfig{Type: ProviderOpenAI, APIKey: "sk-test"}, recorder)
+		_, err := StreamCompletion(context.Background(), provider, testCompletionRequest, func(string) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), "parse stream chunk") {
+			t.Errorf("错误 = %v", err)
+		}
+	})
+
+	t.Run("onDelta 返回错误时中止", func(t *testing.T) {
+		recorder := &requestRecorder{responses: []string{`data: {"choices":[{"delta":{"content":"a"}}]}` + "\n\n" + `data: {"choices":[{"delta":{"content":"b"}}]}` + "\n\n"}}
+		provider := newTestProvider(t, &ProviderConfig{Type: ProviderOpenAI, APIKey: "sk-test"}, recorder)
+		stop := errors.New("client gone")
+		calls := 0
+		_, err := StreamCompletion(context.Background(), provider, testCompletionRequest, func(string) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Errorf("错误 = %v, 调用 %d 次", err, calls)
+		}
+	})
+}
+
+func TestOllamaProviderComplete(t *testing.T) {
+	temperature := 0.1
+	recorder := &requestRecorder{responses: []string{`{"model":"qwen2.5","message":{"role":"assistant","content":"本地模型的回复\n"},"done":true}`}}
+	provider := newTestProvider(t, &ProviderConfig{Type: ProviderOllama, Temperature: &temperature, MaxTokens: 300}, recorder)
+
+	content, err := provider.Complete(context.Background(), testCompletionRequest)
+	if err != nil {
+		t.Fatalf("Complete 返回错误: %v", err)
+	}
+	if content != "本地模型的回复" {
+		t.Errorf("content = %q", content)
+	}
+
+	if recorder.paths[0] != "/v1/api/chat" {
+		t.Errorf("请求路径 = %q", recorder.paths[0])
+	}
+	if got := recorder.headers[0].Get("Authorization"); got != "" {
+		t.Errorf("Ollama 不需要 Authorization，实际为 %q", got)
+	}
+	body := recorder.bodies[0]
+	if body["model"] != "qwen2.5" || body["stream"] != false {
+		t.Errorf("model = %v, stream = %v", body["model"], body["stream"])
+	}
+	if options, _ := body["options"].(map[string]interface{}); options["temperature"] != 0.1 || options["num_predict"] != float64(300) {
+		t.Errorf("options = %v", body["options"])
+	}
+	checkMessages(t, body)
+}
+
+func TestOllamaProviderStream(t *testing.T) {
+	stream := `{"message":{"role":"assistant","content":"第一段"},"done":false}` + "\n\n" +
+		`{"message":{"role":"assistant","content":"，第二段"},"done":false}` + "\n" +
+		`{"message":{"role":"assistant","content":""},"done":true}` + "\n" +
+		`{"message":{"role":"assistant","content":"不应输出"},"done":false}` + "\n"
+	recorder := &requestRecorder{responses: []string{stream}}
+	provider := newTestProvider(t, &ProviderConfig{Type: ProviderOllama}, recorder)
+
+	var deltas []string
+	content, err := StreamCompletion(context.Background(), provider, testCompletionRequest, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamCompletion 返回错误: %v", err)
+	}
+	if strings.Join(deltas, "|") != "第一段|，第二段" || content != "第一段，第二段" {
+		t.Errorf("deltas = %q, content = %q", deltas, content)
+	}
+	if recorder.bodies[0]["stream"] != true {
+		t.Errorf("stream = %v", recorder.bodies[0]["stream"])
+	}
+
+	t.Run("流中的错误", func(t *testing.T) {
+		recorder := &requestRecorder{responses: []string{`{"error":"model 'qwen9' not found"}` + "\n"}}
+		provider := newTestProvider(t, &ProviderConfig{Type: ProviderOllama}, recorder)
+		_, err := StreamCompletion(context.Background(), provider, testCompletionRequest, func(string) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), "qwen9") {
+			t.Errorf("错误 = %v", err)
+		}
+	})
+}
+
+func TestProviderOverrides(t *testing.T) {
+	temperature := 1.3
+	settings := &AISettings{
+		Provider: "writer",
+		Providers: map[string]*ProviderConfig{
+			"writer": {Type: ProviderDeepSeek, APIKey: "sk-a", Model: "deepseek-reasoner", Temperature: &temperature, MaxTokens: 4000, TimeoutSeconds: 7},
+			"local":  {Type: ProviderOllama, TimeoutSeconds: 3},
+			"zhipu":  {APIKey: "sk-b"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		provider    string
+		baseURL     string
+		model       string
+		temperature float64
+		maxTokens   int
+		timeout     time.Duration
+	}{
+		{"默认服务商", "", "https://api.deepseek.com/v1", "deepseek-reasoner", 1.3, 4000, 7 * time.Second},
+		{"按名称选择", "local", "http://localhost:11434", "qwen2.5", defaultAITemperature, defaultAIMaxTokens, 3 * time.Second},
+		{"名称即类型", "zhipu", "https://open.bigmodel.cn/api/paas/v4", "glm-4-flash", defaultAITemperature, defaultAIMaxTokens, defaultAITimeoutSeconds * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := settings.NewProvider(tt.provider)
+			if err != nil {
+				t.Fatalf("NewProvider 返回错误: %v", err)
+			}
+
+			var cfg *ProviderConfig
+			var client, streamClient *http.Client
+			switch p := provider.(type) {
+			case *OpenAIProvider:
+				cfg, client, streamClient = p.config, p.client, p.streamClient
+			case *OllamaProvider:
+				cfg, client, streamClient = p.config, p.client, p.streamClient
+			default:
+				t.Fatalf("服务商类型 = %T", provider)
+			}
+
+			if cfg.BaseURL != tt.baseURL || cfg.Model != tt.model {
+				t.Errorf("base_url = %q, model = %q", cfg.BaseURL, cfg.Model)
+			}
+			if *cfg.Temperature != tt.temperature || cfg.MaxTokens != tt.maxTokens {
+				t.Errorf("temperature = %v, max_tokens = %d", *cfg.Temperature, cfg.MaxTokens)
+			}
+			if client.Timeout != tt.timeout {
+				t.Errorf("请求超时 = %v, 期望 %v", client.Timeout, tt.timeout)
+			}
+			// 流式请求不设整体超时，只限制等待响应头的时间
+			if streamClient.Timeout != 0 || streamClient.Transport.(*http.Transport).ResponseHeaderTimeout != tt.timeout {
+				t.Errorf("流式请求超时 = %v/%v", streamClient.Timeout, streamClient.Transport.(*http.Transport).ResponseHeaderTimeout)
+			}
+		})
+	}
+
+	// 每次请求都会创建服务商，超时相同时应共用流式客户端的连接池
+	first, _ := settings.NewProvider("")
+	second, _ := settings.NewProvider("")
+	if first.(*OpenAIProvider).streamClient != second.(*OpenAIProvider).streamClient {
+		t.Error("相同超时的服务商没有共用流式请求客户端")
+	}
+}
+
+func TestProviderTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	provider, err := (&AISettings{Providers: map[string]*ProviderConfig{
+		"slow": {Type: ProviderOpenAI, BaseURL: server.URL, APIKey: "sk-test", TimeoutSeconds: 1},
+	}}).NewProvider("slow")
+	if err != nil {
+		t.Fatalf("NewProvider 返回错误: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := provider.Complete(context.Background(), testCompletionRequest); err == nil {
+		t.Fatal("期望超时错误")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("超时后 %v 才返回", elapsed)
+	}
+}
+
+func TestNewProviderAPIKeyMissing(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("CUSTOM_KEY", "")
+
+	settings := &AISettings{Providers: map[string]*ProviderConfig{
+		"custom": {Type: ProviderOpenAI, APIKeyEnv: "CUSTOM_KEY"},
+		"local":  {Type: ProviderOllama},
+	}}
+
+	for _, name := range []string{"openai", "custom"} {
+		if _, err := settings.NewProvider(name); !errors.Is(err, ErrAPIKeyMissing) {
+			t.Errorf("NewProvider(%q) 错误 = %v, 期望 ErrAPIKeyMissing", name, err)
+		}
+	}
+	if _, err := settings.NewProvider("local"); err != nil {
+		t.Errorf("Ollama 不需要 API 密钥: %v", err)
+	}
+
+	t.Setenv("CUSTOM_KEY", "sk-from-env")
+	provider, err := settings.NewProvider("custom")
+	if err != nil {
+		t.Fatalf("设置环境变量后 NewProvider 返回错误: %v", err)
+	}
+	if key := provider.(*OpenAIProvider).config.APIKey; key != "sk-from-env" {
+		t.Errorf("APIKey = %q", key)
+	}
+}
+
+func TestOptimizeFallsBackWithoutAPIKey(t *testing.T) {
+	t.Setenv("DEEPSEEK_API_KEY", "")
+	saved := serverConfig
+	serverConfig = DefaultConfig()
+	defer func() { serverConfig = saved }()
+
+	content := "# 张三 的工作周报\n\n## 功能开发\n\n1. feat: 新增导出功能\n"
+	body, _ := json.Marshal(OptimizeReportRequest{Content: content, Type: ReportTypeWeekly})
+
+	for _, endpoint := range []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"optimize-report", optimizeReportHandler},
+		{"optimize-report/stream", optimizeReportStreamHandler},
+	} {
+		t.Run(endpoint.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			endpoint.handler(recorder, httptest.NewRequest("POST", "/api/"+endpoint.name, bytes.NewReader(body)))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("状态码 = %d: %s", recorder.Code, recorder.Body)
+			}
+
+			response := recorder.Body.String()
+			if endpoint.name == "optimize-report/stream" {
+				_, done, ok := strings.Cut(response, "event: done\ndata: ")
+				if !ok {
+					t.Fatalf("缺少 done 事件: %q", response)
+				}
+				response = strings.TrimSpace(done)
+			}
+			var result OptimizeReportResponse
+			if err := json.Unmarshal([]byte(response), &result); err != nil {
+				t.Fatalf("解析响应失败: %v", err)
+			}
+			if !result.Offline || !strings.HasPrefix(result.OptimizedContent, "## 总结") || !strings.HasSuffix(result.OptimizedContent, content) {
+				t.Errorf("离线结果 = %+v", result)
+			}
+		})
+	}
+}
+
+func TestOfflineOptimizeUsesStructuredReport(t *testing.T) {
+	saved := serverConfig
+	serverConfig = DefaultConfig()
+	serverConfig.history = NewHistoryStore(filepath.Join(t.TempDir(), "history.db"))
+	defer func() { serverConfig = saved }()
+
+	report := newTestReport(t, false, testReportCommits()...)
+	content := "# 张三 的工作周报\n\n1. feat(export): add yaml export\n"
+	entry, err := serverConfig.History().Record(report, content, "", HistorySourceAPI, nil)
+	if err != nil {
+		t.Fatalf("Record 返回错误: %v", err)
+	}
+	want := "## 总结\n\n" + BuildNarrative(report, NarrativeChinese) + "\n\n" + content
+
+	tests := []struct {
+		name string
+		req  *OptimizeReportRequest
+		want string
+	}{
+		{"请求中的结构化报告", &OptimizeReportRequest{Content: content, Report: NewReportExport(report)}, want},
+		{"报告历史中的报告", &OptimizeReportRequest{Content: content, ReportID: entry.ID}, want},
+		{
+			"历史中不存在时解析报告文本",
+			&OptimizeReportRequest{Content: content, Type: ReportTypeWeekly, ReportID: entry.ID + 100},
+			"## 总结\n\n" + BuildNarrative(reportFromMarkdown(content, ReportTypeWeekly, serverConfig), NarrativeChinese) + "\n\n" + content,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := offlineOptimize(tt.req).OptimizedContent; got != tt.want {
+				t.Errorf("OptimizedContent = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFakeProvider(t *testing.T) {
+	t.Run("回显用户消息", func(t *testing.T) {
+		provider := NewFakeProvider("")
+		content, err := provider.Complete(context.Background(), testCompletionRequest)
+		if err != nil || content != "优化这份周报" {
+			t.Errorf("content = %q, err = %v", content, err)
+		}
+		if len(provider.Requests()) != 1 {
+			t.Errorf("Requests() = %d", len(provider.Requests()))
+		}
+	})
+
+	t.Run("流式输出拼接后与原文相同", func(t *testing.T) {
+		provider := NewFakeProvider("第一行 内容\n第二行  结尾")
+		var deltas []string
+		content, err := StreamCompletion(context.Background(), provider, testCompletionRequest, func(delta string) error {
+			deltas = append(deltas, delta)
+			return nil
+		})
+		if err != nil || content != provider.Response || strings.Join(deltas, "") != provider.Response || len(deltas) != 4 {
+			t.Errorf("deltas = %q, content = %q, err = %v", deltas, content, err)
+		}
+	})
+
+	t.Run("预设错误", func(t *testing.T) {
+		provider := &FakeProvider{Err: fmt.Errorf("quota exceeded")}
+		if _, err := provider.Complete(context.Background(), testCompletionRequest); err == nil {
+			t.Error("期望返回错误")
+		}
+	})
+
+	t.Run("context 已取消", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := NewFakeProvider("x").Complete(ctx, testCompletionRequest); !errors.Is(err, context.Canceled) {
+			t.Errorf("错误 = %v", err)
+		}
+	})
+}
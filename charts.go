@@ -0,0 +1,370 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 图表尺寸，SVG 使用 viewBox 按容器宽度缩放
+const (
+	chartWidth      = 640
+	chartHeight     = 220
+	chartPadLeft    = 40
+	chartPadRight   = 10
+	chartPadTop     = 20
+	chartPadBottom  = 30
+	chartMaxLabels  = 12 // 横轴最多显示的标签数，超出时间隔显示
+	chartRowHeight  = 24 // 横向条形图每行高度
+	chartLabelWidth = 120
+	chartMaxRows    = 8 // 横向条形图最多显示的行数，其余合并为"其他"
+)
+
+// 图表配色
+const (
+	chartColorCommits   = "#3b82f6"
+	chartColorFileTypes = "#8b5cf6"
+	chartColorAdditions = "#16a34a"
+	chartColorDeletions = "#dc2626"
+	chartColorAxis      = "#d1d5db"
+	chartColorText      = "#4b5563"
+)
+
+// chartPoint 图表中的一个数据点
+type chartPoint struct {
+	Label string
+	Value int
+}
+
+// churnPoint 代码增删图表中的一个数据点
+type churnPoint struct {
+	Label     string
+	Additions int
+	Deletions int
+}
+
+// chartBucket 时间趋势的统计粒度，与 ReportSummary 中各统计的键格式一致
+type chartBucket struct {
+	key  func(t time.Time) string
+	next func(t time.Time) time.Time
+}
+
+var (
+	dailyBucket = chartBucket{
+		key:  func(t time.Time) string { return t.Format("01-02") },
+		next: func(t time.Time) time.Time { return t.AddDate(0, 0, 1) },
+	}
+	weeklyBucket = chartBucket{
+		key: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		},
+		next: func(t time.Time) time.Time { return t.AddDate(0, 0, 7) },
+	}
+	monthlyBucket = chartBucket{
+		key:  func(t time.Time) string { return t.Format("2006-01") },
+		next: func(t time.Time) time.Time { return t.AddDate(0, 1, 0) },
+	}
+)
+
+// trendBucket 返回报告中已有的最细粒度的时间统计，没有时间统计时返回 nil
+func trendBucket(summary *ReportSummary) (*chartBucket, map[string]int) {
+	switch {
+	case summary == nil:
+		return nil, nil
+	case len(summary.DailyStats) > 0:
+		return &dailyBucket, summary.DailyStats
+	case len(summary.WeeklyStats) > 0:
+		return &weeklyBucket, summary.WeeklyStats
+	case len(summary.MonthlyStats) > 0:
+		return &monthlyBucket, summary.MonthlyStats
+	}
+	return nil, nil
+}
+
+// bucketKeys 按时间顺序返回统计周期内的所有键，没有提交的日期也包含在内
+func (b *chartBucket) bucketKeys(since, until time.Time) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for t := since; !t.After(until); t = b.next(t) {
+		if key := b.key(t); !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	// 周、月步进可能跳过结束日期所在的周期
+	if key := b.key(until); !until.Before(since) && !seen[key] {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// trendSeries 返回提交趋势的数据点
+func trendSeries(report *Report) []chartPoint {
+	bucket, stats := trendBucket(report.Summary)
+	if bucket == nil {
+		return nil
+	}
+
+	keys := bucket.bucketKeys(report.Since, report.Until)
+	if len(keys) == 0 {
+		keys = sortedStatKeys(stats)
+	}
+
+	points := make([]chartPoint, len(keys))
+	for i, key := range keys {
+		points[i] = chartPoint{Label: key, Value: stats[key]}
+	}
+	return points
+}
+
+// churnSeries 返回代码增删的数据点：有时间统计时按相同粒度分布，否则按分类统计
+func churnSeries(report *Report) []churnPoint {
+	if bucket, _ := trendBucket(report.Summary); bucket != nil {
+		totals := make(map[string]*churnPoint)
+		for _, commit := range report.Commits {
+			key := bucket.key(commit.Date)
+			if totals[key] == nil {
+				totals[key] = &churnPoint{Label: key}
+			}
+			totals[key].Additions += commit.Additions
+			totals[key].Deletions += commit.Deletions
+		}
+
+		var points []churnPoint
+		for _, key := range bucket.bucketKeys(report.Since, report.Until) {
+			if p := totals[key]; p != nil {
+				points = append(points, *p)
+			} else {
+				points = append(points, churnPoint{Label: key})
+			}
+		}
+		return points
+	}
+
+	var points []churnPoint
+	for _, name := range sortedCategoryNames(report.Categories) {
+		p := churnPoint{Label: name}
+		for _, commit := range report.Categories[name] {
+			p.Additions += commit.Additions
+			p.Deletions += commit.Deletions
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+// fileTypeSeries 返回文件类型分布的数据点，按数量从高到低，超出 chartMaxRows 的合并为"其他"
+func fileTypeSeries(fileTypes map[string]int) []chartPoint {
+	var points []chartPoint
+	for name, count := range fileTypes {
+		points = append(points, chartPoint{Label: name, Value: count})
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Value != points[j].Value {
+			return points[i].Value > points[j].Value
+		}
+		return points[i].Label < points[j].Label
+	})
+
+	if len(points) > chartMaxRows {
+		rest := chartPoint{Label: OtherCategory}
+		for _, p := range points[chartMaxRows-1:] {
+			rest.Value += p.Value
+		}
+		points = append(points[:chartMaxRows-1], rest)
+	}
+	return points
+}
+
+// sortedStatKeys 返回统计中按字典序排序的键
+func sortedStatKeys(stats map[string]int) []string {
+	keys := make([]string, 0, len(stats))
+	for key := range stats {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedCategoryNames 返回按名称排序的分类，"其他"排在最后
+func sortedCategoryNames(categories map[string][]*GitCommit) []string {
+	names := make([]string, 0, len(categories))
+	for name := range categories {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == OtherCategory) != (names[j] == OtherCategory) {
+			return names[j] == OtherCategory
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// svgWriter 拼接 SVG 元素，文本统一转义
+type svgWriter struct {
+	b strings.Builder
+}
+
+// open 写入 svg 根元素
+func (w *svgWriter) open(width, height int, title string) {
+	fmt.Fprintf(&w.b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" role="img" aria-label="%s" font-family="sans-serif" font-size="11">`,
+		width, height, width, height, template.HTMLEscapeString(title))
+	fmt.Fprintf(&w.b, `<title>%s</title>`, template.HTMLEscapeString(title))
+}
+
+// rect 写入矩形，tooltip 作为鼠标悬停时的提示；高度或宽度为 0 时不写入
+func (w *svgWriter) rect(x, y, width, height float64, color, tooltip string) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+	fmt.Fprintf(&w.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s</title></rect>`,
+		x, y, width, height, color, template.HTMLEscapeString(tooltip))
+}
+
+// line 写入线段
+func (w *svgWriter) line(x1, y1, x2, y2 float64, color string) {
+	fmt.Fprintf(&w.b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="1"/>`, x1, y1, x2, y2, color)
+}
+
+// text 写入文本，anchor 为 start、middle 或 end
+func (w *svgWriter) text(x, y float64, anchor, color, content string) {
+	fmt.Fprintf(&w.b, `<text x="%.1f" y="%.1f" text-anchor="%s" fill="%s">%s</text>`,
+		x, y, anchor, color, template.HTMLEscapeString(content))
+}
+
+// close 结束 svg 元素并返回可直接嵌入 HTML 的内容
+func (w *svgWriter) close() template.HTML {
+	w.b.WriteString(`</svg>`)
+	return template.HTML(w.b.String())
+}
+
+// labelStep 横轴标签的显示间隔，避免标签重叠
+func labelStep(n int) int {
+	return (n + chartMaxLabels - 1) / chartMaxLabels
+}
+
+// barChartSVG 生成纵向条形图，用于提交趋势
+func barChartSVG(title string, points []chartPoint, color string) template.HTML {
+	if len(points) == 0 {
+		return ""
+	}
+
+	max := 0
+	for _, p := range points {
+		if p.Value > max {
+			max = p.Value
+		}
+	}
+	if max == 0 {
+		max = 1
+	}
+
+	plotWidth := float64(chartWidth - chartPadLeft - chartPadRight)
+	plotHeight := float64(chartHeight - chartPadTop - chartPadBottom)
+	baseline := float64(chartPadTop) + plotHeight
+	slot := plotWidth / float64(len(points))
+	step := labelStep(len(points))
+
+	var w svgWriter
+	w.open(chartWidth, chartHeight, title)
+	w.line(chartPadLeft, chartPadTop, chartWidth-chartPadRight, chartPadTop, chartColorAxis)
+	w.line(chartPadLeft, baseline, chartWidth-chartPadRight, baseline, chartColorAxis)
+	w.text(chartPadLeft-6, chartPadTop+4, "end", chartColorText, fmt.Sprint(max))
+	w.text(chartPadLeft-6, baseline+4, "end", chartColorText, "0")
+
+	for i, p := range points {
+		x := float64(chartPadLeft) + slot*float64(i)
+		height := plotHeight * float64(p.Value) / float64(max)
+		w.rect(x+slot*0.15, baseline-height, slot*0.7, height, color, fmt.Sprintf("%s: %d", p.Label, p.Value))
+		if p.Value > 0 && len(points) <= 31 {
+			w.text(x+slot/2, baseline-height-4, "middle", chartColorText, fmt.Sprint(p.Value))
+		}
+		if i%step == 0 {
+			w.text(x+slot/2, baseline+16, "middle", chartColorText, p.Label)
+		}
+	}
+
+	return w.close()
+}
+
+// hbarChartSVG 生成横向条形图，用于文件类型等分类数据
+func hbarChartSVG(title string, points []chartPoint, color string) template.HTML {
+	if len(points) == 0 {
+		return ""
+	}
+
+	max := 0
+	for _, p := range points {
+		if p.Value > max {
+			max = p.Value
+		}
+	}
+	if max == 0 {
+		max = 1
+	}
+
+	height := chartRowHeight*len(points) + chartPadTop
+	barMax := float64(chartWidth - chartLabelWidth - 60)
+
+	var w svgWriter
+	w.open(chartWidth, height, title)
+	for i, p := range points {
+		y := float64(chartPadTop/2 + chartRowHeight*i)
+		width := barMax * float64(p.Value) / float64(max)
+		w.text(chartLabelWidth-8, y+chartRowHeight/2+4, "end", chartColorText, p.Label)
+		w.rect(chartLabelWidth, y+4, width, chartRowHeight-8, color, fmt.Sprintf("%s: %d", p.Label, p.Value))
+		w.text(chartLabelWidth+width+6, y+chartRowHeight/2+4, "start", chartColorText, fmt.Sprint(p.Value))
+	}
+
+	return w.close()
+}
+
+// churnChartSVG 生成代码增删图：新增在基线以上，删除在基线以下，使用相同比例
+func churnChartSVG(title string, points []churnPoint) template.HTML {
+	if len(points) == 0 {
+		return ""
+	}
+
+	max := 0
+	for _, p := range points {
+		if p.Additions > max {
+			max = p.Additions
+		}
+		if p.Deletions > max {
+			max = p.Deletions
+		}
+	}
+	if max == 0 {
+		max = 1
+	}
+
+	plotWidth := float64(chartWidth - chartPadLeft - chartPadRight)
+	half := float64(chartHeight-chartPadTop-chartPadBottom) / 2
+	baseline := float64(chartPadTop) + half
+	slot := plotWidth / float64(len(points))
+	step := labelStep(len(points))
+
+	var w svgWriter
+	w.open(chartWidth, chartHeight, title)
+	w.line(chartPadLeft, baseline, chartWidth-chartPadRight, baseline, chartColorAxis)
+	w.text(chartPadLeft-6, chartPadTop+4, "end", chartColorAdditions, fmt.Sprintf("+%d", max))
+	w.text(chartPadLeft-6, baseline+half+4, "end", chartColorDeletions, fmt.Sprintf("-%d", max))
+
+	for i, p := range points {
+		x := float64(chartPadLeft) + slot*float64(i)
+		up := half * float64(p.Additions) / float64(max)
+		down := half * float64(p.Deletions) / float64(max)
+		tooltip := fmt.Sprintf("%s: +%d/-%d", p.Label, p.Additions, p.Deletions)
+		w.rect(x+slot*0.15, baseline-up, slot*0.7, up, chartColorAdditions, tooltip)
+		w.rect(x+slot*0.15, baseline, slot*0.7, down, chartColorDeletions, tooltip)
+		if i%step == 0 {
+			w.text(x+slot/2, float64(chartHeight-8), "middle", chartColorText, p.Label)
+		}
+	}
+
+	return w.close()
+}
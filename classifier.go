@@ -0,0 +1,422 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// classifierModelVersion 模型文件格式版本
+const classifierModelVersion = 1
+
+// Classifier 朴素贝叶斯提交分类器，特征为提交标题中的词和变更文件的路径信息
+// 完全离线运行，模型以JSON保存在本地
+type Classifier struct {
+	Version    int                         `json:"version"`
+	TrainedAt  time.Time                   `json:"trained_at"`
+	Documents  int                         `json:"documents"`  // 训练样本数
+	Vocabulary []string                    `json:"vocabulary"` // 所有出现过的特征
+	Categories map[string]*ClassifierClass `json:"categories"` // 各分类的统计
+}
+
+// ClassifierClass 单个分类的训练统计
+type ClassifierClass struct {
+	Documents int            `json:"documents"` // 该分类的样本数
+	Tokens    int            `json:"tokens"`    // 该分类的特征总数
+	Counts    map[string]int `json:"counts"`    // 各特征出现次数
+}
+
+// TrainingExample 一条带标注的训练样本，对应标注JSONL文件中的一行
+// 只给出 hash 时从仓库中读取该提交的标题和文件，用于纠正已有提交的分类
+type TrainingExample struct {
+	Hash     string   `json:"hash,omitempty"`
+	Message  string   `json:"message,omitempty"`
+	Files    []string `json:"files,omitempty"`
+	Category string   `json:"category"`
+}
+
+// Prediction 分类预测结果
+type Prediction struct {
+	Category   string   // 预测的分类
+	Confidence float64  // 后验概率(0-1)
+	Features   []string // 对结果贡献最大的特征
+}
+
+// ClassifierEvaluation 分类器在标注数据上的评估结果
+type ClassifierEvaluation struct {
+	Total      int
+	Correct    int
+	Accuracy   float64
+	Categories []*CategoryMetrics // 按分类名排序
+}
+
+// CategoryMetrics 单个分类的评估指标
+type CategoryMetrics struct {
+	Name      string
+	Support   int     // 标注为该分类的样本数
+	Predicted int     // 预测为该分类的样本数
+	Correct   int     // 预测正确的样本数
+	Precision float64 // Correct / Predicted
+	Recall    float64 // Correct / Support
+}
+
+// NewClassifier 创建空的分类器
+func NewClassifier() *Classifier {
+	return &Classifier{
+		Version:    classifierModelVersion,
+		Categories: make(map[string]*ClassifierClass),
+	}
+}
+
+// LoadClassifier 从文件加载分类器模型
+func LoadClassifier(filename string) (*Classifier, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("读取分类模型失败: %v", err)
+	}
+
+	var classifier Classifier
+	if err := json.Unmarshal(data, &classifier); err != nil {
+		return nil, fmt.Errorf("解析分类模型失败: %v", err)
+	}
+	if classifier.Version != classifierModelVersion {
+		return nil, fmt.Errorf("不支持的分类模型版本: %d", classifier.Version)
+	}
+	if len(classifier.Categories) == 0 {
+		return nil, fmt.Errorf("分类模型中没有任何分类")
+	}
+
+	return &classifier, nil
+}
+
+// Save 保存模型到文件
+func (c *Classifier) Save(filename string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化分类模型失败: %v", err)
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("保存分类模型失败: %v", err)
+	}
+	return nil
+}
+
+// Train 用标注样本训练分类器，可多次调用以追加样本
+func (c *Classifier) Train(examples []*TrainingExample) {
+	vocabulary := make(map[string]bool)
+	for _, token := range c.Vocabulary {
+		vocabulary[token] = true
+	}
+
+	for _, example := range examples {
+		class, ok := c.Categories[example.Category]
+		if !ok {
+			class = &ClassifierClass{Counts: make(map[string]int)}
+			c.Categories[example.Category] = class
+		}
+
+		class.Documents++
+		c.Documents++
+		for _, token := range extractFeatures(example.Message, example.Files) {
+			class.Counts[token]++
+			class.Tokens++
+			vocabulary[token] = true
+		}
+	}
+
+	c.Vocabulary = c.Vocabulary[:0]
+	for token := range vocabulary {
+		c.Vocabulary = append(c.Vocabulary, token)
+	}
+	sort.Strings(c.Vocabulary)
+	c.TrainedAt = time.Now()
+}
+
+// Predict 预测提交标题和变更文件所属的分类
+func (c *Classifier) Predict(message string, files []string) *Prediction {
+	if c.Documents == 0 {
+		return nil
+	}
+
+	tokens := extractFeatures(message, files)
+	vocabularySize := float64(len(c.Vocabulary) + 1)
+
+	// 各分类的对数后验（未归一化），使用拉普拉斯平滑
+	scores := make(map[string]float64, len(c.Categories))
+	for name, class := range c.Categories {
+		score := math.Log(float64(class.Documents) / float64(c.Documents))
+		for _, token := range tokens {
+			score += math.Log((float64(class.Counts[token]) + 1) / (float64(class.Tokens) + vocabularySize))
+		}
+		scores[name] = score
+	}
+
+	best := ""
+	for _, name := range c.sortedCategories() {
+		if best == "" || scores[name] > scores[best] {
+			best = name
+		}
+	}
+
+	// 归一化为概率
+	var total float64
+	for _, score := range scores {
+		total += math.Exp(score - scores[best])
+	}
+
+	return &Prediction{
+		Category:   best,
+		Confidence: 1 / total,
+		Features:   c.topFeatures(best, tokens, 3),
+	}
+}
+
+// topFeatures 返回对预测分类贡献最大的特征（相对其他分类的平均似然）
+func (c *Classifier) topFeatures(category string, tokens []string, limit int) []string {
+	vocabularySize := float64(len(c.Vocabulary) + 1)
+	likelihood := func(class *ClassifierClass, token string) float64 {
+		return math.Log((float64(class.Counts[token]) + 1) / (float64(class.Tokens) + vocabularySize))
+	}
+
+	type weighted struct {
+		token  string
+		weight float64
+	}
+	var features []weighted
+	for _, token := range tokens {
+		if c.Categories[category].Counts[token] == 0 {
+			continue
+		}
+		var others float64
+		for name, class := range c.Categories {
+			if name != category {
+				others += likelihood(class, token)
+			}
+		}
+		if len(c.Categories) > 1 {
+			others /= float64(len(c.Categories) - 1)
+		}
+		features = append(features, weighted{token, likelihood(c.Categories[category], token) - others})
+	}
+
+	sort.SliceStable(features, func(i, j int) bool {
+		return features[i].weight > features[j].weight
+	})
+
+	var result []string
+	for i, feature := range features {
+		if i >= limit || feature.weight <= 0 {
+			break
+		}
+		result = append(result, feature.token)
+	}
+	return result
+}
+
+// Evaluate 在标注样本上评估分类器，统计各分类的精确率和召回率
+func (c *Classifier) Evaluate(examples []*TrainingExample) *ClassifierEvaluation {
+	evaluation := &ClassifierEvaluation{}
+	metrics := make(map[string]*CategoryMetrics)
+	metric := func(name string) *CategoryMetrics {
+		if _, ok := metrics[name]; !ok {
+			metrics[name] = &CategoryMetrics{Name: name}
+		}
+		return metrics[name]
+	}
+
+	for _, example := range examples {
+		prediction := c.Predict(example.Message, example.Files)
+		if prediction == nil {
+			continue
+		}
+
+		evaluation.Total++
+		metric(example.Category).Support++
+		metric(prediction.Category).Predicted++
+		if prediction.Category == example.Category {
+			evaluation.Correct++
+			metric(example.Category).Correct++
+		}
+	}
+
+	if evaluation.Total > 0 {
+		evaluation.Accuracy = float64(evaluation.Correct) / float64(evaluation.Total)
+	}
+
+	for _, m := range metrics {
+		if m.Predicted > 0 {
+			m.Precision = float64(m.Correct) / float64(m.Predicted)
+		}
+		if m.Support > 0 {
+			m.Recall = float64(m.Correct) / float64(m.Support)
+		}
+		evaluation.Categories = append(evaluation.Categories, m)
+	}
+	sort.Slice(evaluation.Categories, func(i, j int) bool {
+		return evaluation.Categories[i].Name < evaluation.Categories[j].Name
+	})
+
+	return evaluation
+}
+
+// sortedCategories 返回排序后的分类名，保证预测结果稳定
+func (c *Classifier) sortedCategories() []string {
+	names := make([]string, 0, len(c.Categories))
+	for name := range c.Categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// extractFeatures 提取分类特征，每个特征在一个提交中只计一次
+// 标题中英文按词切分，中文使用单字和相邻两字；文件路径贡献扩展名、目录名和文件名中的词
+func extractFeatures(message string, files []string) []string {
+	seen := make(map[string]bool)
+	var features []string
+	add := func(feature string) {
+		if !seen[feature] {
+			seen[feature] = true
+			features = append(features, feature)
+		}
+	}
+
+	for _, token := range tokenizeMessage(message) {
+		add(token)
+	}
+
+	for _, file := range files {
+		file = strings.ToLower(file)
+		if ext := path.Ext(file); ext != "" {
+			add("ext:" + strings.TrimPrefix(ext, "."))
+		}
+		dir, base := path.Split(file)
+		for _, segment := range strings.Split(strings.Trim(dir, "/"), "/") {
+			if segment != "" {
+				add("dir:" + segment)
+			}
+		}
+		for _, word := range strings.FieldsFunc(strings.TrimSuffix(base, path.Ext(base)), isNotWordRune) {
+			add("file:" + word)
+		}
+	}
+
+	return features
+}
+
+// tokenizeMessage 切分提交标题
+func tokenizeMessage(message string) []string {
+	var tokens []string
+	var han []rune
+
+	flushHan := func() {
+		for i, r := range han {
+			tokens = append(tokens, string(r))
+			if i > 0 {
+				tokens = append(tokens, string(han[i-1:i+1]))
+			}
+		}
+		han = han[:0]
+	}
+
+	var word strings.Builder
+	flushWord := func() {
+		if word.Len() > 1 {
+			tokens = append(tokens, word.String())
+		}
+		word.Reset()
+	}
+
+	for _, r := range strings.ToLower(message) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case !isNotWordRune(r):
+			flushHan()
+			word.WriteRune(r)
+		default:
+			flushHan()
+			flushWord()
+		}
+	}
+	flushHan()
+	flushWord()
+
+	return tokens
+}
+
+// isNotWordRune 判断字符是否为词的分隔符
+func isNotWordRune(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+}
+
+// LoadTrainingData 读取标注JSONL文件，每行一个 {"message", "files", "category"} 或 {"hash", "category"} 对象
+func LoadTrainingData(filename string) ([]*TrainingExample, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("读取标注数据失败: %v", err)
+	}
+	defer file.Close()
+
+	var examples []*TrainingExample
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var example TrainingExample
+		if err := json.Unmarshal([]byte(text), &example); err != nil {
+			return nil, fmt.Errorf("标注数据第%d行解析失败: %v", line, err)
+		}
+		if example.Category == "" {
+			return nil, fmt.Errorf("标注数据第%d行缺少 category", line)
+		}
+		if example.Message == "" && example.Hash == "" {
+			return nil, fmt.Errorf("标注数据第%d行缺少 message 或 hash", line)
+		}
+		examples = append(examples, &example)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取标注数据失败: %v", err)
+	}
+
+	return examples, nil
+}
+
+// ExamplesFromCommits 把用户通过 "Category: xxx" trailer 标注过分类的提交转换为训练样本
+func ExamplesFromCommits(commits []*GitCommit, categories CategoryRules) []*TrainingExample {
+	var examples []*TrainingExample
+	for _, commit := range commits {
+		for _, value := range commit.TrailerValues("Category") {
+			name := ""
+			for _, category := range categories {
+				if strings.EqualFold(category.Name, value) {
+					name = category.Name
+					break
+				}
+			}
+			if name == "" {
+				continue
+			}
+			examples = append(examples, &TrainingExample{
+				Hash:     commit.Hash,
+				Message:  commit.Message,
+				Files:    commit.Files,
+				Category: name,
+			})
+			break
+		}
+	}
+	return examples
+}
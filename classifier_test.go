@@ -0,0 +1,163 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// classifierExamples 小型标注样本，各分类的标题和文件特征互不重叠
+var classifierExamples = []*TrainingExample{
+	{Message: "修复登录崩溃", Files: []string{"auth/login.go"}, Category: "Bug修复"},
+	{Message: "修复空指针", Files: []string{"auth/session.go"}, Category: "Bug修复"},
+	{Message: "crash on empty input", Files: []string{"parser/lexer.go"}, Category: "Bug修复"},
+	{Message: "更新部署文档", Files: []string{"docs/deploy.md"}, Category: "文档更新"},
+	{Message: "补充接口说明", Files: []string{"docs/api.md"}, Category: "文档更新"},
+	{Message: "describe usage in readme", Files: []string{"README.md"}, Category: "文档更新"},
+}
+
+func TestExtractFeatures(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		files   []string
+		want    []string
+	}{
+		{"英文按词切分并忽略单字符", "Fix a Crash in parser", nil, []string{"fix", "crash", "in", "parser"}},
+		{"中文单字和相邻两字", "修复bug", nil, []string{"修", "复", "修复", "bug"}},
+		{"文件路径特征", "", []string{"pkg/Auth/login_test.go"}, []string{"ext:go", "dir:pkg", "dir:auth", "file:login", "file:test"}},
+		{"重复特征只计一次", "fix fix", []string{"a/x.go", "a/y.go"}, []string{"fix", "ext:go", "dir:a", "file:x", "file:y"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractFeatures(tt.message, tt.files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractFeatures = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifierPredict(t *testing.T) {
+	classifier := NewClassifier()
+	if classifier.Predict("修复", nil) != nil {
+		t.Error("未训练的模型应返回 nil")
+	}
+	classifier.Train(classifierExamples)
+
+	tests := []struct {
+		name     string
+		message  string
+		files    []string
+		category string
+	}{
+		{"中文标题", "修复会话崩溃", []string{"auth/token.go"}, "Bug修复"},
+		{"英文标题", "crash when input is empty", nil, "Bug修复"},
+		{"只有文件特征", "", []string{"docs/faq.md"}, "文档更新"},
+		{"文档标题", "更新接口文档", nil, "文档更新"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prediction := classifier.Predict(tt.message, tt.files)
+			if prediction.Category != tt.category {
+				t.Errorf("Category = %q, 期望 %q", prediction.Category, tt.category)
+			}
+			if prediction.Confidence <= 0.5 || prediction.Confidence > 1 {
+				t.Errorf("Confidence = %v", prediction.Confidence)
+			}
+			if len(prediction.Features) == 0 || len(prediction.Features) > 3 {
+				t.Errorf("Features = %q", prediction.Features)
+			}
+		})
+	}
+}
+
+func TestClassifierTrainIncremental(t *testing.T) {
+	classifier := NewClassifier()
+	classifier.Train(classifierExamples[:3])
+	classifier.Train(classifierExamples[3:])
+
+	if classifier.Documents != len(classifierExamples) {
+		t.Errorf("Documents = %d, 期望 %d", classifier.Documents, len(classifierExamples))
+	}
+	if classifier.Categories["Bug修复"].Documents != 3 || classifier.Categories["文档更新"].Documents != 3 {
+		t.Errorf("分类样本数 = %d/%d", classifier.Categories["Bug修复"].Documents, classifier.Categories["文档更新"].Documents)
+	}
+	for i := 1; i < len(classifier.Vocabulary); i++ {
+		if classifier.Vocabulary[i-1] >= classifier.Vocabulary[i] {
+			t.Fatalf("Vocabulary 未排序或有重复: %q", classifier.Vocabulary[i-1:i+1])
+		}
+	}
+}
+
+func TestClassifierEvaluate(t *testing.T) {
+	classifier := NewClassifier()
+	classifier.Train(classifierExamples)
+
+	evaluation := classifier.Evaluate([]*TrainingExample{
+		{Message: "修复登录问题", Category: "Bug修复"},
+		{Message: "更新文档", Files: []string{"docs/intro.md"}, Category: "文档更新"},
+		// 标注与内容不符，模型会预测为 Bug修复
+		{Message: "修复崩溃", Files: []string{"auth/login.go"}, Category: "文档更新"},
+	})
+
+	if evaluation.Total != 3 || evaluation.Correct != 2 {
+		t.Fatalf("Total/Correct = %d/%d, 期望 3/2", evaluation.Total, evaluation.Correct)
+	}
+	if evaluation.Accuracy < 0.66 || evaluation.Accuracy > 0.67 {
+		t.Errorf("Accuracy = %v", evaluation.Accuracy)
+	}
+
+	want := []CategoryMetrics{
+		{Name: "Bug修复", Support: 1, Predicted: 2, Correct: 1, Precision: 0.5, Recall: 1},
+		{Name: "文档更新", Support: 2, Predicted: 1, Correct: 1, Precision: 1, Recall: 0.5},
+	}
+	if len(evaluation.Categories) != len(want) {
+		t.Fatalf("Categories = %d, 期望 %d", len(evaluation.Categories), len(want))
+	}
+	for i, metrics := range evaluation.Categories {
+		if *metrics != want[i] {
+			t.Errorf("Categories[%d] = %+v, 期望 %+v", i, *metrics, want[i])
+		}
+	}
+}
+
+func TestClassifierSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	classifier := NewClassifier()
+	classifier.Train(classifierExamples)
+
+	filename := filepath.Join(dir, "model.json")
+	if err := classifier.Save(filename); err != nil {
+		t.Fatalf("Save 返回错误: %v", err)
+	}
+	loaded, err := LoadClassifier(filename)
+	if err != nil {
+		t.Fatalf("LoadClassifier 返回错误: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Predict("修复崩溃", nil), classifier.Predict("修复崩溃", nil)) {
+		t.Error("加载后的模型预测结果不同")
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"版本不支持", `{"version": 99, "categories": {"a": {}}}`, "版本"},
+		{"没有分类", `{"version": 1}`, "没有任何分类"},
+		{"不是JSON", `not json`, "解析"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(dir, "bad.json")
+			if err := os.WriteFile(filename, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadClassifier(filename); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, 期望包含 %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// runCommand 执行子命令，args 为子命令名之后的参数；不是子命令时返回 false
+func runCommand(name string, args []string) bool {
+	switch name {
+	case "categorize":
+		runCategorize(args)
+	case "classify":
+		runClassify(args)
+	case "okr-draft":
+		runOKRDraft(args)
+	case "templates":
+		runTemplates(args)
+	case "history":
+		runHistory(args)
+	default:
+		return false
+	}
+	return true
+}
+
+// runCategorize 查看提交的分类结果，-explain 时输出命中的规则及原因
+func runCategorize(args []string) {
+	fs := flag.NewFlagSet("categorize", flag.ExitOnError)
+	var (
+		explain    = fs.Bool("explain", false, "显示命中的规则及原因")
+		repoPath   = fs.String("repo", ".", "Git仓库路径")
+		configFile = fs.String("config", "", "配置文件路径，参考 config.example.json")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: git-report categorize [-explain] [-repo 路径] [-config 文件] <提交>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	// 允许选项写在提交之后，如 "categorize <hash> -explain"
+	var revs []string
+	for rest := fs.Args(); len(rest) > 0; rest = fs.Args() {
+		revs = append(revs, rest[0])
+		fs.Parse(rest[1:])
+	}
+
+	if len(revs) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+
+	parser := NewGitParser(*repoPath)
+	engine := config.RuleEngine()
+
+	for _, rev := range revs {
+		commit, err := parser.GetCommit(rev)
+		if err != nil {
+			log.Fatalf("获取提交失败: %v", err)
+		}
+
+		explanation := engine.Explain(commit)
+		fmt.Printf("%s %s\n", shortHash(commit.Hash, config.ReportSettings.ShortHashLength), commit.Message)
+		fmt.Printf("  分类: %s\n", strings.Join(explanation.Categories, ", "))
+
+		if !*explain {
+			continue
+		}
+
+		fmt.Printf("  来源: %s\n", categorySourceName(explanation.Source))
+		if len(explanation.Matches) == 0 {
+			fmt.Println("  没有规则命中，归入" + OtherCategory)
+		}
+		for _, match := range explanation.Matches {
+			fmt.Printf("  规则 %s -> %s\n", match.Rule, strings.Join(match.Categories, ", "))
+			for _, reason := range match.Reasons {
+				fmt.Printf("    - %s\n", reason)
+			}
+		}
+	}
+}
+
+// categorySourceName 返回分类来源的中文名称
+func categorySourceName(source string) string {
+	switch source {
+	case "trailer":
+		return "提交中的 Category trailer"
+	case "rules":
+		return "配置中的 rules"
+	case "builtin":
+		return "type_categories 和 categories 关键词"
+	case "classifier":
+		return "分类模型"
+	default:
+		return "默认分类"
+	}
+}
+
+// shortHash 截取短哈希
+func shortHash(hash string, length int) string {
+	if length > 0 && len(hash) > length {
+		return hash[:length]
+	}
+	return hash
+}
+
+// runClassify 训练或评估离线分类模型
+func runClassify(args []string) {
+	usage := "用法: git-report classify train|evaluate [选项]"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	action := args[0]
+	fs := flag.NewFlagSet("classify "+action, flag.ExitOnError)
+	var (
+		dataFile   = fs.String("data", "", "标注数据文件 (JSONL)，每行 {\"message\", \"files\", \"category\"} 或 {\"hash\", \"category\"}")
+		repoPath   = fs.String("repo", "", "Git仓库路径，使用其中带 Category trailer 的提交作为标注数据，并解析标注数据中的 hash")
+		since      = fs.String("since", "", "只使用该日期 (YYYY-MM-DD) 之后的提交，默认为全部历史")
+		modelFile  = fs.String("model", "", "模型文件路径，默认为配置中的 classifier_model")
+		configFile = fs.String("config", "", "配置文件路径，参考 config.example.json")
+		holdout    = fs.Float64("holdout", 0, "train 时留出该比例的样本用于评估，如 0.2")
+	)
+	fs.Parse(args[1:])
+
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	if *modelFile == "" {
+		*modelFile = config.ClassifierModel
+	}
+	if *modelFile == "" {
+		log.Fatalf("没有指定模型文件，请使用 -model 或配置 classifier_model")
+	}
+
+	examples, err := loadExamples(*dataFile, *repoPath, *since, config)
+	if err != nil {
+		log.Fatalf("加载标注数据失败: %v", err)
+	}
+	if len(examples) == 0 {
+		log.Fatalf("没有标注数据，请使用 -data 或 -repo 指定")
+	}
+
+	switch action {
+	case "train":
+		if *holdout < 0 || *holdout >= 1 {
+			log.Fatalf("-holdout 必须在0到1之间")
+		}
+		train, test := splitExamples(examples, *holdout)
+
+		classifier := NewClassifier()
+		classifier.Train(train)
+		if err := classifier.Save(*modelFile); err != nil {
+			log.Fatalf("%v", err)
+		}
+		fmt.Printf("已使用 %d 条样本训练 %d 个分类，模型已保存到: %s\n", len(train), len(classifier.Categories), *modelFile)
+
+		if len(test) > 0 {
+			fmt.Printf("\n留出的 %d 条样本上的评估结果:\n", len(test))
+			printEvaluation(classifier.Evaluate(test))
+		}
+	case "evaluate":
+		classifier, err := LoadClassifier(*modelFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		printEvaluation(classifier.Evaluate(examples))
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// runOKRDraft 根据一个季度的提交让AI生成OKR草稿
+func runOKRDraft(args []string) {
+	fs := flag.NewFlagSet("okr-draft", flag.ExitOnError)
+	var (
+		repoPath   = fs.String("repo", "", "Git仓库路径，多个路径用逗号分隔，也可以是工作区目录，默认为配置中的 default_repo_path")
+		date       = fs.String("date", "", "季度内的任意日期 (YYYY-MM-DD)，默认为今天")
+		author     = fs.String("author", "", "指定作者，默认为当前Git用户")
+		team       = fs.Bool("team", false, "团队模式：包含所有作者的提交")
+		provider   = fs.String("provider", "", "AI服务商名称，默认为配置中的 ai.provider")
+		attempts   = fs.Int("attempts", DefaultOKRDraftAttempts, "模型输出不符合 schema 时的最大尝试次数")
+		asJSON     = fs.Bool("json", false, "输出JSON而不是Markdown")
+		output     = fs.String("output", "", "输出文件路径，默认输出到控制台")
+		configFile = fs.String("config", "", "配置文件路径，参考 config.example.json")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: git-report okr-draft [-repo 路径] [-date 日期] [-author 作者] [-provider 服务商] [-json] [-output 文件]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	if *repoPath == "" {
+		*repoPath = config.DefaultRepoPath
+	}
+	if *repoPath == "" {
+		*repoPath = "."
+	}
+
+	targetDate, err := parseDate(*date)
+	if err != nil {
+		log.Fatalf("日期解析错误: %v", err)
+	}
+
+	repoPaths, err := DiscoverRepos(strings.Split(*repoPath, ","))
+	if err != nil {
+		log.Fatalf("查找仓库失败: %v", err)
+	}
+
+	generator := NewMultiRepoReportGenerator(repoPaths, *author, config)
+	generator.SetTeamMode(*team)
+	if config.RosterFile != "" {
+		roster, err := LoadRoster(config.RosterFile)
+		if err != nil {
+			log.Fatalf("加载花名册失败: %v", err)
+		}
+		generator.SetRoster(roster)
+	}
+
+	report, err := generator.GenerateQuarterlyReport(targetDate)
+	if err != nil {
+		log.Fatalf("生成报告失败: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.AI.Deadline())
+	defer cancel()
+	result, err := DraftOKR(ctx, config, report, *provider, *attempts)
+	if err != nil {
+		log.Fatalf("生成OKR草稿失败: %v", err)
+	}
+
+	var content string
+	if *asJSON {
+		data, err := json.MarshalIndent(result.Draft, "", "  ")
+		if err != nil {
+			log.Fatalf("编码OKR草稿失败: %v", err)
+		}
+		content = string(data) + "\n"
+	} else {
+		content = result.Draft.Markdown(config.ReportSettings.ShortHashLength)
+	}
+
+	if *output == "" {
+		fmt.Print(content)
+		return
+	}
+	if err := os.WriteFile(*output, []byte(content), 0644); err != nil {
+		log.Fatalf("写入文件失败: %v", err)
+	}
+	fmt.Printf("OKR草稿已保存到: %s（模型调用 %d 次）\n", *output, result.Attempts)
+}
+
+// loadExamples 汇总标注文件和仓库中带 Category trailer 的提交，只给出 hash 的样本从仓库中补全
+func loadExamples(dataFile, repoPath, since string, config *Config) ([]*TrainingExample, error) {
+	var examples []*TrainingExample
+
+	if dataFile != "" {
+		data, err := LoadTrainingData(dataFile)
+		if err != nil {
+			return nil, err
+		}
+		examples = append(examples, data...)
+	}
+
+	fromRepo := repoPath != ""
+	if repoPath == "" {
+		repoPath = "."
+	}
+	parser := NewGitParser(repoPath)
+
+	corrected := make(map[string]bool)
+	for _, example := range examples {
+		if example.Hash == "" {
+			continue
+		}
+		if example.Message == "" {
+			commit, err := parser.GetCommit(example.Hash)
+			if err != nil {
+				return nil, err
+			}
+			example.Hash = commit.Hash
+			example.Message = commit.Message
+			example.Files = commit.Files
+		}
+		corrected[example.Hash] = true
+	}
+
+	if fromRepo {
+		sinceDate := time.Unix(0, 0)
+		if since != "" {
+			var err error
+			if sinceDate, err = parseDate(since); err != nil {
+				return nil, fmt.Errorf("日期解析错误: %v", err)
+			}
+		}
+		commits, err := parser.GetCommits(sinceDate, time.Now(), "")
+		if err != nil {
+			return nil, err
+		}
+
+		// 标注文件中的纠正优先于提交中的 trailer
+		for _, example := range ExamplesFromCommits(commits, config.Categories) {
+			if !corrected[example.Hash] {
+				examples = append(examples, example)
+			}
+		}
+	}
+
+	return examples, nil
+}
+
+// splitExamples 按固定间隔留出部分样本用于评估，结果可复现
+func splitExamples(examples []*TrainingExample, holdout float64) ([]*TrainingExample, []*TrainingExample) {
+	if holdout <= 0 {
+		return examples, nil
+	}
+
+	step := int(1 / holdout)
+	if step < 2 {
+		step = 2
+	}
+
+	var train, test []*TrainingExample
+	for i, example := range examples {
+		if i%step == step-1 {
+			test = append(test, example)
+		} else {
+			train = append(train, example)
+		}
+	}
+	return train, test
+}
+
+// printEvaluation 输出各分类的精确率和召回率
+func printEvaluation(evaluation *ClassifierEvaluation) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "分类\t样本数\t预测数\t正确数\t精确率\t召回率")
+	for _, m := range evaluation.Categories {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%.1f%%\t%.1f%%\n", m.Name, m.Support, m.Predicted, m.Correct, m.Precision*100, m.Recall*100)
+	}
+	w.Flush()
+	fmt.Printf("\n准确率: %.1f%% (%d/%d)\n", evaluation.Accuracy*100, evaluation.Correct, evaluation.Total)
+}
+
+// runTemplates 列出内置报告模板，或输出指定模板的内容以便复制修改
+func runTemplates(args []string) {
+	usage := "用法: git-report templates list|show <模板名>"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "list":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, tmpl := range BuiltinTemplates() {
+			description := tmpl.Description
+			if tmpl.Default {
+				description += "（默认）"
+			}
+			fmt.Fprintf(w, "%s\t%s\n", tmpl.Name, description)
+		}
+		w.Flush()
+	case "show":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		tmpl, ok := LookupBuiltinTemplate(args[1])
+		if !ok {
+			log.Fatalf("未知的模板: %s，可用的内置模板: %s", args[1], builtinTemplateNames())
+		}
+		fmt.Print(tmpl.content)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// runHistory 查看报告历史：列出报告、显示某个修订或比较两个修订
+func runHistory(args []string) {
+	usage := "用法: git-report history list|show|diff [选项]"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	action := args[0]
+	fs := flag.NewFlagSet("history "+action, flag.ExitOnError)
+	var (
+		author     = fs.String("author", "", "list: 只列出该作者的报告")
+		reportType = fs.String("type", "", "list: 只列出该类型的报告，如 weekly")
+		since      = fs.String("since", "", "list: 报告周期与该日期 (YYYY-MM-DD) 之后有交集")
+		until      = fs.String("until", "", "list: 报告周期与该日期 (YYYY-MM-DD) 之前有交集")
+		limit      = fs.Int("limit", 20, "list: 最多列出的报告数，0 表示不限")
+		revision   = fs.Int("revision", 0, "show: 修订号，默认为最新修订")
+		asJSON     = fs.Bool("json", false, "show: 输出结构化报告 (JSON) 而不是报告内容")
+		from       = fs.Int("from", 0, "diff: 起始修订号，默认为第一个修订")
+		to         = fs.Int("to", 0, "diff: 目标修订号，默认为最新修订")
+		configFile = fs.String("config", "", "配置文件路径，参考 config.example.json")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), usage)
+		fmt.Fprintln(fs.Output(), "  history list [-author 作者] [-type 类型] [-since 日期] [-until 日期] [-limit 数量]")
+		fmt.Fprintln(fs.Output(), "  history show <编号> [-revision 修订号] [-json]")
+		fmt.Fprintln(fs.Output(), "  history diff <编号> [-from 修订号] [-to 修订号]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+
+	// 允许选项写在编号之后，如 "history show 12 -revision 2"
+	var positional []string
+	for rest := fs.Args(); len(rest) > 0; rest = fs.Args() {
+		positional = append(positional, rest[0])
+		fs.Parse(rest[1:])
+	}
+
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	history := config.History()
+	if history == nil {
+		log.Fatalf("没有配置报告历史，请在配置文件中设置 history_file 或环境变量 %s", EnvHistoryFile)
+	}
+
+	switch action {
+	case "list":
+		filter := HistoryFilter{Author: *author, Type: *reportType, Limit: *limit}
+		if *since != "" {
+			if filter.Since, err = parseDate(*since); err != nil {
+				log.Fatalf("日期解析错误: %v", err)
+			}
+		}
+		if *until != "" {
+			if filter.Until, err = parseDate(*until); err != nil {
+				log.Fatalf("日期解析错误: %v", err)
+			}
+			filter.Until = filter.Until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+
+		entries, err := history.List(filter)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if len(entries) == 0 {
+			fmt.Println("没有找到报告")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "编号\t类型\t作者\t周期\t提交\t修订\t来源\t生成时间")
+		for _, entry := range entries {
+			name := entry.Author
+			if entry.Team {
+				name = "团队"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n", entry.ID, reportTypeName(entry.Type), name, entry.Period,
+				entry.Commits, entry.RevisionCount, entry.Source, entry.CreatedAt.Local().Format(config.TimeFormat))
+		}
+		w.Flush()
+
+	case "show", "diff":
+		if len(positional) != 1 {
+			fs.Usage()
+			os.Exit(2)
+		}
+		id, err := strconv.ParseUint(positional[0], 10, 64)
+		if err != nil {
+			log.Fatalf("无效的报告编号: %s", positional[0])
+		}
+		record, err := history.Get(id)
+		if err != nil {
+			log.Fatalf("读取报告 %d 失败: %v", id, err)
+		}
+
+		if action == "diff" {
+			diff, _, _, err := record.Diff(*from, *to)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			fmt.Print(diff)
+			return
+		}
+
+		if *asJSON {
+			data, err := json.MarshalIndent(record.Report, "", "  ")
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+		rev, err := record.Revision(*revision)
+		if err != nil {
+			log.Fatalf("报告 %d 没有修订 %d", id, *revision)
+		}
+		fmt.Fprintf(os.Stderr, "报告 #%d 修订 %d/%d（%s）\n", id, rev.Number, record.RevisionCount, revisionKindName(rev.Kind))
+		fmt.Print(rev.Content)
+
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// revisionKindName 返回修订类型的中文名称
+func revisionKindName(kind string) string {
+	switch kind {
+	case RevisionGenerated:
+		return "生成"
+	case RevisionOptimized:
+		return "AI 优化"
+	case RevisionEdited:
+		return "手动编辑"
+	default:
+		return kind
+	}
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Config 应用配置，对应 config.example.json
+type Config struct {
+	DefaultAuthor           string                `json:"default_author"`            // 默认作者
+	DefaultRepoPath         string                `json:"default_repo_path"`         // 默认仓库路径
+	DefaultTemplate         string                `json:"default_template"`          // 默认模板，内置模板名或模板文件路径
+	OutputDirectory         string                `json:"output_directory"`          // 报告输出目录
+	RosterFile              string                `json:"roster_file"`               // 团队花名册文件
+	DateFormat              string                `json:"date_format"`               // 日期格式
+	TimeFormat              string                `json:"time_format"`               // 时间格式
+	Categories              CategoryRules         `json:"categories"`                // 提交分类关键词（按声明顺序匹配）
+	TypeCategories          map[string]string     `json:"type_categories"`           // Conventional Commits 类型到分类的映射
+	Rules                   []*CategorizationRule `json:"rules"`                     // 自定义分类规则，优先于 type_categories 和关键词
+	ClassifierModel         string                `json:"classifier_model"`          // 分类模型文件，规则都未命中时使用
+	ClassifierMinConfidence float64               `json:"classifier_min_confidence"` // 采用分类模型预测结果的最低置信度
+	FileTypeMapping         map[string]string     `json:"file_type_mapping"`         // 扩展名到文件类型的映射，键为逗号分隔的扩展名
+	ReportSettings          ReportSettings        `json:"report_settings"`           // 报告设置
+	OKRFile                 string                `json:"okr_file"`                  // 默认的OKR定义文件
+	FilesDirectory          string                `json:"files_directory"`           // 接口请求可以按文件名引用的花名册和OKR文件所在目录
+	AI                      AISettings            `json:"ai"`                        // AI优化服务商配置
+	Redaction               RedactionSettings     `json:"redaction"`                 // 发送给AI前的脱敏配置
+	PromptsDirectory        string                `json:"prompts_directory"`         // 自定义提示词模板目录，同名模板覆盖内置模板
+	NarrativeLanguage       string                `json:"narrative_language"`        // 叙述性总结的语言: zh, en
+	Delivery                DeliverySettings      `json:"delivery"`                  // 报告推送渠道
+	Scheduler               SchedulerSettings     `json:"scheduler"`                 // 定时生成和推送报告
+	HistoryFile             string                `json:"history_file"`              // 报告历史文件，为空时不保存生成的报告
+
+	fileTypes  map[string]string // 展开后的扩展名映射
+	ruleEngine *RuleEngine       // 编译后的分类规则
+	classifier *Classifier       // 加载的分类模型
+	redactor   *Redactor         // 编译后的脱敏器
+	prompts    *PromptLibrary    // 加载的提示词模板
+	history    *HistoryStore     // 报告历史
+}
+
+// ReportSettings 报告设置
+type ReportSettings struct {
+	MaxTopFiles              int  `json:"max_top_files"`              // 热点文件数量
+	IncludeFileChanges       bool `json:"include_file_changes"`       // 是否包含文件变更列表
+	IncludeCodeStats         bool `json:"include_code_stats"`         // 是否包含代码行数统计
+	IncludeDailyDistribution bool `json:"include_daily_distribution"` // 是否包含每日提交分布
+	ShortHashLength          int  `json:"short_hash_length"`          // 短哈希长度
+}
+
+// CategoryRule 分类及其关键词
+type CategoryRule struct {
+	Name     string
+	Keywords []string
+}
+
+// CategoryRules 有序的分类规则，JSON中以对象形式书写，保留声明顺序
+type CategoryRules []CategoryRule
+
+// OtherCategory 无法归入任何分类的提交
+const OtherCategory = "其他"
+
+// 配置相关的环境变量，优先级高于配置文件
+const (
+	EnvConfigFile      = "GIT_REPORT_CONFIG"
+	EnvDefaultAuthor   = "GIT_REPORT_AUTHOR"
+	EnvDefaultRepoPath = "GIT_REPORT_REPO"
+	EnvDefaultTemplate = "GIT_REPORT_TEMPLATE"
+	EnvOutputDirectory = "GIT_REPORT_OUTPUT_DIR"
+	EnvMaxTopFiles     = "GIT_REPORT_MAX_TOP_FILES"
+	EnvShortHashLength = "GIT_REPORT_SHORT_HASH_LENGTH"
+	EnvClassifierModel = "GIT_REPORT_CLASSIFIER_MODEL"
+	EnvHistoryFile     = "GIT_REPORT_HISTORY_FILE"
+)
+
+// DefaultConfig 返回内置默认配置
+func DefaultConfig() *Config {
+	return &Config{
+		DateFormat: "2006年01月02日",
+		TimeFormat: "2006-01-02 15:04:05",
+		Categories: CategoryRules{
+			{Name: "功能开发", Keywords: []string{"feat", "feature", "add", "新增", "功能"}},
+			{Name: "Bug修复", Keywords: []string{"fix", "bugfix", "hotfix", "bug", "修复", "修正"}},
+			{Name: "代码重构", Keywords: []string{"refactor", "重构"}},
+			{Name: "文档更新", Keywords: []string{"doc", "readme", "文档"}},
+			{Name: "测试相关", Keywords: []string{"test", "测试"}},
+			{Name: "配置修改", Keywords: []string{"config", "configuration", "配置"}},
+		},
+		TypeCategories: map[string]string{
+			"feat":     "功能开发",
+			"fix":      "Bug修复",
+			"refactor": "代码重构",
+			"perf":     "代码重构",
+			"style":    "代码重构",
+			"docs":     "文档更新",
+			"test":     "测试相关",
+			"build":    "配置修改",
+			"ci":       "配置修改",
+		},
+		FileTypeMapping: map[string]string{
+			"js,jsx,ts,tsx":     "JavaScript/TypeScript",
+			"go":                "Go",
+			"py":                "Python",
+			"java":              "Java",
+			"cpp,cc,cxx,c":      "C/C++",
+			"html,htm":          "HTML",
+			"css,scss,sass":     "CSS",
+			"md,markdown":       "Markdown",
+			"json,yaml,yml,xml": "配置文件",
+		},
+		ClassifierMinConfidence: 0.6,
+		AI:                      DefaultAISettings(),
+		Redaction:               RedactionSettings{Enabled: true},
+		Scheduler:               SchedulerSettings{Enabled: true},
+		NarrativeLanguage:       NarrativeChinese,
+		ReportSettings: ReportSettings{
+			MaxTopFiles:              5,
+			IncludeFileChanges:       true,
+			IncludeCodeStats:         true,
+			IncludeDailyDistribution: true,
+			ShortHashLength:          8,
+		},
+	}
+}
+
+// LoadConfig 加载配置文件并应用环境变量覆盖
+// filename 为空时读取 GIT_REPORT_CONFIG 环境变量，仍为空则使用默认配置
+func LoadConfig(filename string) (*Config, error) {
+	if filename == "" {
+		filename = os.Getenv(EnvConfigFile)
+	}
+
+	config := DefaultConfig()
+
+	if filename != "" {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("读取配置文件失败: %v", err)
+		}
+
+		// 映射类配置整体替换默认值，而不是与默认值合并
+		config.FileTypeMapping = nil
+		config.TypeCategories = nil
+
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(config); err != nil {
+			return nil, fmt.Errorf("解析配置文件 %s 失败: %v", filename, err)
+		}
+
+		if config.FileTypeMapping == nil {
+			config.FileTypeMapping = DefaultConfig().FileTypeMapping
+		}
+		if config.TypeCategories == nil {
+			config.TypeCategories = DefaultConfig().TypeCategories
+		}
+	}
+
+	if err := config.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	if config.ClassifierModel != "" {
+		classifier, err := LoadClassifier(config.ClassifierModel)
+		if err != nil {
+			return nil, err
+		}
+		config.classifier = classifier
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// applyEnv 应用环境变量覆盖
+func (c *Config) applyEnv() error {
+	if v := os.Getenv(EnvDefaultAuthor); v != "" {
+		c.DefaultAuthor = v
+	}
+	if v := os.Getenv(EnvDefaultRepoPath); v != "" {
+		c.DefaultRepoPath = v
+	}
+	if v := os.Getenv(EnvDefaultTemplate); v != "" {
+		c.DefaultTemplate = v
+	}
+	if v := os.Getenv(EnvOutputDirectory); v != "" {
+		c.OutputDirectory = v
+	}
+	if v := os.Getenv(EnvClassifierModel); v != "" {
+		c.ClassifierModel = v
+	}
+	if v := os.Getenv(EnvHistoryFile); v != "" {
+		c.HistoryFile = v
+	}
+	if v := os.Getenv(EnvMaxTopFiles); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("环境变量 %s 不是有效的整数: %s", EnvMaxTopFiles, v)
+		}
+		c.ReportSettings.MaxTopFiles = n
+	}
+	if v := os.Getenv(EnvShortHashLength); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("环境变量 %s 不是有效的整数: %s", EnvShortHashLength, v)
+		}
+		c.ReportSettings.ShortHashLength = n
+	}
+	c.AI.applyEnv()
+	return nil
+}
+
+// Validate 校验配置，返回所有发现的问题
+func (c *Config) Validate() error {
+	var problems []string
+
+	if c.DateFormat == "" {
+		problems = append(problems, "date_format 不能为空")
+	}
+	if c.TimeFormat == "" {
+		problems = append(problems, "time_format 不能为空")
+	}
+	if c.ReportSettings.MaxTopFiles < 0 {
+		problems = append(problems, "report_settings.max_top_files 不能为负数")
+	}
+	if c.ClassifierMinConfidence < 0 || c.ClassifierMinConfidence > 1 {
+		problems = append(problems, "classifier_min_confidence 必须在0到1之间")
+	}
+	if c.NarrativeLanguage != "" && c.NarrativeLanguage != NarrativeChinese && c.NarrativeLanguage != NarrativeEnglish {
+		problems = append(problems, fmt.Sprintf("narrative_language 只支持 zh 和 en，当前为 %q", c.NarrativeLanguage))
+	}
+	if n := c.ReportSettings.ShortHashLength; n < 4 || n > 40 {
+		problems = append(problems, fmt.Sprintf("report_settings.short_hash_length 必须在4到40之间，当前为%d", n))
+	}
+
+	seen := make(map[string]bool)
+	for _, rule := range c.Categories {
+		if strings.TrimSpace(rule.Name) == "" {
+			problems = append(problems, "categories 中存在空的分类名")
+			continue
+		}
+		if seen[rule.Name] {
+			problems = append(problems, fmt.Sprintf("categories 中分类重复: %s", rule.Name))
+		}
+		seen[rule.Name] = true
+	}
+
+	// 分类需要通过关键词、类型映射或自定义规则中的至少一种方式归入提交
+	referenced := make(map[string]bool)
+	for commitType, category := range c.TypeCategories {
+		referenced[category] = true
+		if !seen[category] && category != OtherCategory {
+			problems = append(problems, fmt.Sprintf("type_categories 中类型 %s 映射到未定义的分类 %s", commitType, category))
+		}
+	}
+
+	for i, rule := range c.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("rule-%d", i+1)
+		}
+		for _, category := range rule.Categories {
+			referenced[category] = true
+			if !seen[category] && category != OtherCategory {
+				problems = append(problems, fmt.Sprintf("rules 中规则 %s 引用了未定义的分类 %s", name, category))
+			}
+		}
+	}
+
+	for _, rule := range c.Categories {
+		if len(rule.Keywords) == 0 && !referenced[rule.Name] {
+			problems = append(problems, fmt.Sprintf("分类 %s 没有关键词，也没有被 type_categories 或 rules 引用", rule.Name))
+		}
+	}
+
+	engine, err := NewRuleEngine(c)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	c.ruleEngine = engine
+
+	problems = append(problems, c.indexFileTypes()...)
+	problems = append(problems, c.AI.validate()...)
+	problems = append(problems, validateRedaction(c.Redaction)...)
+	problems = append(problems, c.Delivery.validate()...)
+	problems = append(problems, c.Scheduler.validate(c)...)
+
+	c.history = nil
+	if c.HistoryFile != "" {
+		c.history = NewHistoryStore(c.HistoryFile)
+	}
+
+	prompts, err := LoadPromptLibrary(c.PromptsDirectory)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	c.prompts = prompts
+
+	if len(problems) > 0 {
+		return fmt.Errorf("配置校验失败: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// indexFileTypes 展开 file_type_mapping 中逗号分隔的扩展名
+func (c *Config) indexFileTypes() []string {
+	var problems []string
+	c.fileTypes = make(map[string]string)
+	for exts, name := range c.FileTypeMapping {
+		if name == "" {
+			problems = append(problems, fmt.Sprintf("file_type_mapping 中 %s 的类型名为空", exts))
+			continue
+		}
+		for _, ext := range strings.Split(exts, ",") {
+			ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+			if ext == "" {
+				continue
+			}
+			if existing, ok := c.fileTypes[ext]; ok && existing != name {
+				problems = append(problems, fmt.Sprintf("file_type_mapping 中扩展名 %s 同时映射到 %s 和 %s", ext, existing, name))
+			}
+			c.fileTypes[ext] = name
+		}
+	}
+	return problems
+}
+
+// FileTypeName 返回扩展名对应的文件类型，未配置时返回扩展名本身
+func (c *Config) FileTypeName(ext string) string {
+	if c.fileTypes == nil {
+		c.indexFileTypes()
+	}
+	if name, ok := c.fileTypes[ext]; ok {
+		return name
+	}
+	return ext
+}
+
+// ErrFileNotAllowed 接口请求引用了未配置的文件
+var ErrFileNotAllowed = errors.New("file is not allowed")
+
+// RequestFile 解析接口请求中引用的文件，避免通过接口读取服务器上的任意文件
+// name 为空时使用 configured；与 configured 相同，或是 files_directory 中的文件名时允许，其余返回 ErrFileNotAllowed
+func (c *Config) RequestFile(name, configured string) (string, error) {
+	if name == "" || name == configured {
+		return configured, nil
+	}
+	if c.FilesDirectory != "" && name == filepath.Base(name) && name != "." && name != ".." {
+		return filepath.Join(c.FilesDirectory, name), nil
+	}
+	return "", fmt.Errorf("%w: %s, use the configured file or a file name in files_directory", ErrFileNotAllowed, name)
+}
+
+// RuleEngine 返回编译后的分类规则引擎
+// 配置未经校验或规则无效时只使用内置规则
+func (c *Config) RuleEngine() *RuleEngine {
+	if c.ruleEngine == nil {
+		engine, err := NewRuleEngine(c)
+		if err != nil {
+			rules := c.Rules
+			c.Rules = nil
+			engine, _ = NewRuleEngine(c)
+			c.Rules = rules
+		}
+		c.ruleEngine = engine
+	}
+	return c.ruleEngine
+}
+
+// Redactor 返回发送给AI前使用的脱敏器
+// 默认作者会被替换；配置了花名册时，成员名和别名也会被替换
+func (c *Config) Redactor() (*Redactor, error) {
+	if c.redactor != nil {
+		return c.redactor, nil
+	}
+
+	terms := []string{c.DefaultAuthor}
+	if c.RosterFile != "" {
+		roster, err := LoadRoster(c.RosterFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range roster.Members {
+			terms = append(terms, member.Name)
+			terms = append(terms, member.Aliases...)
+		}
+	}
+
+	redactor, err := NewRedactor(c.Redaction, terms)
+	if err != nil {
+		return nil, err
+	}
+	c.redactor = redactor
+	return redactor, nil
+}
+
+// History 返回报告历史，未配置 history_file 时返回 nil
+func (c *Config) History() *HistoryStore {
+	return c.history
+}
+
+// PromptLibrary 返回AI优化使用的提示词模板库
+func (c *Config) PromptLibrary() (*PromptLibrary, error) {
+	if c.prompts != nil {
+		return c.prompts, nil
+	}
+
+	prompts, err := LoadPromptLibrary(c.PromptsDirectory)
+	if err != nil {
+		return nil, err
+	}
+	c.prompts = prompts
+	return prompts, nil
+}
+
+// UnmarshalJSON 按声明顺序解析分类对象
+func (r *CategoryRules) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("categories 必须是对象")
+	}
+
+	rules := CategoryRules{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		name := token.(string)
+
+		var keywords []string
+		if err := decoder.Decode(&keywords); err != nil {
+			return fmt.Errorf("分类 %s 的关键词必须是字符串数组", name)
+		}
+		rules = append(rules, CategoryRule{Name: name, Keywords: keywords})
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+
+	*r = rules
+	return nil
+}
+
+// MarshalJSON 按声明顺序输出分类对象
+func (r CategoryRules) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, rule := range r {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(rule.Name)
+		keywords, err := json.Marshal(rule.Keywords)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(keywords)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// conventionalHeaderRegex 匹配 Conventional Commits 标题，如 "feat(api)!: add endpoint"
+var conventionalHeaderRegex = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^()]*)\))?(!)?: +(\S.*)$`)
+
+// parseConventionalCommit 解析提交标题和正文中的 Conventional Commits 信息
+// 标题不符合规范时不修改提交，Type 保持为空
+func parseConventionalCommit(commit *GitCommit) {
+	matches := conventionalHeaderRegex.FindStringSubmatch(strings.TrimSpace(commit.Message))
+	if matches == nil {
+		return
+	}
+
+	commit.Type = strings.ToLower(matches[1])
+	commit.Scope = strings.TrimSpace(matches[2])
+	commit.Breaking = matches[3] == "!"
+	commit.Description = matches[4]
+
+	if note := parseBreakingNote(commit.Body); note != "" {
+		commit.Breaking = true
+		commit.BreakingNote = note
+	} else if commit.Breaking {
+		// 只有 ! 标记时以描述作为破坏性变更说明
+		commit.BreakingNote = commit.Description
+	}
+}
+
+// parseBreakingNote 提取 "BREAKING CHANGE:" 或 "BREAKING-CHANGE:" 脚注的内容，直到段落结束
+func parseBreakingNote(body string) string {
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		var rest string
+		var ok bool
+		if rest, ok = strings.CutPrefix(line, "BREAKING CHANGE:"); !ok {
+			if rest, ok = strings.CutPrefix(line, "BREAKING-CHANGE:"); !ok {
+				continue
+			}
+		}
+
+		note := []string{strings.TrimSpace(rest)}
+		for _, next := range lines[i+1:] {
+			if strings.TrimSpace(next) == "" {
+				break
+			}
+			// 遇到下一个脚注时结束
+			if key, _, found := strings.Cut(next, ":"); found && isTrailerKey(key) {
+				break
+			}
+			note = append(note, strings.TrimSpace(next))
+		}
+		return strings.TrimSpace(strings.Join(note, " "))
+	}
+	return ""
+}
+
+// groupByScope 按 Conventional Commits 的 scope 分组，没有 scope 的提交不参与分组
+func groupByScope(commits []*GitCommit) map[string][]*GitCommit {
+	scopes := make(map[string][]*GitCommit)
+	for _, commit := range commits {
+		if commit.Scope != "" {
+			scopes[commit.Scope] = append(scopes[commit.Scope], commit)
+		}
+	}
+	return scopes
+}
+
+// collectBreakingChanges 返回包含破坏性变更的提交
+func collectBreakingChanges(commits []*GitCommit) []*GitCommit {
+	var breaking []*GitCommit
+	for _, commit := range commits {
+		if commit.Breaking {
+			breaking = append(breaking, commit)
+		}
+	}
+	return breaking
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule 解析后的 cron 表达式，字段依次为分、时、日、月、星期
+type CronSchedule struct {
+	spec    string
+	minute  uint64
+	hour    uint64
+	dom     uint64
+	month   uint64
+	dow     uint64
+	domStar bool // 日为 *，此时只按星期匹配
+	dowStar bool // 星期为 *，此时只按日匹配
+}
+
+// cronField 一个字段的取值范围和可用的英文缩写
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronMinute = cronField{name: "分", min: 0, max: 59}
+	cronHour   = cronField{name: "时", min: 0, max: 23}
+	cronDom    = cronField{name: "日", min: 1, max: 31}
+	cronMonth  = cronField{name: "月", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 星期中 0 和 7 都表示周日
+	cronDow = cronField{name: "星期", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// cronMacros 常用的简写
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron 解析标准的 5 字段 cron 表达式，如 "30 17 * * FRI" 表示每周五 17:30
+// 支持 *、列表、范围、步长、月份和星期的英文缩写，以及 @daily、@weekly 等简写
+// 日和星期都不是 * 时，满足其中之一即可，与常见的 cron 实现一致
+func ParseCron(spec string) (*CronSchedule, error) {
+	expr := strings.TrimSpace(spec)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron 表达式 %q 应包含 5 个字段（分 时 日 月 星期）", spec)
+	}
+
+	c := &CronSchedule{spec: spec, domStar: fields[2] == "*" || fields[2] == "?", dowStar: fields[4] == "*" || fields[4] == "?"}
+	var err error
+	for i, target := range []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&c.minute, cronMinute},
+		{&c.hour, cronHour},
+		{&c.dom, cronDom},
+		{&c.month, cronMonth},
+		{&c.dow, cronDow},
+	} {
+		if *target.bits, err = parseCronField(fields[i], target.field); err != nil {
+			return nil, fmt.Errorf("cron 表达式 %q 无效: %v", spec, err)
+		}
+	}
+
+	// 7 与 0 同为周日
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+
+	return c, nil
+}
+
+// parseCronField 解析一个字段，返回取值的位图
+func parseCronField(expr string, field cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepExpr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s字段的步长 %q 无效", field.name, stepExpr)
+			}
+			step = n
+		}
+
+		var low, high int
+		switch {
+		case rangeExpr == "*" || rangeExpr == "?":
+			low, high = field.min, field.max
+		case strings.Contains(rangeExpr, "-"):
+			from, to, _ := strings.Cut(rangeExpr, "-")
+			var err error
+			if low, err = cronValue(from, field); err != nil {
+				return 0, err
+			}
+			if high, err = cronValue(to, field); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("%s字段的范围 %q 无效", field.name, rangeExpr)
+			}
+		default:
+			var err error
+			if low, err = cronValue(rangeExpr, field); err != nil {
+				return 0, err
+			}
+			high = low
+			// "5/15" 表示从 5 开始每 15 个单位
+			if hasStep {
+				high = field.max
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// cronValue 解析单个取值，支持英文缩写
+func cronValue(expr string, field cronField) (int, error) {
+	if v, ok := field.names[strings.ToLower(expr)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(expr)
+	if err != nil || v < field.min || v > field.max {
+		return 0, fmt.Errorf("%s字段的值 %q 超出范围 %d-%d", field.name, expr, field.min, field.max)
+	}
+	return v, nil
+}
+
+// String 返回原始表达式
+func (c *CronSchedule) String() string {
+	return c.spec
+}
+
+// Next 返回 t 之后的下一次运行时间，按 t 所在的时区计算；五年内没有匹配的时间时返回零值
+// 夏令时跳过的时刻当天不会运行
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// matchDay 判断日期是否匹配日和星期字段
+func (c *CronSchedule) matchDay(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,639 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DeliveryMessage 推送的消息，Markdown 为渲染后的报告
+// HTML 只用于邮件，为空时邮件只有纯文本正文；作者、周期等用于渲染邮件主题
+type DeliveryMessage struct {
+	Title    string
+	Markdown string
+	HTML     string
+	Author   string
+	Period   string
+	Type     string
+	Date     string
+}
+
+// Channel 报告推送渠道
+type Channel interface {
+	// Name 渠道名称，即配置中的键
+	Name() string
+	// Send 推送消息，超出平台长度限制时拆分为多条，返回实际发送的条数
+	Send(ctx context.Context, msg *DeliveryMessage) (int, error)
+}
+
+// DeliverySettings 报告推送配置
+type DeliverySettings struct {
+	Channels map[string]*ChannelConfig `json:"channels"` // 推送渠道，名称为内置类型时可省略 type
+	SMTP     SMTPSettings              `json:"smtp"`     // 邮件渠道使用的 SMTP 服务器
+}
+
+// ChannelConfig 单个推送渠道的配置
+type ChannelConfig struct {
+	Type           string   `json:"type"`            // dingtalk, feishu, wecom, slack, email
+	WebhookURL     string   `json:"webhook_url"`     // 机器人 Webhook 地址，建议使用 webhook_url_env
+	WebhookURLEnv  string   `json:"webhook_url_env"` // 读取 Webhook 地址的环境变量
+	Secret         string   `json:"secret"`          // 加签密钥，钉钉和飞书机器人开启签名校验时填写
+	SecretEnv      string   `json:"secret_env"`      // 读取加签密钥的环境变量
+	MaxBytes       int      `json:"max_bytes"`       // 单条消息的最大字节数，超出时拆分，0 表示使用平台限制
+	TimeoutSeconds int      `json:"timeout_seconds"` // 单次请求超时时间
+	To             []string `json:"to"`              // 邮件收件人
+	Cc             []string `json:"cc"`              // 邮件抄送
+	Bcc            []string `json:"bcc"`             // 邮件密送
+	Subject        string   `json:"subject"`         // 邮件主题模板，如 {{.Author}} 周报 {{.Period}}
+}
+
+// DeliveryResult 推送到一个渠道的结果
+type DeliveryResult struct {
+	Channel string `json:"channel"`
+	Type    string `json:"type"`
+	Parts   int    `json:"parts"`           // 发送的消息条数
+	Error   string `json:"error,omitempty"` // 推送失败的原因
+}
+
+// 内置的推送渠道类型
+const (
+	ChannelDingTalk = "dingtalk"
+	ChannelFeishu   = "feishu"
+	ChannelWeCom    = "wecom"
+	ChannelSlack    = "slack"
+)
+
+// channelMaxBytes 各平台单条消息的长度上限，略低于官方限制，为分段标记和 JSON 转义留出余量
+var channelMaxBytes = map[string]int{
+	ChannelDingTalk: 18000, // 钉钉 markdown 消息 20000 字节
+	ChannelFeishu:   15000, // 飞书请求体 20KB，卡片结构另有开销
+	ChannelWeCom:    4000,  // 企业微信 markdown 内容 4096 字节
+	ChannelSlack:    3800,  // Slack 建议单条消息不超过 4000 字符
+}
+
+// 未配置时的默认请求超时
+const defaultChannelTimeoutSeconds = 10
+
+// 最小的单条消息长度，过小的 max_bytes 会把报告拆得过碎
+const minChannelMaxBytes = 500
+
+// 分段标记中标题的最大字节数，过长时截断，避免标记占满单条消息
+const maxMarkerTitleBytes = 120
+
+// splitMessage 每段的最小字节数，限制过小或为负时按此拆分
+const minSplitBytes = 64
+
+// NewChannel 按名称创建推送渠道
+func (s *DeliverySettings) NewChannel(name string) (Channel, error) {
+	cfg, err := s.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Type == ChannelEmail {
+		return NewEmailChannel(name, cfg, &s.SMTP)
+	}
+	if cfg.WebhookURL == "" {
+		if cfg.WebhookURLEnv != "" {
+			return nil, fmt.Errorf("推送渠道 %s 的 Webhook 地址未配置，请设置环境变量 %s", name, cfg.WebhookURLEnv)
+		}
+		return nil, fmt.Errorf("推送渠道 %s 的 Webhook 地址未配置", name)
+	}
+
+	base := webhookChannel{
+		name:   name,
+		config: cfg,
+		client: &http.Client{Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second},
+	}
+	switch cfg.Type {
+	case ChannelDingTalk:
+		return &DingTalkChannel{base}, nil
+	case ChannelFeishu:
+		return &FeishuChannel{base}, nil
+	case ChannelWeCom:
+		return &WeComChannel{base}, nil
+	default:
+		return &SlackChannel{base}, nil
+	}
+}
+
+// Names 返回按名称排序的渠道名
+func (s *DeliverySettings) Names() []string {
+	names := make([]string, 0, len(s.Channels))
+	for name := range s.Channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ChannelType 返回渠道的类型，渠道不存在时返回空字符串
+func (s *DeliverySettings) ChannelType(name string) string {
+	cfg, err := s.resolve(name)
+	if err != nil {
+		return ""
+	}
+	return cfg.Type
+}
+
+// Deliver 依次推送到各渠道，某个渠道失败不影响其他渠道
+func (s *DeliverySettings) Deliver(ctx context.Context, names []string, msg *DeliveryMessage) []DeliveryResult {
+	results := make([]DeliveryResult, 0, len(names))
+	for _, name := range names {
+		result := DeliveryResult{Channel: name, Type: s.ChannelType(name)}
+		channel, err := s.NewChannel(name)
+		if err == nil {
+			result.Parts, err = channel.Send(ctx, msg)
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// resolve 合并渠道配置与默认值，并从环境变量读取地址和密钥
+func (s *DeliverySettings) resolve(name string) (*ChannelConfig, error) {
+	configured, ok := s.Channels[name]
+	if !ok || configured == nil {
+		return nil, fmt.Errorf("未知的推送渠道: %s", name)
+	}
+
+	cfg := *configured
+	if cfg.Type == "" {
+		cfg.Type = name
+	}
+	if cfg.Type == ChannelEmail {
+		return &cfg, nil
+	}
+	limit, ok := channelMaxBytes[cfg.Type]
+	if !ok {
+		return nil, fmt.Errorf("未知的推送渠道类型 %s，可选 dingtalk, feishu, wecom, slack, email", cfg.Type)
+	}
+
+	if cfg.WebhookURL == "" && cfg.WebhookURLEnv != "" {
+		cfg.WebhookURL = os.Getenv(cfg.WebhookURLEnv)
+	}
+	if cfg.Secret == "" && cfg.SecretEnv != "" {
+		cfg.Secret = os.Getenv(cfg.SecretEnv)
+	}
+	if cfg.MaxBytes == 0 || cfg.MaxBytes > limit {
+		cfg.MaxBytes = limit
+	}
+	if cfg.TimeoutSeconds == 0 {
+		cfg.TimeoutSeconds = defaultChannelTimeoutSeconds
+	}
+
+	return &cfg, nil
+}
+
+// validate 校验推送配置，返回发现的问题
+func (s *DeliverySettings) validate() []string {
+	problems := s.SMTP.validate()
+
+	for _, name := range s.Names() {
+		cfg := s.Channels[name]
+		resolved, err := s.resolve(name)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("delivery.channels.%s 无效: %v", name, err))
+			continue
+		}
+		if resolved.Type == ChannelEmail {
+			problems = append(problems, validateEmailChannel(name, cfg, &s.SMTP)...)
+			continue
+		}
+		if cfg.WebhookURL == "" && cfg.WebhookURLEnv == "" {
+			problems = append(problems, fmt.Sprintf("delivery.channels.%s 需要配置 webhook_url 或 webhook_url_env", name))
+		}
+		if cfg.WebhookURL != "" {
+			if u, err := url.Parse(cfg.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				problems = append(problems, fmt.Sprintf("delivery.channels.%s.webhook_url 不是有效的 http(s) 地址", name))
+			}
+		}
+		if cfg.MaxBytes < 0 || (cfg.MaxBytes > 0 && cfg.MaxBytes < minChannelMaxBytes) {
+			problems = append(problems, fmt.Sprintf("delivery.channels.%s.max_bytes 不能小于 %d", name, minChannelMaxBytes))
+		}
+		if cfg.TimeoutSeconds < 0 {
+			problems = append(problems, fmt.Sprintf("delivery.channels.%s.timeout_seconds 不能为负数", name))
+		}
+	}
+
+	return problems
+}
+
+// webhookChannel 各平台机器人共用的配置和客户端
+type webhookChannel struct {
+	name   string
+	config *ChannelConfig
+	client *http.Client
+}
+
+// Name 渠道名称
+func (c *webhookChannel) Name() string {
+	return c.name
+}
+
+// parts 把消息转换为平台支持的 Markdown 并按长度拆分，多条时在后续消息开头标注序号
+func (c *webhookChannel) parts(msg *DeliveryMessage, dialect markdownDialect) []string {
+	text := chatMarkdown(msg.Markdown, dialect)
+	title := truncateUTF8(msg.Title, maxMarkerTitleBytes)
+	marker := func(i, n int) string {
+		return dialect.bold(fmt.Sprintf("%s（%d/%d）", title, i, n)) + "\n\n"
+	}
+
+	// 为分段标记预留长度
+	reserve := len(marker(99, 99))
+	parts := splitMessage(text, c.config.MaxBytes-reserve)
+	for i := 1; i < len(parts); i++ {
+		parts[i] = marker(i+1, len(parts)) + parts[i]
+	}
+	return parts
+}
+
+// DingTalkChannel 钉钉自定义机器人
+// 开启加签时在地址上附加 timestamp 和 sign 参数
+type DingTalkChannel struct {
+	webhookChannel
+}
+
+// Send 以 markdown 消息推送，标题用于会话列表中的预览
+func (c *DingTalkChannel) Send(ctx context.Context, msg *DeliveryMessage) (int, error) {
+	parts := c.parts(msg, dialectDingTalk)
+	for i, text := range parts {
+		target := c.config.WebhookURL
+		if c.config.Secret != "" {
+			timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+			sign := hmacBase64([]byte(c.config.Secret), timestamp+"\n"+c.config.Secret)
+			target = appendQuery(target, url.Values{"timestamp": {timestamp}, "sign": {sign}})
+		}
+
+		body := map[string]interface{}{
+			"msgtype": "markdown",
+			"markdown": map[string]string{
+				"title": partTitle(msg.Title, i, len(parts)),
+				"text":  text,
+			},
+		}
+		var response struct {
+			ErrCode int    `json:"errcode"`
+			ErrMsg  string `json:"errmsg"`
+		}
+		if err := postJSON(ctx, c.client, target, nil, body, &response); err != nil {
+			return i, err
+		}
+		if response.ErrCode != 0 {
+			return i, fmt.Errorf("dingtalk returned error %d: %s", response.ErrCode, response.ErrMsg)
+		}
+	}
+	return len(parts), nil
+}
+
+// FeishuChannel 飞书/Lark 自定义机器人，以消息卡片展示报告
+// 开启签名校验时在请求体中附加 timestamp 和 sign
+type FeishuChannel struct {
+	webhookChannel
+}
+
+// Send 以 interactive 卡片推送，标题显示在卡片头部
+func (c *FeishuChannel) Send(ctx context.Context, msg *DeliveryMessage) (int, error) {
+	parts := c.parts(msg, dialectFeishu)
+	for i, text := range parts {
+		body := map[string]interface{}{
+			"msg_type": "interactive",
+			"card": map[string]interface{}{
+				"config": map[string]bool{"wide_screen_mode": true},
+				"header": map[string]interface{}{
+					"template": "blue",
+					"title":    map[string]string{"tag": "plain_text", "content": partTitle(msg.Title, i, len(parts))},
+				},
+				"elements": []interface{}{
+					map[string]string{"tag": "markdown", "content": text},
+				},
+			},
+		}
+		if c.config.Secret != "" {
+			timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+			body["timestamp"] = timestamp
+			body["sign"] = hmacBase64([]byte(timestamp+"\n"+c.config.Secret), "")
+		}
+
+		// 旧版接口以 StatusCode 表示结果，新版为 code
+		var response struct {
+			Code       int    `json:"code"`
+			Msg        string `json:"msg"`
+			StatusCode int    `json:"StatusCode"`
+		}
+		if err := postJSON(ctx, c.client, c.config.WebhookURL, nil, body, &response); err != nil {
+			return i, err
+		}
+		if response.Code != 0 {
+			return i, fmt.Errorf("feishu returned error %d: %s", response.Code, response.Msg)
+		}
+		if response.StatusCode != 0 {
+			return i, fmt.Errorf("feishu returned status %d", response.StatusCode)
+		}
+	}
+	return len(parts), nil
+}
+
+// WeComChannel 企业微信群机器人，密钥包含在 Webhook 地址中
+type WeComChannel struct {
+	webhookChannel
+}
+
+// Send 以 markdown 消息推送，企业微信的 markdown 消息没有单独的标题
+func (c *WeComChannel) Send(ctx context.Context, msg *DeliveryMessage) (int, error) {
+	parts := c.parts(msg, dialectWeCom)
+	for i, text := range parts {
+		body := map[string]interface{}{
+			"msgtype":  "markdown",
+			"markdown": map[string]string{"content": text},
+		}
+		var response struct {
+			ErrCode int    `json:"errcode"`
+			ErrMsg  string `json:"errmsg"`
+		}
+		if err := postJSON(ctx, c.client, c.config.WebhookURL, nil, body, &response); err != nil {
+			return i, err
+		}
+		if response.ErrCode != 0 {
+			return i, fmt.Errorf("wecom returned error %d: %s", response.ErrCode, response.ErrMsg)
+		}
+	}
+	return len(parts), nil
+}
+
+// SlackChannel Slack Incoming Webhook，密钥包含在 Webhook 地址中
+type SlackChannel struct {
+	webhookChannel
+}
+
+// Send 以 mrkdwn 文本推送，Slack 成功时返回纯文本 ok 而不是 JSON
+func (c *SlackChannel) Send(ctx context.Context, msg *DeliveryMessage) (int, error) {
+	parts := c.parts(msg, dialectSlack)
+	for i, text := range parts {
+		body := map[string]interface{}{"text": text, "mrkdwn": true}
+		if err := postWebhook(ctx, c.client, c.config.WebhookURL, body); err != nil {
+			return i, err
+		}
+	}
+	return len(parts), nil
+}
+
+// postWebhook 发送JSON请求，只检查状态码，不解析响应
+func postWebhook(ctx context.Context, client *http.Client, url string, body interface{}) error {
+	responseBody, err := postStream(ctx, client, url, nil, body)
+	if err != nil {
+		return err
+	}
+	defer responseBody.Close()
+	io.Copy(io.Discard, responseBody)
+	return nil
+}
+
+// hmacBase64 计算 HMAC-SHA256 并进行 Base64 编码
+func hmacBase64(key []byte, message string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// appendQuery 在地址上附加查询参数，保留已有的参数
+func appendQuery(rawURL string, values url.Values) string {
+	separator := "?"
+	if strings.Contains(rawURL, "?") {
+		separator = "&"
+	}
+	return rawURL + separator + values.Encode()
+}
+
+// partTitle 多条消息时在标题后标注序号
+func partTitle(title string, i, n int) string {
+	if n <= 1 {
+		return title
+	}
+	return fmt.Sprintf("%s（%d/%d）", title, i+1, n)
+}
+
+// truncateUTF8 把文本截断到不超过 limit 字节，不拆开多字节字符，截断时以省略号结尾
+func truncateUTF8(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	cut := limit - len("…")
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "…"
+}
+
+// markdownDialect 各平台支持的 Markdown 子集
+// 四个平台都不支持表格，飞书卡片和 Slack 也不支持标题
+type markdownDialect struct {
+	headings bool   // 是否支持 # 标题
+	strong   string // 加粗的标记
+	slack    bool   // 使用 Slack 的 mrkdwn 语法：转义 & < >，链接写作 <url|text>
+}
+
+var (
+	dialectDingTalk = markdownDialect{headings: true, strong: "**"}
+	dialectFeishu   = markdownDialect{strong: "**"}
+	dialectWeCom    = markdownDialect{headings: true, strong: "**"}
+	dialectSlack    = markdownDialect{strong: "*", slack: true}
+)
+
+// bold 加粗文本
+func (d markdownDialect) bold(text string) string {
+	return d.strong + text + d.strong
+}
+
+var (
+	chatHeadingRegex = regexp.MustCompile(`^#{1,6}\s+(.*)$`)
+	chatRuleRegex    = regexp.MustCompile(`^\s*([-*_]\s*){3,}$`)
+	chatTableRegex   = regexp.MustCompile(`^\s*\|.*\|\s*$`)
+	chatDividerRegex = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	chatBoldRegex    = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	chatItalicRegex  = regexp.MustCompile(`(^|[^*])\*([^*\s][^*\n]*?)\*([^*]|$)`)
+	chatLinkRegex    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	chatBlankRegex   = regexp.MustCompile(`\n{3,}`)
+	chatCommentRegex = regexp.MustCompile(`(?s)<!--.*?-->`)
+)
+
+// chatMarkdown 把报告的 Markdown 转换为聊天平台支持的格式
+// 表格转换为列表，分隔线和 HTML 注释删除，不支持标题的平台把标题改为加粗
+func chatMarkdown(content string, dialect markdownDialect) string {
+	content = chatCommentRegex.ReplaceAllString(strings.ReplaceAll(content, "\r\n", "\n"), "")
+	lines := strings.Split(content, "\n")
+
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case chatTableRegex.MatchString(line):
+			var table []string
+			for ; i < len(lines) && chatTableRegex.MatchString(lines[i]); i++ {
+				table = append(table, lines[i])
+			}
+			i--
+			out = append(out, tableToList(table)...)
+		case chatRuleRegex.MatchString(line):
+			out = append(out, "")
+		case chatHeadingRegex.MatchString(line):
+			if dialect.headings {
+				out = append(out, line)
+			} else {
+				out = append(out, "**"+strings.TrimSpace(chatHeadingRegex.FindStringSubmatch(line)[1])+"**")
+			}
+		default:
+			out = append(out, line)
+		}
+	}
+
+	text := strings.Join(out, "\n")
+	if dialect.slack {
+		text = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+		text = chatItalicRegex.ReplaceAllString(text, "${1}_${2}_$3")
+		text = chatBoldRegex.ReplaceAllString(text, "*$1*")
+		text = chatLinkRegex.ReplaceAllString(text, "<$2|$1>")
+	}
+	text = chatBlankRegex.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// tableToList 把表格的每一行转换为列表项，两列的表格写作“名称：值”，多列时带上表头
+func tableToList(table []string) []string {
+	var header []string
+	var items []string
+	for _, line := range table {
+		if chatDividerRegex.MatchString(line) {
+			continue
+		}
+		cells := strings.Split(strings.Trim(strings.TrimSpace(line), "|"), "|")
+		for i := range cells {
+			cells[i] = strings.TrimSpace(cells[i])
+		}
+		if header == nil {
+			header = cells
+			continue
+		}
+		if len(cells) == 0 {
+			continue
+		}
+
+		if len(cells) == 2 {
+			items = append(items, fmt.Sprintf("- %s：%s", cells[0], cells[1]))
+			continue
+		}
+		var fields []string
+		for j := 1; j < len(cells); j++ {
+			if j < len(header) && header[j] != "" {
+				fields = append(fields, header[j]+" "+cells[j])
+			} else {
+				fields = append(fields, cells[j])
+			}
+		}
+		items = append(items, fmt.Sprintf("- %s：%s", cells[0], strings.Join(fields, "，")))
+	}
+	return items
+}
+
+// splitMessage 按行把文本拆分为不超过 limit 字节的若干段，尽量在空行处断开
+// 单行超出限制时按字符截断；limit 小于 minSplitBytes 时按 minSplitBytes 拆分
+func splitMessage(text string, limit int) []string {
+	limit = max(limit, minSplitBytes)
+	if len(text) <= limit {
+		return []string{text}
+	}
+
+	var parts []string
+	var current bytes.Buffer
+	flush := func() {
+		if part := strings.TrimSpace(current.String()); part != "" {
+			parts = append(parts, part)
+		}
+		current.Reset()
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		for len(line) > limit {
+			flush()
+			cut := limit
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			parts = append(parts, line[:cut])
+			line = line[cut:]
+		}
+
+		if current.Len()+len(line)+1 > limit {
+			// 当前段较满时从上一个空行处断开，避免把一节内容拆到两条消息中
+			content := current.String()
+			if cut := strings.LastIndex(content, "\n\n"); cut > limit/2 {
+				rest := content[cut+2:]
+				current.Reset()
+				current.WriteString(content[:cut])
+				flush()
+				current.WriteString(rest)
+			}
+			if current.Len()+len(line)+1 > limit {
+				flush()
+			}
+		}
+		current.WriteString(line)
+		current.WriteByte('\n')
+	}
+	flush()
+
+	return parts
+}
+
+// NewReportMessage 根据报告构建推送消息，同时渲染 HTML 供邮件使用
+func NewReportMessage(renderer *ReportRenderer, report *Report, markdown string) (*DeliveryMessage, error) {
+	html, err := renderer.RenderHTML(report)
+	if err != nil {
+		return nil, err
+	}
+	return &DeliveryMessage{
+		Title:    reportTitle(report),
+		Markdown: markdown,
+		HTML:     html,
+		Author:   report.Author,
+		Period:   report.Period,
+		Type:     report.Type,
+		Date:     report.Date.Format("2006-01-02"),
+	}, nil
+}
+
+// reportTitle 报告推送时使用的标题
+func reportTitle(report *Report) string {
+	title := "工作" + reportTypeName(report.Type)
+	if report.Author != "" {
+		title = report.Author + " 的" + title
+	}
+	return title
+}
+
+// markdownTitle 取 Markdown 中的第一个标题作为消息标题，没有标题时使用“工作报告”
+func markdownTitle(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if match := chatHeadingRegex.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			if title := strings.TrimSpace(chatBoldRegex.ReplaceAllString(match[1], "$1")); title != "" {
+				return title
+			}
+		}
+	}
+	return "工作报告"
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestChannel 创建指向测试服务器的推送渠道
+func newTestChannel(t *testing.T, cfg *ChannelConfig, handler http.Handler) Channel {
+	t.Helper()
+	cfg.WebhookURL = startTestServer(t, handler) + "/hook?access_token=abc"
+	settings := &DeliverySettings{Channels: map[string]*ChannelConfig{"test": cfg}}
+	channel, err := settings.NewChannel("test")
+	if err != nil {
+		t.Fatalf("NewChannel 返回错误: %v", err)
+	}
+	return channel
+}
+
+// field 按路径取出 JSON 对象中的值
+func field(value interface{}, path ...interface{}) interface{} {
+	for _, key := range path {
+		switch k := key.(type) {
+		case string:
+			m, _ := value.(map[string]interface{})
+			value = m[k]
+		case int:
+			a, _ := value.([]interface{})
+			if k >= len(a) {
+				return nil
+			}
+			value = a[k]
+		}
+	}
+	return value
+}
+
+var testDeliveryMessage = &DeliveryMessage{
+	Title:    "张三 的工作周报",
+	Markdown: "# 周报\n\n## 概览\n\n| 指标 | 数值 |\n|---|---|\n| 提交 | 3 |\n\n- **修复** 登录问题 [详情](https://example.com/a?b=1&c=2)",
+}
+
+func TestDingTalkSend(t *testing.T) {
+	recorder := &requestRecorder{}
+	channel := newTestChannel(t, &ChannelConfig{Type: ChannelDingTalk, Secret: "SEC123"}, recorder)
+
+	before := time.Now().UnixMilli()
+	sent, err := channel.Send(context.Background(), testDeliveryMessage)
+	if err != nil {
+		t.Fatalf("Send 返回错误: %v", err)
+	}
+	if sent != 1 || len(recorder.bodies) != 1 {
+		t.Fatalf("发送 %d 条，服务器收到 %d 条，期望 1", sent, len(recorder.bodies))
+	}
+
+	body := recorder.bodies[0]
+	if body["msgtype"] != "markdown" {
+		t.Errorf("msgtype = %v", body["msgtype"])
+	}
+	if title := field(body, "markdown", "title"); title != "张三 的工作周报" {
+		t.Errorf("markdown.title = %v", title)
+	}
+	text, _ := field(body, "markdown", "text").(string)
+	if !strings.Contains(text, "# 周报") || !strings.Contains(text, "- 提交：3") || strings.Contains(text, "|---|") {
+		t.Errorf("markdown.text 未转换表格: %q", text)
+	}
+
+	// 加签：sign = Base64(HMAC-SHA256(secret, timestamp + "\n" + secret))
+	query, err := url.ParseQuery(recorder.queries[0])
+	if err != nil {
+		t.Fatalf("解析查询参数失败: %v", err)
+	}
+	if query.Get("access_token") != "abc" {
+		t.Errorf("原有查询参数丢失: %q", recorder.queries[0])
+	}
+	timestamp, err := strconv.ParseInt(query.Get("timestamp"), 10, 64)
+	if err != nil || timestamp < before || timestamp > time.Now().UnixMilli() {
+		t.Errorf("timestamp = %q", query.Get("timestamp"))
+	}
+	if want := hmacBase64([]byte("SEC123"), query.Get("timestamp")+"\nSEC123"); query.Get("sign") != want {
+		t.Errorf("sign = %q, 期望 %q", query.Get("sign"), want)
+	}
+}
+
+func TestFeishuSend(t *testing.T) {
+	recorder := &requestRecorder{}
+	channel := newTestChannel(t, &ChannelConfig{Type: ChannelFeishu, Secret: "FS456"}, recorder)
+
+	if _, err := channel.Send(context.Background(), testDeliveryMessage); err != nil {
+		t.Fatalf("Send 返回错误: %v", err)
+	}
+
+	body := recorder.bodies[0]
+	if body["msg_type"] != "interactive" {
+		t.Errorf("msg_type = %v", body["msg_type"])
+	}
+	if title := field(body, "card", "header", "title", "content"); title != "张三 的工作周报" {
+		t.Errorf("card.header.title.content = %v", title)
+	}
+	if tag := field(body, "card", "elements", 0, "tag"); tag != "markdown" {
+		t.Errorf("card.elements[0].tag = %v", tag)
+	}
+	content, _ := field(body, "card", "elements", 0, "content").(string)
+	if !strings.Contains(content, "**周报**") || strings.Contains(content, "# 周报") {
+		t.Errorf("飞书卡片不支持标题，应改为加粗: %q", content)
+	}
+
+	// 签名：sign = Base64(HMAC-SHA256(timestamp + "\n" + secret, ""))
+	timestamp, _ := body["timestamp"].(string)
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+		t.Fatalf("timestamp = %v", body["timestamp"])
+	}
+	if want := hmacBase64([]byte(timestamp+"\nFS456"), ""); body["sign"] != want {
+		t.Errorf("sign = %v, 期望 %q", body["sign"], want)
+	}
+}
+
+func TestFeishuSendWithoutSecret(t *testing.T) {
+	recorder := &requestRecorder{}
+	channel := newTestChannel(t, &ChannelConfig{Type: ChannelFeishu}, recorder)
+
+	if _, err := channel.Send(context.Background(), testDeliveryMessage); err != nil {
+		t.Fatalf("Send 返回错误: %v", err)
+	}
+	if _, ok := recorder.bodies[0]["sign"]; ok {
+		t.Error("未配置密钥时不应附加 sign")
+	}
+}
+
+func TestWeComSend(t *testing.T) {
+	recorder := &requestRecorder{}
+	channel := newTestChannel(t, &ChannelConfig{Type: ChannelWeCom}, recorder)
+
+	if _, err := channel.Send(context.Background(), testDeliveryMessage); err != nil {
+		t.Fatalf("Send 返回错误: %v", err)
+	}
+
+	body := recorder.bodies[0]
+	if body["msgtype"] != "markdown" {
+		t.Errorf("msgtype = %v", body["msgtype"])
+	}
+	content, _ := field(body, "markdown", "content").(string)
+	if !strings.HasPrefix(content, "# 周报") {
+		t.Errorf("markdown.content = %q", content)
+	}
+	if recorder.queries[0] != "access_token=abc" {
+		t.Errorf("企业微信不应附加签名参数: %q", recorder.queries[0])
+	}
+}
+
+func TestSlackSend(t *testing.T) {
+	recorder := &requestRecorder{responses: []string{"ok"}}
+	channel := newTestChannel(t, &ChannelConfig{Type: ChannelSlack}, recorder)
+
+	if _, err := channel.Send(context.Background(), testDeliveryMessage); err != nil {
+		t.Fatalf("Send 返回错误: %v", err)
+	}
+
+	body := recorder.bodies[0]
+	if body["mrkdwn"] != true {
+		t.Errorf("mrkdwn = %v", body["mrkdwn"])
+	}
+	text, _ := body["text"].(string)
+	for _, want := range []string{"*周报*", "*修复*", "<https://example.com/a?b=1&amp;c=2|详情>"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text 缺少 %q: %q", want, text)
+		}
+	}
+}
+
+func TestWebhookSendErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		channel  string
+		recorder *requestRecorder
+		wantErr  string
+	}{
+		{"钉钉 errcode", ChannelDingTalk, &requestRecorder{responses: []string{`{"errcode":310000,"errmsg":"sign not match"}`}}, "310000"},
+		{"钉钉 HTTP 错误", ChannelDingTalk, &requestRecorder{status: http.StatusBadGateway}, "502"},
+		{"飞书 code", ChannelFeishu, &requestRecorder{responses: []string{`{"code":19021,"msg":"sign match fail"}`}}, "19021"},
+		{"飞书旧版 StatusCode", ChannelFeishu, &requestRecorder{responses: []string{`{"StatusCode":9499}`}}, "9499"},
+		{"企业微信 errcode", ChannelWeCom, &requestRecorder{responses: []string{`{"errcode":93000,"errmsg":"invalid webhook url"}`}}, "93000"},
+		{"企业微信响应不是 JSON", ChannelWeCom, &requestRecorder{responses: []string{"not json"}}, ""},
+		{"Slack HTTP 错误", ChannelSlack, &requestRecorder{status: http.StatusForbidden, responses: []string{"invalid_token"}}, "invalid_token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := newTestChannel(t, &ChannelConfig{Type: tt.channel}, tt.recorder)
+			sent, err := channel.Send(context.Background(), testDeliveryMessage)
+			if err == nil {
+				t.Fatal("期望返回错误")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("错误 %q 中缺少 %q", err, tt.wantErr)
+			}
+			if sent != 0 {
+				t.Errorf("发送条数 = %d, 期望 0", sent)
+			}
+		})
+	}
+}
+
+func TestWebhookSendSplitsAtPlatformLimit(t *testing.T) {
+	var sections []string
+	for i := 0; i < 40; i++ {
+		sections = append(sections, fmt.Sprintf("## 第 %d 节\n\n%s", i, strings.Repeat("提交说明内容。", 40)))
+	}
+	msg := &DeliveryMessage{Title: "张三 的工作周报", Markdown: strings.Join(sections, "\n\n")}
+
+	for _, channelType := range []string{ChannelDingTalk, ChannelFeishu, ChannelWeCom, ChannelSlack} {
+		t.Run(channelType, func(t *testing.T) {
+			recorder := &requestRecorder{responses: []string{`{"errcode":0,"code":0}`}}
+			channel := newTestChannel(t, &ChannelConfig{Type: channelType}, recorder)
+
+			sent, err := channel.Send(context.Background(), msg)
+			if err != nil {
+				t.Fatalf("Send 返回错误: %v", err)
+			}
+			if sent < 2 || sent != len(recorder.bodies) {
+				t.Fatalf("发送 %d 条，服务器收到 %d 条，期望拆分为多条", sent, len(recorder.bodies))
+			}
+
+			limit := channelMaxBytes[channelType]
+			for i, body := range recorder.bodies {
+				text := webhookText(channelType, body)
+				if len(text) > limit {
+					t.Errorf("第 %d 条 %d 字节，超过上限 %d", i+1, len(text), limit)
+				}
+				if i > 0 && !strings.Contains(text, fmt.Sprintf("（%d/%d）", i+1, sent)) {
+					t.Errorf("第 %d 条缺少分段标记: %q", i+1, text[:min(len(text), 80)])
+				}
+			}
+		})
+	}
+}
+
+// webhookText 取出各平台请求体中的消息正文
+func webhookText(channelType string, body map[string]interface{}) string {
+	var value interface{}
+	switch channelType {
+	case ChannelDingTalk:
+		value = field(body, "markdown", "text")
+	case ChannelFeishu:
+		value = field(body, "card", "elements", 0, "content")
+	case ChannelWeCom:
+		value = field(body, "markdown", "content")
+	default:
+		value = body["text"]
+	}
+	text, _ := value.(string)
+	return text
+}
+
+func TestWebhookPartsLongTitle(t *testing.T) {
+	channel := &webhookChannel{config: &ChannelConfig{MaxBytes: minChannelMaxBytes}}
+	msg := &DeliveryMessage{
+		Title:    strings.Repeat("很长的标题", 200),
+		Markdown: strings.Repeat("一行内容\n", 400),
+	}
+
+	parts := channel.parts(msg, dialectWeCom)
+	if len(parts) < 2 {
+		t.Fatalf("拆分为 %d 条，期望多条", len(parts))
+	}
+	for i, part := range parts {
+		if len(part) > minChannelMaxBytes {
+			t.Errorf("第 %d 条 %d 字节，超过上限 %d", i+1, len(part), minChannelMaxBytes)
+		}
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{"未超出限制", "第一行\n第二行", 100, []string{"第一行\n第二行"}},
+		{"按行拆分", strings.Repeat("a", 40) + "\n" + strings.Repeat("b", 40) + "\n" + strings.Repeat("c", 40), 90,
+			[]string{strings.Repeat("a", 40) + "\n" + strings.Repeat("b", 40), strings.Repeat("c", 40)}},
+		{"在空行处断开", strings.Repeat("a", 50) + "\n\n" + strings.Repeat("b", 20) + "\n" + strings.Repeat("c", 30), 80,
+			[]string{strings.Repeat("a", 50), strings.Repeat("b", 20) + "\n" + strings.Repeat("c", 30)}},
+		{"超长行按字符截断", strings.Repeat("汉", 30), 64,
+			[]string{strings.Repeat("汉", 21), strings.Repeat("汉", 9)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitMessage(tt.text, tt.limit)
+			if strings.Join(got, "\x00") != strings.Join(tt.want, "\x00") {
+				t.Errorf("splitMessage() = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitMessageKeepsContent(t *testing.T) {
+	text := strings.Repeat("- 修复了一个问题，涉及多个模块\n", 300)
+	for _, limit := range []int{-10, 0, 1, 3, 64, 500, 4000} {
+		parts := splitMessage(text, limit)
+		effective := max(limit, minSplitBytes)
+		for _, part := range parts {
+			if len(part) > effective {
+				t.Errorf("limit=%d: 段长 %d 超过 %d", limit, len(part), effective)
+			}
+		}
+		joined := strings.ReplaceAll(strings.Join(parts, ""), "\n", "")
+		if joined != strings.ReplaceAll(text, "\n", "") {
+			t.Errorf("limit=%d: 拆分后内容丢失", limit)
+		}
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		text  string
+		limit int
+		want  string
+	}{
+		{"短标题", 20, "短标题"},
+		{"周报周报周报", 10, "周报…"},
+		{"abcdefgh", 6, "abc…"},
+	}
+	for _, tt := range tests {
+		if got := truncateUTF8(tt.text, tt.limit); got != tt.want {
+			t.Errorf("truncateUTF8(%q, %d) = %q, 期望 %q", tt.text, tt.limit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContextLines 差异前后保留的上下文行数
+const diffContextLines = 3
+
+// diffMaxCells 逐行比较的规模上限，超出时把中间不同的部分整体视为删除后新增
+const diffMaxCells = 4000000
+
+// diffLine 差异中的一行，op 为 ' '、'-' 或 '+'
+type diffLine struct {
+	op   byte
+	text string
+	a, b int // 在两个文本中的行号（从 0 开始），新增行的 a 和删除行的 b 为下一行的位置
+}
+
+// UnifiedDiff 按行比较两段文本，返回 unified diff 格式的差异，相同时返回空字符串
+func UnifiedDiff(a, b, fromName, toName string) string {
+	if a == b {
+		return ""
+	}
+	lines := diffLines(diffSplit(a), diffSplit(b))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+
+	for start := 0; start < len(lines); {
+		// 找到下一处改动
+		for start < len(lines) && lines[start].op == ' ' {
+			start++
+		}
+		if start == len(lines) {
+			break
+		}
+
+		// 向后合并间隔不超过两倍上下文的改动
+		end := start
+		for i := start; i < len(lines); i++ {
+			if lines[i].op != ' ' {
+				end = i + 1
+			} else if i-end >= 2*diffContextLines {
+				break
+			}
+		}
+
+		from := max(start-diffContextLines, 0)
+		to := min(end+diffContextLines, len(lines))
+		writeHunk(&sb, lines[from:to])
+		start = to
+	}
+
+	return sb.String()
+}
+
+// writeHunk 输出一段差异及其 @@ 行号头
+func writeHunk(sb *strings.Builder, hunk []diffLine) {
+	var aCount, bCount int
+	for _, line := range hunk {
+		if line.op != '+' {
+			aCount++
+		}
+		if line.op != '-' {
+			bCount++
+		}
+	}
+
+	aStart, bStart := hunk[0].a+1, hunk[0].b+1
+	if aCount == 0 {
+		aStart--
+	}
+	if bCount == 0 {
+		bStart--
+	}
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+	for _, line := range hunk {
+		sb.WriteByte(line.op)
+		sb.WriteString(line.text)
+		sb.WriteByte('\n')
+	}
+}
+
+// diffLines 用最长公共子序列计算逐行差异，先去掉相同的开头和结尾以缩小规模
+func diffLines(a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var lines []diffLine
+	for i := 0; i < prefix; i++ {
+		lines = append(lines, diffLine{op: ' ', text: a[i], a: i, b: i})
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(midA), len(midB)
+
+	if n*m > diffMaxCells {
+		for i, text := range midA {
+			lines = append(lines, diffLine{op: '-', text: text, a: prefix + i, b: prefix})
+		}
+		for j, text := range midB {
+			lines = append(lines, diffLine{op: '+', text: text, a: prefix + n, b: prefix + j})
+		}
+	} else {
+		// lcs[i][j] 为 midA[i:] 与 midB[j:] 的最长公共子序列长度
+		lcs := make([][]int32, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int32, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && midA[i] == midB[j]:
+				lines = append(lines, diffLine{op: ' ', text: midA[i], a: prefix + i, b: prefix + j})
+				i++
+				j++
+			case j < m && (i == n || lcs[i][j+1] > lcs[i+1][j]):
+				lines = append(lines, diffLine{op: '+', text: midB[j], a: prefix + i, b: prefix + j})
+				j++
+			default:
+				lines = append(lines, diffLine{op: '-', text: midA[i], a: prefix + i, b: prefix + j})
+				i++
+			}
+		}
+	}
+
+	for k := 0; k < suffix; k++ {
+		lines = append(lines, diffLine{op: ' ', text: a[len(a)-suffix+k], a: len(a) - suffix + k, b: len(b) - suffix + k})
+	}
+	return lines
+}
+
+// diffSplit 按行拆分文本，忽略结尾的换行
+func diffSplit(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
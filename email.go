@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// SMTPSettings 发送邮件使用的 SMTP 服务器，所有邮件渠道共用
+type SMTPSettings struct {
+	Host               string `json:"host"`                 // 服务器地址
+	Port               int    `json:"port"`                 // 端口，默认 STARTTLS 为 587，隐式 TLS 为 465，不加密为 25
+	Security           string `json:"security"`             // starttls（默认）、tls（隐式 TLS）或 none
+	Username           string `json:"username"`             // 登录用户名，为空时不认证
+	Password           string `json:"password"`             // 登录密码，建议使用 password_env
+	PasswordEnv        string `json:"password_env"`         // 读取登录密码的环境变量
+	From               string `json:"from"`                 // 发件人，如 周报机器人 <report@example.com>
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"` // 不校验服务器证书，仅用于自签名证书的内网服务器
+	TimeoutSeconds     int    `json:"timeout_seconds"`      // 连接和发送的超时时间
+}
+
+// SMTP 连接的加密方式
+const (
+	SMTPSecurityStartTLS = "starttls"
+	SMTPSecurityTLS      = "tls"
+	SMTPSecurityNone     = "none"
+)
+
+// ChannelEmail 邮件推送渠道类型
+const ChannelEmail = "email"
+
+// DefaultEmailSubject 未配置 subject 时的邮件主题
+const DefaultEmailSubject = "{{.Title}}"
+
+// 未配置时的默认超时
+const defaultSMTPTimeoutSeconds = 30
+
+// EmailSubjectData 邮件主题模板中可用的变量
+type EmailSubjectData struct {
+	Title    string // 报告标题，如 张三 的工作周报
+	Author   string // 报告作者
+	Period   string // 报告周期
+	Type     string // 报告类型，如 weekly
+	TypeName string // 报告类型名称，如 周报
+	Date     string // 报告日期，如 2024-01-15
+}
+
+// EmailChannel 通过 SMTP 发送邮件
+// 消息带有 HTML 渲染结果时发送 multipart/alternative，同时包含 Markdown 纯文本和 HTML
+type EmailChannel struct {
+	name    string
+	config  *ChannelConfig
+	smtp    *SMTPSettings
+	subject *template.Template
+}
+
+// NewEmailChannel 创建邮件渠道
+func NewEmailChannel(name string, config *ChannelConfig, settings *SMTPSettings) (*EmailChannel, error) {
+	smtpSettings := settings.resolve()
+	if smtpSettings.Host == "" || smtpSettings.From == "" {
+		return nil, fmt.Errorf("邮件渠道 %s 需要先配置 delivery.smtp 的 host 和 from", name)
+	}
+	if len(config.To) == 0 {
+		return nil, fmt.Errorf("邮件渠道 %s 没有配置收件人", name)
+	}
+
+	subject := config.Subject
+	if subject == "" {
+		subject = DefaultEmailSubject
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("解析邮件渠道 %s 的主题模板失败: %v", name, err)
+	}
+
+	return &EmailChannel{name: name, config: config, smtp: smtpSettings, subject: tmpl}, nil
+}
+
+// Name 渠道名称
+func (c *EmailChannel) Name() string {
+	return c.name
+}
+
+// Send 发送一封邮件，收件人包括 to、cc 和 bcc
+func (c *EmailChannel) Send(ctx context.Context, msg *DeliveryMessage) (int, error) {
+	subject, err := c.Subject(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := buildEmail(c.smtp.From, c.config.To, c.config.Cc, subject, msg)
+	if err != nil {
+		return 0, err
+	}
+
+	recipients := append(append(append([]string{}, c.config.To...), c.config.Cc...), c.config.Bcc...)
+	if err := c.smtp.send(ctx, recipients, data); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// Subject 渲染邮件主题
+func (c *EmailChannel) Subject(msg *DeliveryMessage) (string, error) {
+	data := EmailSubjectData{
+		Title:    msg.Title,
+		Author:   msg.Author,
+		Period:   msg.Period,
+		Type:     msg.Type,
+		TypeName: reportTypeName(msg.Type),
+		Date:     msg.Date,
+	}
+
+	var buf bytes.Buffer
+	if err := c.subject.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("渲染邮件主题失败: %v", err)
+	}
+	// 主题中不能出现换行，模板中的多余空白合并为一个空格
+	return strings.Join(strings.Fields(buf.String()), " "), nil
+}
+
+// resolve 填充默认端口、加密方式和超时，并从环境变量读取密码
+func (s *SMTPSettings) resolve() *SMTPSettings {
+	resolved := *s
+	if resolved.Security == "" {
+		resolved.Security = SMTPSecurityStartTLS
+	}
+	if resolved.Port == 0 {
+		switch resolved.Security {
+		case SMTPSecurityTLS:
+			resolved.Port = 465
+		case SMTPSecurityNone:
+			resolved.Port = 25
+		default:
+			resolved.Port = 587
+		}
+	}
+	if resolved.Password == "" && resolved.PasswordEnv != "" {
+		resolved.Password = os.Getenv(resolved.PasswordEnv)
+	}
+	if resolved.TimeoutSeconds == 0 {
+		resolved.TimeoutSeconds = defaultSMTPTimeoutSeconds
+	}
+	return &resolved
+}
+
+// validate 校验 SMTP 配置，未配置 host 时不检查
+func (s *SMTPSettings) validate() []string {
+	var problems []string
+
+	if s.Host == "" {
+		return nil
+	}
+	switch s.Security {
+	case "", SMTPSecurityStartTLS, SMTPSecurityTLS, SMTPSecurityNone:
+	default:
+		problems = append(problems, fmt.Sprintf("delivery.smtp.security 只支持 starttls、tls 和 none，当前为 %q", s.Security))
+	}
+	if s.Port < 0 || s.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("delivery.smtp.port 无效: %d", s.Port))
+	}
+	if s.From == "" {
+		problems = append(problems, "delivery.smtp.from 不能为空")
+	} else if _, err := mail.ParseAddress(s.From); err != nil {
+		problems = append(problems, fmt.Sprintf("delivery.smtp.from 不是有效的邮箱地址: %v", err))
+	}
+	if s.TimeoutSeconds < 0 {
+		problems = append(problems, "delivery.smtp.timeout_seconds 不能为负数")
+	}
+
+	return problems
+}
+
+// validateEmailChannel 校验邮件渠道的收件人和主题模板
+func validateEmailChannel(name string, cfg *ChannelConfig, settings *SMTPSettings) []string {
+	var problems []string
+
+	if settings.Host == "" {
+		problems = append(problems, fmt.Sprintf("delivery.channels.%s 是邮件渠道，需要配置 delivery.smtp", name))
+	}
+	if len(cfg.To) == 0 {
+		problems = append(problems, fmt.Sprintf("delivery.channels.%s.to 不能为空", name))
+	}
+	for _, address := range append(append(append([]string{}, cfg.To...), cfg.Cc...), cfg.Bcc...) {
+		if _, err := mail.ParseAddress(address); err != nil {
+			problems = append(problems, fmt.Sprintf("delivery.channels.%s 中收件人 %q 无效", name, address))
+		}
+	}
+	if cfg.Subject != "" {
+		// 用空数据试渲染一次，提前发现拼错的变量名
+		tmpl, err := template.New(name).Parse(cfg.Subject)
+		if err == nil {
+			err = tmpl.Execute(io.Discard, EmailSubjectData{})
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("delivery.channels.%s.subject 无效: %v", name, err))
+		}
+	}
+
+	return problems
+}
+
+// send 连接服务器并发送一封邮件
+func (s *SMTPSettings) send(ctx context.Context, recipients []string, data []byte) error {
+	timeout := time.Duration(s.TimeoutSeconds) * time.Second
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		timeout = time.Until(deadline)
+	}
+
+	address := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	tlsConfig := &tls.Config{ServerName: s.Host, InsecureSkipVerify: s.InsecureSkipVerify}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if s.Security == SMTPSecurityTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server %s: %v", address, err)
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %v", err)
+	}
+	defer client.Close()
+
+	if s.Security == SMTPSecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP server %s does not support STARTTLS", address)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS failed: %v", err)
+		}
+	}
+
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %v", err)
+		}
+	}
+
+	from, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender %q: %v", s.From, err)
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM failed: %v", err)
+	}
+	for _, recipient := range recipients {
+		to, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return fmt.Errorf("invalid recipient %q: %v", recipient, err)
+		}
+		if err := client.Rcpt(to.Address); err != nil {
+			return fmt.Errorf("SMTP RCPT TO %s failed: %v", to.Address, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA failed: %v", err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to write message: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("SMTP server rejected message: %v", err)
+	}
+
+	return client.Quit()
+}
+
+// buildEmail 生成邮件内容，有 HTML 时为 multipart/alternative，否则为纯文本
+// 正文使用 quoted-printable 编码，非 ASCII 的主题和名称使用 RFC 2047 编码
+func buildEmail(from string, to, cc []string, subject string, msg *DeliveryMessage) ([]byte, error) {
+	var buf bytes.Buffer
+
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("发件人无效: %v", err)
+	}
+	header("From", sender.String())
+	for _, field := range []struct {
+		key  string
+		list []string
+	}{{"To", to}, {"Cc", cc}} {
+		if len(field.list) == 0 {
+			continue
+		}
+		addresses := make([]string, 0, len(field.list))
+		for _, address := range field.list {
+			parsed, err := mail.ParseAddress(address)
+			if err != nil {
+				return nil, fmt.Errorf("收件人 %q 无效: %v", address, err)
+			}
+			addresses = append(addresses, parsed.String())
+		}
+		header(field.key, strings.Join(addresses, ", "))
+	}
+	header("Subject", mime.BEncoding.Encode("UTF-8", subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID(sender.Address))
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		header("Content-Type", "text/plain; charset=UTF-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, msg.Markdown); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	header("Content-Type", "multipart/alternative; boundary="+writer.Boundary())
+	buf.WriteString("\r\n")
+
+	// 邮件客户端优先显示最后一个能够展示的部分，HTML 放在最后
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", msg.Markdown},
+		{"text/html; charset=UTF-8", msg.HTML},
+	} {
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+// writeQuotedPrintable 以 CRLF 换行写入 quoted-printable 编码的正文
+func writeQuotedPrintable(w io.Writer, content string) error {
+	qp := quotedprintable.NewWriter(w)
+	content = strings.ReplaceAll(strings.ReplaceAll(content, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// messageID 生成 Message-ID，域名取自发件人地址
+func messageID(sender string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(sender, "@"); at >= 0 {
+		domain = sender[at+1:]
+	}
+	random := make([]byte, 12)
+	rand.Read(random)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(random), domain)
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpSession 假 SMTP 服务器记录的一次会话
+type smtpSession struct {
+	startTLS   bool     // 是否执行了 STARTTLS
+	authTLS    bool     // AUTH 是否在加密连接上进行
+	auth       string   // AUTH PLAIN 解码后的凭据，\x00 分隔
+	from       string   // MAIL FROM 的地址
+	recipients []string // RCPT TO 的地址
+	data       []byte   // DATA 的内容
+}
+
+// fakeSMTPServer 只接受一个连接的 SMTP 服务器，支持 STARTTLS 和 AUTH PLAIN
+// 会话结束后把记录发送到返回的通道
+func fakeSMTPServer(t *testing.T, tlsConfig *tls.Config) (int, <-chan *smtpSession) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	sessions := make(chan *smtpSession, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+		session := &smtpSession{}
+		defer func() { sessions <- session }()
+
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 fake.example.com ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			verb, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(verb) {
+			case "EHLO", "HELO":
+				extensions := []string{"fake.example.com", "AUTH PLAIN"}
+				if tlsConfig != nil && !session.startTLS {
+					extensions = append(extensions, "STARTTLS")
+				}
+				for i, ext := range extensions {
+					separator := "-"
+					if i == len(extensions)-1 {
+						separator = " "
+					}
+					text.PrintfLine("250%s%s", separator, ext)
+				}
+			case "STARTTLS":
+				text.PrintfLine("220 ready to start TLS")
+				tlsConn := tls.Server(conn, tlsConfig)
+				if err := tlsConn.Handshake(); err != nil {
+					return
+				}
+				session.startTLS = true
+				text = textproto.NewConn(tlsConn)
+			case "AUTH":
+				mechanism, initial, _ := strings.Cut(arg, " ")
+				decoded, err := base64.StdEncoding.DecodeString(initial)
+				if mechanism != "PLAIN" || err != nil {
+					text.PrintfLine("504 unsupported")
+					continue
+				}
+				session.auth = string(decoded)
+				session.authTLS = session.startTLS
+				text.PrintfLine("235 authenticated")
+			case "MAIL":
+				session.from = smtpPath(arg)
+				text.PrintfLine("250 ok")
+			case "RCPT":
+				session.recipients = append(session.recipients, smtpPath(arg))
+				text.PrintfLine("250 ok")
+			case "DATA":
+				text.PrintfLine("354 end with .")
+				if session.data, err = text.ReadDotBytes(); err != nil {
+					return
+				}
+				text.PrintfLine("250 queued")
+			case "QUIT":
+				text.PrintfLine("221 bye")
+				return
+			default:
+				text.PrintfLine("502 unknown command")
+			}
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port, sessions
+}
+
+// smtpPath 取出 "FROM:<a@b>" 或 "TO:<a@b>" 中的地址
+func smtpPath(arg string) string {
+	start, end := strings.Index(arg, "<"), strings.LastIndex(arg, ">")
+	if start < 0 || end < start {
+		return arg
+	}
+	return arg[start+1 : end]
+}
+
+// selfSignedTLSConfig 生成 localhost 的自签名证书
+func selfSignedTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("生成密钥失败: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("生成证书失败: %v", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+var testEmailChannel = &ChannelConfig{
+	Type:    ChannelEmail,
+	To:      []string{"张三 <zhangsan@example.com>"},
+	Cc:      []string{"lead@example.com"},
+	Bcc:     []string{"archive@example.com"},
+	Subject: "{{.Author}} {{.TypeName}} {{.Period}}",
+}
+
+var testEmailMessage = &DeliveryMessage{
+	Title:    "张三 的工作周报",
+	Markdown: "# 周报\n\n- 修复登录问题\n- 新增导出功能",
+	HTML:     "<h1>周报</h1><ul><li>修复登录问题</li></ul>",
+	Author:   "张三",
+	Period:   "2026-10-12 至 2026-10-18",
+	Type:     "weekly",
+	Date:     "2026-10-17",
+}
+
+func TestEmailSend(t *testing.T) {
+	tests := []struct {
+		name      string
+		security  string
+		tlsConfig *tls.Config
+	}{
+		{"STARTTLS", SMTPSecurityStartTLS, selfSignedTLSConfig(t)},
+		{"不加密", SMTPSecurityNone, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port, sessions := fakeSMTPServer(t, tt.tlsConfig)
+			settings := &DeliverySettings{
+				Channels: map[string]*ChannelConfig{"mail": testEmailChannel},
+				SMTP: SMTPSettings{
+					Host:               "localhost",
+					Port:               port,
+					Security:           tt.security,
+					Username:           "report@example.com",
+					Password:           "secret",
+					From:               "周报机器人 <report@example.com>",
+					InsecureSkipVerify: true,
+					TimeoutSeconds:     5,
+				},
+			}
+			channel, err := settings.NewChannel("mail")
+			if err != nil {
+				t.Fatalf("NewChannel 返回错误: %v", err)
+			}
+
+			sent, err := channel.Send(context.Background(), testEmailMessage)
+			if err != nil {
+				t.Fatalf("Send 返回错误: %v", err)
+			}
+			if sent != 1 {
+				t.Errorf("发送条数 = %d, 期望 1", sent)
+			}
+
+			session := <-sessions
+			if session.startTLS != (tt.security == SMTPSecurityStartTLS) {
+				t.Errorf("STARTTLS = %v", session.startTLS)
+			}
+			if session.authTLS != session.startTLS {
+				t.Errorf("AUTH 是否加密 = %v, 期望 %v", session.authTLS, session.startTLS)
+			}
+			if session.auth != "\x00report@example.com\x00secret" {
+				t.Errorf("AUTH PLAIN = %q", session.auth)
+			}
+			if session.from != "report@example.com" {
+				t.Errorf("MAIL FROM = %q", session.from)
+			}
+			wantRecipients := []string{"zhangsan@example.com", "lead@example.com", "archive@example.com"}
+			if strings.Join(session.recipients, ",") != strings.Join(wantRecipients, ",") {
+				t.Errorf("RCPT TO = %q, 期望 %q", session.recipients, wantRecipients)
+			}
+
+			message, err := mail.ReadMessage(bytes.NewReader(session.data))
+			if err != nil {
+				t.Fatalf("解析邮件失败: %v", err)
+			}
+			if message.Header.Get("Bcc") != "" || bytes.Contains(session.data, []byte("archive@example.com")) {
+				t.Error("邮件内容中不应出现密送地址")
+			}
+			subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+			if err != nil || subject != "张三 周报 2026-10-12 至 2026-10-18" {
+				t.Errorf("Subject = %q (%v)", subject, err)
+			}
+		})
+	}
+}
+
+func TestEmailSendRequiresSTARTTLS(t *testing.T) {
+	port, _ := fakeSMTPServer(t, nil)
+	smtpSettings := (&SMTPSettings{Host: "localhost", Port: port, From: "report@example.com", TimeoutSeconds: 5}).resolve()
+
+	err := smtpSettings.send(context.Background(), []string{"zhangsan@example.com"}, []byte("Subject: x\r\n\r\nbody\r\n"))
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("服务器不支持 STARTTLS 时应返回错误，实际为 %v", err)
+	}
+}
+
+func TestBuildEmail(t *testing.T) {
+	data, err := buildEmail("周报机器人 <report@example.com>", testEmailChannel.To, testEmailChannel.Cc, "张三 的工作周报", testEmailMessage)
+	if err != nil {
+		t.Fatalf("buildEmail 返回错误: %v", err)
+	}
+
+	message, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("解析邮件失败: %v", err)
+	}
+
+	// 非 ASCII 的主题使用 RFC 2047 编码
+	rawSubject := message.Header.Get("Subject")
+	if !strings.HasPrefix(rawSubject, "=?UTF-8?b?") {
+		t.Errorf("Subject 未按 RFC 2047 编码: %q", rawSubject)
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(rawSubject); subject != "张三 的工作周报" {
+		t.Errorf("Subject = %q", subject)
+	}
+
+	from, err := message.Header.AddressList("From")
+	if err != nil || len(from) != 1 || from[0].Name != "周报机器人" || from[0].Address != "report@example.com" {
+		t.Errorf("From = %v (%v)", from, err)
+	}
+	to, err := message.Header.AddressList("To")
+	if err != nil || len(to) != 1 || to[0].Name != "张三" {
+		t.Errorf("To = %v (%v)", to, err)
+	}
+	if cc := message.Header.Get("Cc"); cc != "<lead@example.com>" {
+		t.Errorf("Cc = %q", cc)
+	}
+	if message.Header.Get("Bcc") != "" {
+		t.Error("不应包含 Bcc 头")
+	}
+	if !strings.HasSuffix(message.Header.Get("Message-ID"), "@example.com>") {
+		t.Errorf("Message-ID = %q", message.Header.Get("Message-ID"))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q (%v)", message.Header.Get("Content-Type"), err)
+	}
+
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	want := []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", strings.ReplaceAll(testEmailMessage.Markdown, "\n", "\r\n")},
+		{"text/html; charset=UTF-8", testEmailMessage.HTML},
+	}
+	for i, w := range want {
+		part, err := reader.NextRawPart()
+		if err != nil {
+			t.Fatalf("读取第 %d 部分失败: %v", i+1, err)
+		}
+		if got := part.Header.Get("Content-Type"); got != w.contentType {
+			t.Errorf("第 %d 部分 Content-Type = %q, 期望 %q", i+1, got, w.contentType)
+		}
+		if got := part.Header.Get("Content-Transfer-Encoding"); got != "quoted-printable" {
+			t.Errorf("第 %d 部分 Content-Transfer-Encoding = %q", i+1, got)
+		}
+		content, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatalf("解码第 %d 部分失败: %v", i+1, err)
+		}
+		if string(content) != w.content {
+			t.Errorf("第 %d 部分内容 = %q, 期望 %q", i+1, content, w.content)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("期望只有两个部分，实际 %v", err)
+	}
+}
+
+func TestBuildEmailPlainText(t *testing.T) {
+	msg := &DeliveryMessage{Markdown: "第一行\n第二行 = 等号"}
+	data, err := buildEmail("report@example.com", []string{"zhangsan@example.com"}, nil, "Weekly report", msg)
+	if err != nil {
+		t.Fatalf("buildEmail 返回错误: %v", err)
+	}
+
+	message, err := mail.ReadMessage(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatalf("解析邮件失败: %v", err)
+	}
+	if got := message.Header.Get("Content-Type"); got != "text/plain; charset=UTF-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if message.Header.Get("Cc") != "" {
+		t.Error("没有抄送时不应包含 Cc 头")
+	}
+	if subject := message.Header.Get("Subject"); subject != "Weekly report" {
+		t.Errorf("ASCII 主题不需要编码: %q", subject)
+	}
+	content, _ := io.ReadAll(quotedprintable.NewReader(message.Body))
+	if string(content) != "第一行\r\n第二行 = 等号" {
+		t.Errorf("正文 = %q", content)
+	}
+}
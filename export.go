@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReportSchemaVersion 结构化导出的 schema 版本
+// 只增加字段时保持不变，字段改名、删除或含义变化时加一
+const ReportSchemaVersion = 1
+
+// ReportExport 报告的结构化导出，字段名独立于内部结构，保持稳定
+// 提交只在 commits 中完整出现一次，分类、模块、破坏性变更和成员中以完整哈希引用
+type ReportExport struct {
+	SchemaVersion int             `json:"schema_version" yaml:"schema_version"`
+	Type          string          `json:"type" yaml:"type"`
+	Period        string          `json:"period" yaml:"period"`
+	Since         time.Time       `json:"since" yaml:"since"`
+	Until         time.Time       `json:"until" yaml:"until"`
+	Author        string          `json:"author" yaml:"author"`
+	Team          bool            `json:"team" yaml:"team"`
+	GeneratedAt   time.Time       `json:"generated_at" yaml:"generated_at"`
+	Repos         []RepoExport    `json:"repos" yaml:"repos"`
+	Summary       SummaryExport   `json:"summary" yaml:"summary"`
+	Categories    []GroupExport   `json:"categories" yaml:"categories"`
+	Scopes        []GroupExport   `json:"scopes" yaml:"scopes"`
+	Breaking      []string        `json:"breaking" yaml:"breaking"`
+	Members       []MemberExport  `json:"members" yaml:"members"`
+	Rankings      []RankingExport `json:"rankings" yaml:"rankings"`
+	OKR           *OKRExport      `json:"okr" yaml:"okr"`
+	Narrative     string          `json:"narrative" yaml:"narrative"`
+	Commits       []CommitExport  `json:"commits" yaml:"commits"`
+}
+
+// RepoExport 仓库小计
+type RepoExport struct {
+	Name      string `json:"name" yaml:"name"`
+	Path      string `json:"path" yaml:"path"`
+	Branch    string `json:"branch" yaml:"branch"`
+	URL       string `json:"url" yaml:"url"`
+	Commits   int    `json:"commits" yaml:"commits"`
+	Files     int    `json:"files" yaml:"files"`
+	Additions int    `json:"additions" yaml:"additions"`
+	Deletions int    `json:"deletions" yaml:"deletions"`
+	Error     string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// SummaryExport 统计摘要，时间分布的键与 ReportSummary 相同
+type SummaryExport struct {
+	TotalCommits   int               `json:"total_commits" yaml:"total_commits"`
+	TotalFiles     int               `json:"total_files" yaml:"total_files"`
+	TotalAdditions int               `json:"total_additions" yaml:"total_additions"`
+	TotalDeletions int               `json:"total_deletions" yaml:"total_deletions"`
+	FileTypes      map[string]int    `json:"file_types" yaml:"file_types"`
+	DailyStats     map[string]int    `json:"daily_stats" yaml:"daily_stats"`
+	WeeklyStats    map[string]int    `json:"weekly_stats" yaml:"weekly_stats"`
+	MonthlyStats   map[string]int    `json:"monthly_stats" yaml:"monthly_stats"`
+	TopFiles       []FileCountExport `json:"top_files" yaml:"top_files"`
+}
+
+// FileCountExport 文件修改次数
+type FileCountExport struct {
+	Path  string `json:"path" yaml:"path"`
+	Count int    `json:"count" yaml:"count"`
+}
+
+// GroupExport 按分类或模块分组的提交
+type GroupExport struct {
+	Name    string   `json:"name" yaml:"name"`
+	Count   int      `json:"count" yaml:"count"`
+	Commits []string `json:"commits" yaml:"commits"`
+}
+
+// MemberExport 团队成员
+type MemberExport struct {
+	Name       string        `json:"name" yaml:"name"`
+	Summary    SummaryExport `json:"summary" yaml:"summary"`
+	Categories []GroupExport `json:"categories" yaml:"categories"`
+}
+
+// RankingExport 团队排行榜
+type RankingExport struct {
+	Title   string            `json:"title" yaml:"title"`
+	Entries []RankEntryExport `json:"entries" yaml:"entries"`
+}
+
+// RankEntryExport 排行榜条目
+type RankEntryExport struct {
+	Rank  int    `json:"rank" yaml:"rank"`
+	Name  string `json:"name" yaml:"name"`
+	Value int    `json:"value" yaml:"value"`
+}
+
+// OKRExport OKR进度
+type OKRExport struct {
+	Period     string            `json:"period" yaml:"period"`
+	Objectives []ObjectiveExport `json:"objectives" yaml:"objectives"`
+	Unmapped   []string          `json:"unmapped" yaml:"unmapped"`
+}
+
+// ObjectiveExport 目标进度
+type ObjectiveExport struct {
+	ID         string     `json:"id" yaml:"id"`
+	Title      string     `json:"title" yaml:"title"`
+	Progress   float64    `json:"progress" yaml:"progress"`
+	KeyResults []KRExport `json:"key_results" yaml:"key_results"`
+}
+
+// KRExport 关键结果进度
+type KRExport struct {
+	ID       string   `json:"id" yaml:"id"`
+	Title    string   `json:"title" yaml:"title"`
+	Metric   string   `json:"metric" yaml:"metric"`
+	Target   float64  `json:"target" yaml:"target"`
+	Value    float64  `json:"value" yaml:"value"`
+	Progress float64  `json:"progress" yaml:"progress"`
+	Commits  []string `json:"commits" yaml:"commits"`
+}
+
+// CommitExport 提交记录
+type CommitExport struct {
+	Hash           string             `json:"hash" yaml:"hash"`
+	Repo           string             `json:"repo" yaml:"repo"`
+	Author         string             `json:"author" yaml:"author"`
+	Email          string             `json:"email" yaml:"email"`
+	Date           time.Time          `json:"date" yaml:"date"`
+	Committer      string             `json:"committer" yaml:"committer"`
+	CommitterEmail string             `json:"committer_email" yaml:"committer_email"`
+	CommitDate     time.Time          `json:"commit_date" yaml:"commit_date"`
+	Message        string             `json:"message" yaml:"message"`
+	Body           string             `json:"body" yaml:"body"`
+	Type           string             `json:"type" yaml:"type"`
+	Scope          string             `json:"scope" yaml:"scope"`
+	Description    string             `json:"description" yaml:"description"`
+	Breaking       bool               `json:"breaking" yaml:"breaking"`
+	BreakingNote   string             `json:"breaking_note" yaml:"breaking_note"`
+	Categories     []string           `json:"categories" yaml:"categories"`
+	Trailers       []TrailerExport    `json:"trailers" yaml:"trailers"`
+	CoAuthors      []IdentityExport   `json:"co_authors" yaml:"co_authors"`
+	Additions      int                `json:"additions" yaml:"additions"`
+	Deletions      int                `json:"deletions" yaml:"deletions"`
+	Files          []FileChangeExport `json:"files" yaml:"files"`
+}
+
+// TrailerExport 提交信息末尾的 trailer
+type TrailerExport struct {
+	Key   string `json:"key" yaml:"key"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// IdentityExport Git用户身份
+type IdentityExport struct {
+	Name  string `json:"name" yaml:"name"`
+	Email string `json:"email" yaml:"email"`
+}
+
+// FileChangeExport 单个文件的变更
+type FileChangeExport struct {
+	Path      string `json:"path" yaml:"path"`
+	OldPath   string `json:"old_path,omitempty" yaml:"old_path,omitempty"`
+	Additions int    `json:"additions" yaml:"additions"`
+	Deletions int    `json:"deletions" yaml:"deletions"`
+	Binary    bool   `json:"binary" yaml:"binary"`
+}
+
+// NewReportExport 把报告转换为结构化导出，列表字段为空时输出空数组而不是 null
+func NewReportExport(report *Report) *ReportExport {
+	export := &ReportExport{
+		SchemaVersion: ReportSchemaVersion,
+		Type:          report.Type,
+		Period:        report.Period,
+		Since:         report.Since,
+		Until:         report.Until,
+		Author:        report.Author,
+		Team:          report.Team,
+		GeneratedAt:   report.GeneratedAt,
+		Repos:         []RepoExport{},
+		Summary:       exportSummary(report.Summary),
+		Categories:    exportGroups(report.Categories),
+		Scopes:        exportGroups(report.Scopes),
+		Breaking:      commitHashes(report.Breaking),
+		Members:       []MemberExport{},
+		Rankings:      []RankingExport{},
+		OKR:           exportOKR(report.OKR),
+		Narrative:     report.Narrative,
+		Commits:       []CommitExport{},
+	}
+
+	for _, repo := range report.Repos {
+		export.Repos = append(export.Repos, RepoExport{
+			Name:      repo.Name,
+			Path:      repo.Path,
+			Branch:    repo.Branch,
+			URL:       repo.URL,
+			Commits:   repo.Commits,
+			Files:     repo.Files,
+			Additions: repo.Additions,
+			Deletions: repo.Deletions,
+			Error:     repo.Error,
+		})
+	}
+
+	for _, member := range report.Members {
+		export.Members = append(export.Members, MemberExport{
+			Name:       member.Name,
+			Summary:    exportSummary(member.Summary),
+			Categories: exportGroups(member.Categories),
+		})
+	}
+
+	for _, ranking := range report.Rankings {
+		r := RankingExport{Title: ranking.Title, Entries: []RankEntryExport{}}
+		for _, entry := range ranking.Entries {
+			r.Entries = append(r.Entries, RankEntryExport{Rank: entry.Rank, Name: entry.Name, Value: entry.Value})
+		}
+		export.Rankings = append(export.Rankings, r)
+	}
+
+	// 提交所属的分类，按分类名排序
+	categories := make(map[*GitCommit][]string)
+	for _, group := range export.Categories {
+		for _, commit := range report.Categories[group.Name] {
+			categories[commit] = append(categories[commit], group.Name)
+		}
+	}
+
+	for _, commit := range report.Commits {
+		export.Commits = append(export.Commits, exportCommit(commit, categories[commit]))
+	}
+
+	return export
+}
+
+// exportSummary 转换统计摘要
+func exportSummary(summary *ReportSummary) SummaryExport {
+	export := SummaryExport{
+		FileTypes:    map[string]int{},
+		DailyStats:   map[string]int{},
+		WeeklyStats:  map[string]int{},
+		MonthlyStats: map[string]int{},
+		TopFiles:     []FileCountExport{},
+	}
+	if summary == nil {
+		return export
+	}
+
+	export.TotalCommits = summary.TotalCommits
+	export.TotalFiles = summary.TotalFiles
+	export.TotalAdditions = summary.TotalAdditions
+	export.TotalDeletions = summary.TotalDeletions
+	copyCounts(export.FileTypes, summary.FileTypes)
+	copyCounts(export.DailyStats, summary.DailyStats)
+	copyCounts(export.WeeklyStats, summary.WeeklyStats)
+	copyCounts(export.MonthlyStats, summary.MonthlyStats)
+	for _, file := range summary.TopFileCounts {
+		export.TopFiles = append(export.TopFiles, FileCountExport{Path: file.Path, Count: file.Count})
+	}
+
+	return export
+}
+
+// copyCounts 复制计数
+func copyCounts(dst, src map[string]int) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
+// exportGroups 把分组转换为按名称排序的列表
+func exportGroups(groups map[string][]*GitCommit) []GroupExport {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	export := make([]GroupExport, 0, len(names))
+	for _, name := range names {
+		export = append(export, GroupExport{Name: name, Count: len(groups[name]), Commits: commitHashes(groups[name])})
+	}
+	return export
+}
+
+// commitHashes 返回提交的完整哈希
+func commitHashes(commits []*GitCommit) []string {
+	hashes := make([]string, 0, len(commits))
+	for _, commit := range commits {
+		hashes = append(hashes, commit.Hash)
+	}
+	return hashes
+}
+
+// exportOKR 转换OKR进度，未指定OKR时返回 nil
+func exportOKR(progress *OKRProgress) *OKRExport {
+	if progress == nil {
+		return nil
+	}
+
+	export := &OKRExport{
+		Period:     progress.Period,
+		Objectives: []ObjectiveExport{},
+		Unmapped:   commitHashes(progress.Unmapped),
+	}
+	for _, obj := range progress.Objectives {
+		o := ObjectiveExport{
+			ID:         obj.Objective.ID,
+			Title:      obj.Objective.Title,
+			Progress:   obj.Progress,
+			KeyResults: []KRExport{},
+		}
+		for _, kr := range obj.KeyResults {
+			o.KeyResults = append(o.KeyResults, KRExport{
+				ID:       kr.KeyResult.ID,
+				Title:    kr.KeyResult.Title,
+				Metric:   kr.KeyResult.Metric,
+				Target:   kr.KeyResult.Target,
+				Value:    kr.Value,
+				Progress: kr.Progress,
+				Commits:  commitHashes(kr.Commits),
+			})
+		}
+		export.Objectives = append(export.Objectives, o)
+	}
+
+	return export
+}
+
+// exportCommit 转换提交记录
+func exportCommit(commit *GitCommit, categories []string) CommitExport {
+	export := CommitExport{
+		Hash:           commit.Hash,
+		Repo:           commit.Repo,
+		Author:         commit.Author,
+		Email:          commit.Email,
+		Date:           commit.Date,
+		Committer:      commit.Committer,
+		CommitterEmail: commit.CommitterEmail,
+		CommitDate:     commit.CommitDate,
+		Message:        commit.Message,
+		Body:           commit.Body,
+		Type:           commit.Type,
+		Scope:          commit.Scope,
+		Description:    commit.Description,
+		Breaking:       commit.Breaking,
+		BreakingNote:   commit.BreakingNote,
+		Categories:     append([]string{}, categories...),
+		Trailers:       []TrailerExport{},
+		CoAuthors:      []IdentityExport{},
+		Additions:      commit.Additions,
+		Deletions:      commit.Deletions,
+		Files:          []FileChangeExport{},
+	}
+
+	for _, trailer := range commit.Trailers {
+		export.Trailers = append(export.Trailers, TrailerExport{Key: trailer.Key, Value: trailer.Value})
+	}
+	for _, identity := range commit.CoAuthors {
+		export.CoAuthors = append(export.CoAuthors, IdentityExport{Name: identity.Name, Email: identity.Email})
+	}
+
+	// 没有逐文件统计时只有路径
+	if len(commit.Changes) > 0 {
+		for _, change := range commit.Changes {
+			export.Files = append(export.Files, FileChangeExport{
+				Path:      change.Path,
+				OldPath:   change.OldPath,
+				Additions: change.Additions,
+				Deletions: change.Deletions,
+				Binary:    change.Binary,
+			})
+		}
+	} else {
+		for _, file := range commit.Files {
+			export.Files = append(export.Files, FileChangeExport{Path: file})
+		}
+	}
+
+	return export
+}
+
+// MarshalReport 把报告序列化为 JSON 或 YAML
+func MarshalReport(report *Report, format string) ([]byte, error) {
+	export := NewReportExport(report)
+
+	switch format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(export, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("序列化JSON失败: %v", err)
+		}
+		return append(data, '\n'), nil
+	case FormatYAML:
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(export); err != nil {
+			return nil, fmt.Errorf("序列化YAML失败: %v", err)
+		}
+		encoder.Close()
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("不支持的导出格式: %s", format)
+	}
+}
@@ -1,20 +1,130 @@
 'use client'
 
-import { useState } from 'react'
-import { GitBranch, Calendar, FileText, Download, Loader2, Edit3, Save, X, Sparkles } from 'lucide-react'
+import { useEffect, useState } from 'react'
+import { GitBranch, Calendar, FileText, Download, Loader2, Edit3, Save, X, Sparkles, Send } from 'lucide-react'
 import axios from 'axios'
 
+type ReportType = 'daily' | 'weekly' | 'monthly' | 'quarterly' | 'yearly' | 'range'
+
+const reportTypeNames: Record<ReportType, string> = {
+  daily: '日报',
+  weekly: '周报',
+  monthly: '月报',
+  quarterly: '季报',
+  yearly: '年报',
+  range: '区间报告'
+}
+
 interface ReportData {
   content: string
-  type: 'daily' | 'weekly'
+  type: ReportType
   date: string
+  reportId?: number // 报告历史中的编号，服务器未配置 history_file 时为空
+}
+
+interface PromptInfo {
+  name: string
+  title: string
+  description: string
+  source: 'builtin' | 'custom'
+}
+
+interface TemplateInfo {
+  name: string
+  description: string
+  default: boolean
+}
+
+interface ChannelInfo {
+  name: string
+  type: string
+}
+
+interface DeliveryResult {
+  channel: string
+  parts: number
+  error?: string
+}
+
+interface OptimizeOptions {
+  prompt: string
+  type?: ReportType
+  reportId?: number // 优化结果保存为该报告的修订
+}
+
+// saveBlob 把内容作为文件下载
+function saveBlob(blob: Blob, filename: string) {
+  const url = URL.createObjectURL(blob)
+  const a = document.createElement('a')
+  a.href = url
+  a.download = filename
+  document.body.appendChild(a)
+  a.click()
+  document.body.removeChild(a)
+  URL.revokeObjectURL(url)
+}
+
+// streamOptimize 调用流式优化接口，每收到增量内容就以累计结果调用 onProgress，返回完整结果
+async function streamOptimize(content: string, options: OptimizeOptions, onProgress: (content: string) => void): Promise<string> {
+  const response = await fetch('/api/optimize-report/stream', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ content, ...options })
+  })
+
+  if (!response.ok || !response.body) {
+    const data = await response.json().catch(() => null)
+    throw new Error(data?.error || 'AI优化时发生错误')
+  }
+
+  const reader = response.body.getReader()
+  const decoder = new TextDecoder()
+  let buffer = ''
+  let result = ''
+
+  while (true) {
+    const { done, value } = await reader.read()
+    if (done) break
+    buffer += decoder.decode(value, { stream: true })
+
+    // 事件之间以空行分隔
+    let index
+    while ((index = buffer.indexOf('\n\n')) >= 0) {
+      const block = buffer.slice(0, index)
+      buffer = buffer.slice(index + 2)
+
+      let event = 'message'
+      let data = ''
+      for (const line of block.split('\n')) {
+        if (line.startsWith('event:')) event = line.slice(6).trim()
+        if (line.startsWith('data:')) data += line.slice(5).trim()
+      }
+      if (!data) continue
+
+      const payload = JSON.parse(data)
+      if (event === 'progress') {
+        // 长报告先分段整理，整理完成前显示进度
+        onProgress(`正在分段整理长报告（${payload.done}/${payload.total}）...`)
+      } else if (event === 'delta') {
+        result += payload.content
+        onProgress(result)
+      } else if (event === 'done') {
+        return payload.optimizedContent
+      } else if (event === 'error') {
+        throw new Error(payload.error)
+      }
+    }
+  }
+
+  return result
 }
 
 export default function Home() {
   const [activeTab, setActiveTab] = useState<'generate' | 'polish'>('generate')
   const [repoPath, setRepoPath] = useState('')
-  const [reportType, setReportType] = useState<'daily' | 'weekly'>('daily')
+  const [reportType, setReportType] = useState<ReportType>('daily')
   const [selectedDate, setSelectedDate] = useState(new Date().toISOString().split('T')[0])
+  const [sinceDate, setSinceDate] = useState('')
   const [loading, setLoading] = useState(false)
   const [report, setReport] = useState<ReportData | null>(null)
   const [error, setError] = useState('')
@@ -23,6 +133,63 @@ export default function Home() {
   const [isOptimizing, setIsOptimizing] = useState(false)
   const [polishContent, setPolishContent] = useState('')
   const [polishedResult, setPolishedResult] = useState('')
+  const [prompts, setPrompts] = useState<PromptInfo[]>([])
+  const [promptName, setPromptName] = useState('default')
+  const [templates, setTemplates] = useState<TemplateInfo[]>([])
+  const [templateName, setTemplateName] = useState('')
+  const [channels, setChannels] = useState<ChannelInfo[]>([])
+  const [channelName, setChannelName] = useState('')
+  const [isDelivering, setIsDelivering] = useState(false)
+  const [deliveryNotice, setDeliveryNotice] = useState('')
+
+  // 加载可用的提示词模板，失败时只使用默认提示词
+  useEffect(() => {
+    axios.get('/api/prompts')
+      .then((response) => setPrompts(response.data.prompts || []))
+      .catch(() => setPrompts([]))
+    axios.get('/api/templates')
+      .then((response) => setTemplates(response.data.templates || []))
+      .catch(() => setTemplates([]))
+    axios.get('/api/channels')
+      .then((response) => {
+        const list: ChannelInfo[] = response.data.channels || []
+        setChannels(list)
+        if (list.length > 0) setChannelName(list[0].name)
+      })
+      .catch(() => setChannels([]))
+  }, [])
+
+  const promptSelect = (
+    <div>
+      <label htmlFor="promptName" className="block text-sm font-medium text-gray-700 mb-2">
+        <Sparkles className="inline w-4 h-4 mr-1" />
+        AI优化风格
+      </label>
+      <select
+        id="promptName"
+        value={promptName}
+        onChange={(e) => setPromptName(e.target.value)}
+        className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500 focus:border-transparent"
+      >
+        {prompts.length === 0 && <option value="default">默认</option>}
+        {prompts.map((prompt) => (
+          <option key={prompt.name} value={prompt.name} title={prompt.description}>
+            {prompt.title}{prompt.source === 'custom' ? '（自定义）' : ''}
+          </option>
+        ))}
+      </select>
+    </div>
+  )
+
+  // reportRequest 返回生成报告接口的请求参数
+  const reportRequest = (format?: string) => ({
+    repoPath: repoPath.trim(),
+    type: reportType,
+    date: selectedDate,
+    ...(templateName ? { template: templateName } : {}),
+    ...(format ? { format } : {}),
+    ...(reportType === 'range' ? { since: sinceDate, until: selectedDate } : {})
+  })
 
   const generateReport = async () => {
     if (!repoPath.trim()) {
@@ -30,16 +197,18 @@ export default function Home() {
       return
     }
 
+    if (reportType === 'range' && !sinceDate) {
+      setError('请选择开始日期')
+      return
+    }
+
     setLoading(true)
     setError('')
     setReport(null)
+    setDeliveryNotice('')
 
     try {
-      const response = await axios.post('/api/generate-report', {
-        repoPath: repoPath.trim(),
-        type: reportType,
-        date: selectedDate
-      })
+      const response = await axios.post('/api/generate-report', reportRequest())
 
       setReport(response.data)
     } catch (err: any) {
@@ -53,15 +222,66 @@ export default function Home() {
     if (!report) return
 
     const content = isEditing ? editedContent : report.content
-    const blob = new Blob([content], { type: 'text/markdown' })
-    const url = URL.createObjectURL(blob)
-    const a = document.createElement('a')
-    a.href = url
-    a.download = `${report.type}-report-${report.date}.md`
-    document.body.appendChild(a)
-    a.click()
-    document.body.removeChild(a)
-    URL.revokeObjectURL(url)
+    saveBlob(new Blob([content], { type: 'text/markdown' }), `${report.type}-report-${report.date}.md`)
+  }
+
+  // downloadHTML 以相同参数重新生成 HTML 格式的报告并下载，图表内嵌在文件中
+  const downloadHTML = async () => {
+    if (!report) return
+
+    try {
+      const response = await axios.post('/api/generate-report', reportRequest('html'))
+      saveBlob(new Blob([response.data.content], { type: 'text/html' }), `${report.type}-report-${report.date}.html`)
+    } catch (err: any) {
+      setError(err.response?.data?.error || '生成 HTML 报告时发生错误')
+    }
+  }
+
+  // deliverReport 把当前报告（编辑中时为编辑后的内容）推送到选择的渠道
+  // 邮件渠道在报告未编辑时附带 HTML 版本
+  const deliverReport = async () => {
+    if (!report || !channelName) return
+
+    setIsDelivering(true)
+    setError('')
+    setDeliveryNotice('')
+
+    try {
+      let html = ''
+      const channel = channels.find((c) => c.name === channelName)
+      if (channel?.type === 'email' && !isEditing) {
+        const htmlResponse = await axios.post('/api/generate-report', reportRequest('html'))
+        html = htmlResponse.data.content
+      }
+
+      const response = await axios.post('/api/deliver', {
+        content: isEditing ? editedContent : report.content,
+        ...(html ? { html } : {}),
+        type: report.type,
+        date: report.date,
+        channels: [channelName]
+      })
+      const results: DeliveryResult[] = response.data.results || []
+      setDeliveryNotice(results.map((r) => `已推送到 ${r.channel}（${r.parts} 条消息）`).join('；'))
+    } catch (err: any) {
+      const results: DeliveryResult[] = err.response?.data?.results || []
+      const failed = results.find((r) => r.error)
+      setError(failed ? `推送到 ${failed.channel} 失败: ${failed.error}` : err.response?.data?.error || '推送报告时发生错误')
+    } finally {
+      setIsDelivering(false)
+    }
+  }
+
+  // downloadExcel 以相同参数导出 Excel 文件，每张统计表一个工作表
+  const downloadExcel = async () => {
+    if (!report) return
+
+    try {
+      const response = await axios.post('/api/generate-report', reportRequest('xlsx'), { responseType: 'blob' })
+      saveBlob(response.data, `${report.type}-report-${report.date}.xlsx`)
+    } catch (err: any) {
+      setError('导出 Excel 时发生错误')
+    }
   }
 
   const startEditing = () => {
@@ -70,10 +290,19 @@ export default function Home() {
     setIsEditing(true)
   }
 
-  const saveEdit = () => {
+  // saveEdit 保存编辑后的内容，服务器记录了报告历史时同时保存为一个修订
+  const saveEdit = async () => {
     if (!report) return
     setReport({ ...report, content: editedContent })
     setIsEditing(false)
+
+    if (report.reportId && editedContent !== report.content) {
+      try {
+        await axios.post(`/api/reports/${report.reportId}/revisions`, { content: editedContent })
+      } catch (err: any) {
+        setError(err.response?.data?.error || '保存修订时发生错误')
+      }
+    }
   }
 
   const cancelEdit = () => {
@@ -87,22 +316,22 @@ export default function Home() {
     setIsOptimizing(true)
     setError('')
 
-    try {
-      // 使用免费的大模型API进行优化
-      const response = await axios.post('/api/optimize-report', {
-        content: isEditing ? editedContent : report.content,
-        type: report.type
-      })
-
-      const optimizedContent = response.data.optimizedContent
-      
+    const original = isEditing ? editedContent : report.content
+    const showContent = (content: string) => {
       if (isEditing) {
-        setEditedContent(optimizedContent)
+        setEditedContent(content)
       } else {
-        setReport({ ...report, content: optimizedContent })
+        setReport({ ...report, content })
       }
+    }
+
+    try {
+      // 流式返回优化结果，边生成边显示
+      const optimizedContent = await streamOptimize(original, { prompt: promptName, type: report.type, reportId: report.reportId }, showContent)
+      showContent(optimizedContent)
     } catch (err: any) {
-      setError(err.response?.data?.error || 'AI优化时发生错误')
+      showContent(original)
+      setError(err.message || 'AI优化时发生错误')
     } finally {
       setIsOptimizing(false)
     }
@@ -119,14 +348,10 @@ export default function Home() {
     setPolishedResult('')
 
     try {
-      const response = await axios.post('/api/optimize-report', {
-        content: polishContent,
-        type: 'daily' // 默认类型，润色功能不依赖具体类型
-      })
-
-      setPolishedResult(response.data.optimizedContent)
+      const optimizedContent = await streamOptimize(polishContent, { prompt: promptName }, setPolishedResult)
+      setPolishedResult(optimizedContent)
     } catch (err: any) {
-      setError(err.response?.data?.error || '润色时发生错误')
+      setError(err.message || '润色时发生错误')
     } finally {
       setIsOptimizing(false)
     }
@@ -216,19 +441,61 @@ export default function Home() {
               <select
                 id="reportType"
                 value={reportType}
-                onChange={(e) => setReportType(e.target.value as 'daily' | 'weekly')}
+                onChange={(e) => setReportType(e.target.value as ReportType)}
                 className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500 focus:border-transparent"
               >
-                <option value="daily">日报</option>
-                <option value="weekly">周报</option>
+                {(Object.keys(reportTypeNames) as ReportType[]).map((type) => (
+                  <option key={type} value={type}>{reportTypeNames[type]}</option>
+                ))}
               </select>
             </div>
 
+            {/* 报告模板选择，默认使用服务器配置的模板 */}
+            <div>
+              <label htmlFor="templateName" className="block text-sm font-medium text-gray-700 mb-2">
+                <FileText className="inline w-4 h-4 mr-1" />
+                报告模板
+              </label>
+              <select
+                id="templateName"
+                value={templateName}
+                onChange={(e) => setTemplateName(e.target.value)}
+                className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500 focus:border-transparent"
+              >
+                <option value="">默认</option>
+                {templates.map((template) => (
+                  <option key={template.name} value={template.name} title={template.description}>
+                    {template.name}{template.description ? ` - ${template.description.split('：')[0]}` : ''}
+                  </option>
+                ))}
+              </select>
+            </div>
+
+            {/* AI优化使用的提示词 */}
+            {promptSelect}
+
+            {/* 区间报告的开始日期 */}
+            {reportType === 'range' && (
+              <div>
+                <label htmlFor="since" className="block text-sm font-medium text-gray-700 mb-2">
+                  <Calendar className="inline w-4 h-4 mr-1" />
+                  开始日期
+                </label>
+                <input
+                  type="date"
+                  id="since"
+                  value={sinceDate}
+                  onChange={(e) => setSinceDate(e.target.value)}
+                  className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500 focus:border-transparent"
+                />
+              </div>
+            )}
+
             {/* 日期选择 */}
             <div>
               <label htmlFor="date" className="block text-sm font-medium text-gray-700 mb-2">
                 <Calendar className="inline w-4 h-4 mr-1" />
-                目标日期
+                {reportType === 'range' ? '结束日期' : '目标日期'}
               </label>
               <input
                 type="date"
@@ -266,12 +533,18 @@ export default function Home() {
           </div>
         )}
 
+        {deliveryNotice && (
+          <div className="bg-green-50 border border-green-200 rounded-md p-4 mb-6">
+            <div className="text-green-800">{deliveryNotice}</div>
+          </div>
+        )}
+
             {/* 报告结果 */}
             {report && (
               <div className="bg-white rounded-lg shadow-md p-6">
                 <div className="flex justify-between items-center mb-4">
                   <h3 className="text-xl font-semibold text-gray-900">
-                    {report.type === 'daily' ? '日报' : '周报'} - {report.date}
+                    {reportTypeNames[report.type]} - {report.date}
                     {isEditing && <span className="ml-2 text-sm text-blue-600">(编辑模式)</span>}
                   </h3>
                   <div className="flex space-x-2">
@@ -342,6 +615,47 @@ export default function Home() {
                           <Download className="w-4 h-4 mr-2" />
                           下载
                         </button>
+                        <button
+                          onClick={downloadHTML}
+                          className="bg-teal-600 hover:bg-teal-700 text-white font-medium py-2 px-4 rounded-md transition duration-200 flex items-center"
+                        >
+                          <Download className="w-4 h-4 mr-2" />
+                          HTML
+                        </button>
+                        <button
+                          onClick={downloadExcel}
+                          className="bg-emerald-600 hover:bg-emerald-700 text-white font-medium py-2 px-4 rounded-md transition duration-200 flex items-center"
+                        >
+                          <Download className="w-4 h-4 mr-2" />
+                          Excel
+                        </button>
+                        {channels.length > 0 && (
+                          <>
+                            <select
+                              value={channelName}
+                              onChange={(e) => setChannelName(e.target.value)}
+                              className="px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500 focus:border-transparent"
+                            >
+                              {channels.map((channel) => (
+                                <option key={channel.name} value={channel.name}>
+                                  {channel.name}
+                                </option>
+                              ))}
+                            </select>
+                            <button
+                              onClick={deliverReport}
+                              disabled={isDelivering}
+                              className="bg-indigo-600 hover:bg-indigo-700 disabled:bg-indigo-400 text-white font-medium py-2 px-4 rounded-md transition duration-200 flex items-center"
+                            >
+                              {isDelivering ? (
+                                <Loader2 className="w-4 h-4 mr-2 animate-spin" />
+                              ) : (
+                                <Send className="w-4 h-4 mr-2" />
+                              )}
+                              推送
+                            </button>
+                          </>
+                        )}
                       </>
                     )}
                   </div>
@@ -368,6 +682,7 @@ export default function Home() {
           <>
             {/* 润色输入区域 */}
             <div className="bg-white rounded-lg shadow-md p-6 mb-8">
+              <div className="mb-4">{promptSelect}</div>
               <div className="mb-4">
                 <label htmlFor="polishContent" className="block text-sm font-medium text-gray-700 mb-2">
                   <Sparkles className="inline w-4 h-4 mr-1" />
@@ -3,7 +3,7 @@ package main
 import (
 	"fmt"
 	"os/exec"
-	"regexp"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -11,15 +11,57 @@ import (
 
 // GitCommit 表示一个Git提交
 type GitCommit struct {
-	Hash      string
-	Author    string
-	Date      time.Time
-	Message   string
-	Files     []string
+	Hash           string
+	Repo           string // 所属仓库名称
+	Author         string
+	Email          string       // 作者邮箱
+	Date           time.Time    // 作者时间
+	Committer      string       // 提交者（rebase、cherry-pick 后可能与作者不同）
+	CommitterEmail string       // 提交者邮箱
+	CommitDate     time.Time    // 提交时间
+	Message        string       // 提交标题
+	Body           string       // 提交正文（不含标题）
+	Trailers       []Trailer    // 正文末尾的 trailer，如 Signed-off-by、Refs
+	CoAuthors      []Identity   // Co-authored-by 中的共同作者
+	Type           string       // Conventional Commits 类型，如 feat、fix，不符合规范时为空
+	Scope          string       // Conventional Commits 范围
+	Description    string       // Conventional Commits 描述
+	Breaking       bool         // 是否包含破坏性变更
+	BreakingNote   string       // 破坏性变更说明
+	Files          []string     // 变更的文件路径（重命名时为新路径）
+	Changes        []FileChange // 每个文件的变更详情
+	Additions      int
+	Deletions      int
+}
+
+// Trailer 提交信息末尾的 "Key: value" 行
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+// Identity Git用户身份
+type Identity struct {
+	Name  string
+	Email string
+}
+
+// FileChange 单个文件的变更统计
+type FileChange struct {
+	Path      string // 文件路径
+	OldPath   string // 重命名前的路径，未重命名时为空
 	Additions int
 	Deletions int
+	Binary    bool // 二进制文件没有行数统计
 }
 
+// git log 输出格式：每个提交以 \x1e 开头，字段以 NUL 分隔；配合 -z 时 numstat 条目也以 NUL 分隔
+const (
+	commitMarker       = "\x1e"
+	commitHeaderFields = 9
+	commitLogFormat    = "--pretty=format:%x1e%H%x00%an%x00%ae%x00%ad%x00%cn%x00%ce%x00%cd%x00%s%x00%b%x00"
+)
+
 // GitParser Git解析器
 type GitParser struct {
 	repoPath string
@@ -36,9 +78,10 @@ func NewGitParser(repoPath string) *GitParser {
 func (g *GitParser) GetCommits(since, until time.Time, author string) ([]*GitCommit, error) {
 	args := []string{
 		"log",
-		"--pretty=format:%H|%an|%ad|%s",
-		"--date=iso",
+		commitLogFormat,
+		"--date=iso-strict",
 		"--numstat",
+		"-z",
 		fmt.Sprintf("--since=%s", since.Format("2006-01-02 00:00:00")),
 		fmt.Sprintf("--until=%s", until.Format("2006-01-02 23:59:59")),
 	}
@@ -58,6 +101,27 @@ func (g *GitParser) GetCommits(since, until time.Time, author string) ([]*GitCom
 	return g.parseCommits(string(output))
 }
 
+// GetCommit 获取单个提交，rev 可以是哈希、分支名等任意Git修订版本
+func (g *GitParser) GetCommit(rev string) (*GitCommit, error) {
+	cmd := exec.Command("git", "log", "-1", commitLogFormat, "--date=iso-strict", "--numstat", "-z", rev, "--")
+	cmd.Dir = g.repoPath
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("执行git命令失败: %v", err)
+	}
+
+	commits, err := g.parseCommits(string(output))
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("没有找到提交: %s", rev)
+	}
+
+	return commits[0], nil
+}
+
 // GetCurrentUser 获取当前Git用户
 func (g *GitParser) GetCurrentUser() (string, error) {
 	cmd := exec.Command("git", "config", "user.name")
@@ -72,62 +136,184 @@ func (g *GitParser) GetCurrentUser() (string, error) {
 }
 
 // parseCommits 解析Git日志输出
+// 输出按 NUL 切分后依次为：提交头（以 \x1e 开头的哈希及其余固定字段）和若干 numstat 条目。
+// numstat 条目形如 "新增\t删除\t路径"，二进制文件的行数为 "-"；
+// 重命名时路径为空，随后的两个条目分别是旧路径和新路径。
 func (g *GitParser) parseCommits(output string) ([]*GitCommit, error) {
 	var commits []*GitCommit
-	lines := strings.Split(output, "\n")
-
 	var currentCommit *GitCommit
-	commitRegex := regexp.MustCompile(`^([a-f0-9]+)\|(.+)\|(.+)\|(.+)$`)
-	numstatRegex := regexp.MustCompile(`^(\d+)\s+(\d+)\s+(.+)$`)
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
+	tokens := strings.Split(output, "\x00")
+	for i := 0; i < len(tokens); {
+		token := tokens[i]
 
-		// 检查是否是提交行
-		if matches := commitRegex.FindStringSubmatch(line); matches != nil {
-			// 保存上一个提交
-			if currentCommit != nil {
-				commits = append(commits, currentCommit)
+		// 检查是否是提交头
+		if strings.HasPrefix(token, commitMarker) {
+			if i+commitHeaderFields > len(tokens) {
+				return nil, fmt.Errorf("提交头不完整: %q", token)
 			}
+			fields := tokens[i : i+commitHeaderFields]
 
-			// 解析日期
-			date, err := time.Parse("2006-01-02 15:04:05 -0700", matches[3])
+			date, err := time.Parse(time.RFC3339, fields[3])
 			if err != nil {
 				return nil, fmt.Errorf("解析日期失败: %v", err)
 			}
+			commitDate, err := time.Parse(time.RFC3339, fields[6])
+			if err != nil {
+				return nil, fmt.Errorf("解析提交日期失败: %v", err)
+			}
+
+			body := strings.TrimSpace(fields[8])
+			trailers := parseTrailers(body)
 
 			currentCommit = &GitCommit{
-				Hash:    matches[1],
-				Author:  matches[2],
-				Date:    date,
-				Message: matches[4],
-				Files:   []string{},
+				Hash:           strings.TrimPrefix(fields[0], commitMarker),
+				Author:         fields[1],
+				Email:          fields[2],
+				Date:           date,
+				Committer:      fields[4],
+				CommitterEmail: fields[5],
+				CommitDate:     commitDate,
+				Message:        fields[7],
+				Body:           body,
+				Trailers:       trailers,
+				CoAuthors:      parseCoAuthors(trailers),
+				Files:          []string{},
 			}
-		} else if currentCommit != nil {
-			// 检查是否是numstat行
-			if matches := numstatRegex.FindStringSubmatch(line); matches != nil {
-				additions, _ := strconv.Atoi(matches[1])
-				deletions, _ := strconv.Atoi(matches[2])
-				filename := matches[3]
-
-				currentCommit.Additions += additions
-				currentCommit.Deletions += deletions
-				currentCommit.Files = append(currentCommit.Files, filename)
+			parseConventionalCommit(currentCommit)
+			commits = append(commits, currentCommit)
+			i += commitHeaderFields
+			continue
+		}
+
+		// 空条目是记录之间的分隔
+		if token == "" {
+			i++
+			continue
+		}
+
+		if currentCommit == nil {
+			return nil, fmt.Errorf("无法识别的git输出: %q", token)
+		}
+
+		// numstat 条目，第一个条目前带有提交头结束时的换行
+		parts := strings.SplitN(strings.TrimPrefix(token, "\n"), "\t", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("无法解析的numstat条目: %q", token)
+		}
+
+		change := FileChange{Path: parts[2]}
+		i++
+		if change.Path == "" {
+			if i+2 > len(tokens) {
+				return nil, fmt.Errorf("重命名条目不完整: %q", token)
 			}
+			change.OldPath = tokens[i]
+			change.Path = tokens[i+1]
+			i += 2
 		}
-	}
 
-	// 添加最后一个提交
-	if currentCommit != nil {
-		commits = append(commits, currentCommit)
+		if parts[0] == "-" && parts[1] == "-" {
+			change.Binary = true
+		} else {
+			var err error
+			if change.Additions, err = strconv.Atoi(parts[0]); err != nil {
+				return nil, fmt.Errorf("无法解析新增行数: %q", token)
+			}
+			if change.Deletions, err = strconv.Atoi(parts[1]); err != nil {
+				return nil, fmt.Errorf("无法解析删除行数: %q", token)
+			}
+		}
+
+		currentCommit.Additions += change.Additions
+		currentCommit.Deletions += change.Deletions
+		currentCommit.Files = append(currentCommit.Files, change.Path)
+		currentCommit.Changes = append(currentCommit.Changes, change)
 	}
 
 	return commits, nil
 }
 
+// TrailerValues 返回指定 trailer 的所有取值，key 不区分大小写
+func (c *GitCommit) TrailerValues(key string) []string {
+	var values []string
+	for _, trailer := range c.Trailers {
+		if strings.EqualFold(trailer.Key, key) {
+			values = append(values, trailer.Value)
+		}
+	}
+	return values
+}
+
+// FullMessage 返回标题和正文
+func (c *GitCommit) FullMessage() string {
+	if c.Body == "" {
+		return c.Message
+	}
+	return c.Message + "\n\n" + c.Body
+}
+
+// Identities 返回作者及所有共同作者
+func (c *GitCommit) Identities() []Identity {
+	return append([]Identity{{Name: c.Author, Email: c.Email}}, c.CoAuthors...)
+}
+
+// parseTrailers 解析正文最后一段中的 trailer，该段每一行都必须是 "Key: value" 或其续行
+func parseTrailers(body string) []Trailer {
+	if body == "" {
+		return nil
+	}
+
+	paragraphs := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n\n")
+	lines := strings.Split(strings.TrimSpace(paragraphs[len(paragraphs)-1]), "\n")
+
+	var trailers []Trailer
+	for _, line := range lines {
+		// 以空白开头的行是上一个 trailer 的续行
+		if strings.TrimSpace(line) != "" && (line[0] == ' ' || line[0] == '\t') && len(trailers) > 0 {
+			trailers[len(trailers)-1].Value += " " + strings.TrimSpace(line)
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || !isTrailerKey(key) {
+			return nil
+		}
+		trailers = append(trailers, Trailer{Key: key, Value: strings.TrimSpace(value)})
+	}
+
+	return trailers
+}
+
+// isTrailerKey 判断是否为合法的 trailer 名称，如 Signed-off-by
+func isTrailerKey(key string) bool {
+	return key != "" && isASCIIWord(key)
+}
+
+// parseCoAuthors 从 Co-authored-by trailer 中解析共同作者
+func parseCoAuthors(trailers []Trailer) []Identity {
+	var coAuthors []Identity
+	for _, trailer := range trailers {
+		if !strings.EqualFold(trailer.Key, "Co-authored-by") {
+			continue
+		}
+		coAuthors = append(coAuthors, parseIdentity(trailer.Value))
+	}
+	return coAuthors
+}
+
+// parseIdentity 解析 "Name <email>" 格式的身份
+func parseIdentity(value string) Identity {
+	name, rest, ok := strings.Cut(value, "<")
+	if !ok {
+		return Identity{Name: strings.TrimSpace(value)}
+	}
+	return Identity{
+		Name:  strings.TrimSpace(name),
+		Email: strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(rest), ">")),
+	}
+}
+
 // GetRepoInfo 获取仓库信息
 func (g *GitParser) GetRepoInfo() (map[string]string, error) {
 	info := make(map[string]string)
@@ -147,6 +333,13 @@ func (g *GitParser) GetRepoInfo() (map[string]string, error) {
 		info["url"] = remoteURL
 	}
 
+	// 没有远程地址时使用目录名作为仓库名
+	if info["name"] == "" {
+		if absPath, err := filepath.Abs(g.repoPath); err == nil {
+			info["name"] = filepath.Base(absPath)
+		}
+	}
+
 	// 获取当前分支
 	cmd = exec.Command("git", "branch", "--show-current")
 	cmd.Dir = g.repoPath
@@ -155,4 +348,4 @@ func (g *GitParser) GetRepoInfo() (map[string]string, error) {
 	}
 
 	return info, nil
-}
\ No newline at end of file
+}
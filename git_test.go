@@ -0,0 +1,233 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// loadGitLogFixture 读取 testdata/gitlog 下用
+// git log <commitLogFormat> --date=iso-strict --numstat -z 采集的输出
+func loadGitLogFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "gitlog", name))
+	if err != nil {
+		t.Fatalf("读取测试数据失败: %v", err)
+	}
+	return string(data)
+}
+
+func TestParseCommits(t *testing.T) {
+	cst := time.FixedZone("", 8*3600)
+
+	tests := []struct {
+		name    string
+		fixture string
+		check   func(t *testing.T, commit *GitCommit)
+	}{
+		{
+			name:    "标题和正文中的竖线与分隔符、带空格和换行的路径",
+			fixture: "paths_and_pipes.log",
+			check: func(t *testing.T, c *GitCommit) {
+				if c.Hash != "650557a235044ad80e16abfa78cc6aff4718dafb" {
+					t.Errorf("Hash = %q", c.Hash)
+				}
+				if c.Author != "张三" || c.Email != "zhangsan@example.com" {
+					t.Errorf("作者 = %q <%q>", c.Author, c.Email)
+				}
+				if !c.Date.Equal(time.Date(2026, 10, 12, 9, 0, 0, 0, cst)) {
+					t.Errorf("Date = %v", c.Date)
+				}
+				if c.Message != "feat(parser): handle a|b pipes and \x1e marker" {
+					t.Errorf("Message = %q", c.Message)
+				}
+				if c.Body != "Body line one | with pipe\nsecond line of body\n\tindented third line" {
+					t.Errorf("Body = %q", c.Body)
+				}
+				if c.Type != "feat" || c.Scope != "parser" {
+					t.Errorf("Type/Scope = %q/%q", c.Type, c.Scope)
+				}
+				if len(c.Trailers) != 0 {
+					t.Errorf("Trailers = %+v, 期望为空", c.Trailers)
+				}
+				wantChanges := []FileChange{
+					{Path: "file with spaces.txt", Additions: 2},
+					{Path: "new\nline.txt", Additions: 1},
+				}
+				if !reflect.DeepEqual(c.Changes, wantChanges) {
+					t.Errorf("Changes = %+v, 期望 %+v", c.Changes, wantChanges)
+				}
+				if !reflect.DeepEqual(c.Files, []string{"file with spaces.txt", "new\nline.txt"}) {
+					t.Errorf("Files = %q", c.Files)
+				}
+				if c.Additions != 3 || c.Deletions != 0 {
+					t.Errorf("行数 = +%d -%d", c.Additions, c.Deletions)
+				}
+			},
+		},
+		{
+			name:    "多段正文与 Co-authored-by trailer",
+			fixture: "coauthors.log",
+			check: func(t *testing.T, c *GitCommit) {
+				if c.Message != "fix: multi paragraph body" {
+					t.Errorf("Message = %q", c.Message)
+				}
+				wantBody := "First paragraph explains\nthe problem over two lines.\n\n" +
+					"Second paragraph: not a trailer because\nit has ordinary sentences.\n\n" +
+					"Refs: #12\nCo-authored-by: 李四 <lisi@example.com>\nCo-authored-by: Wang Wu <wangwu@example.com>"
+				if c.Body != wantBody {
+					t.Errorf("Body = %q", c.Body)
+				}
+				wantTrailers := []Trailer{
+					{Key: "Refs", Value: "#12"},
+					{Key: "Co-authored-by", Value: "李四 <lisi@example.com>"},
+					{Key: "Co-authored-by", Value: "Wang Wu <wangwu@example.com>"},
+				}
+				if !reflect.DeepEqual(c.Trailers, wantTrailers) {
+					t.Errorf("Trailers = %+v", c.Trailers)
+				}
+				wantCoAuthors := []Identity{
+					{Name: "李四", Email: "lisi@example.com"},
+					{Name: "Wang Wu", Email: "wangwu@example.com"},
+				}
+				if !reflect.DeepEqual(c.CoAuthors, wantCoAuthors) {
+					t.Errorf("CoAuthors = %+v", c.CoAuthors)
+				}
+				if got := len(c.Identities()); got != 3 {
+					t.Errorf("Identities() 数量 = %d, 期望 3", got)
+				}
+				if !reflect.DeepEqual(c.Changes, []FileChange{{Path: "main.go", Additions: 1}}) {
+					t.Errorf("Changes = %+v", c.Changes)
+				}
+			},
+		},
+		{
+			name:    "二进制文件与重命名",
+			fixture: "binary_and_rename.log",
+			check: func(t *testing.T, c *GitCommit) {
+				if c.Committer != "Committer" || c.CommitterEmail != "c@example.com" {
+					t.Errorf("提交者 = %q <%q>", c.Committer, c.CommitterEmail)
+				}
+				if !c.Date.Equal(time.Date(2026, 10, 13, 10, 30, 0, 0, cst)) {
+					t.Errorf("Date = %v", c.Date)
+				}
+				if !c.CommitDate.Equal(time.Date(2026, 10, 13, 11, 0, 0, 0, cst)) {
+					t.Errorf("CommitDate = %v", c.CommitDate)
+				}
+				if c.Body != "" {
+					t.Errorf("Body = %q, 期望为空", c.Body)
+				}
+				wantChanges := []FileChange{
+					{Path: "logo.png", Binary: true},
+					{Path: "renamed file.txt", OldPath: "file with spaces.txt"},
+				}
+				if !reflect.DeepEqual(c.Changes, wantChanges) {
+					t.Errorf("Changes = %+v, 期望 %+v", c.Changes, wantChanges)
+				}
+				if !reflect.DeepEqual(c.Files, []string{"logo.png", "renamed file.txt"}) {
+					t.Errorf("Files = %q", c.Files)
+				}
+			},
+		},
+		{
+			name:    "空提交",
+			fixture: "empty.log",
+			check: func(t *testing.T, c *GitCommit) {
+				if c.Message != "empty commit with\ttab and %x00 literal" {
+					t.Errorf("Message = %q", c.Message)
+				}
+				if c.Type != "" {
+					t.Errorf("Type = %q, 期望为空", c.Type)
+				}
+				if c.Files == nil || len(c.Files) != 0 || len(c.Changes) != 0 {
+					t.Errorf("Files = %q, Changes = %+v, 期望为空", c.Files, c.Changes)
+				}
+				if c.Additions != 0 || c.Deletions != 0 {
+					t.Errorf("行数 = +%d -%d", c.Additions, c.Deletions)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commits, err := (&GitParser{}).parseCommits(loadGitLogFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatalf("parseCommits 返回错误: %v", err)
+			}
+			if len(commits) != 1 {
+				t.Fatalf("提交数量 = %d, 期望 1", len(commits))
+			}
+			tt.check(t, commits[0])
+		})
+	}
+}
+
+func TestParseCommitsMultiple(t *testing.T) {
+	commits, err := (&GitParser{}).parseCommits(loadGitLogFixture(t, "multiple.log"))
+	if err != nil {
+		t.Fatalf("parseCommits 返回错误: %v", err)
+	}
+
+	wantHashes := []string{
+		"8c8393794d225d28b7c2b64c2ef64bcdaa249015",
+		"7b1631cf839628f9f235d5c80a785f5579938e01",
+		"cd1ff407e3a8d498901c37dcb8a4825437e42e82",
+	}
+	if len(commits) != len(wantHashes) {
+		t.Fatalf("提交数量 = %d, 期望 %d", len(commits), len(wantHashes))
+	}
+	for i, hash := range wantHashes {
+		if commits[i].Hash != hash {
+			t.Errorf("commits[%d].Hash = %q, 期望 %q", i, commits[i].Hash, hash)
+		}
+	}
+
+	// 空提交之后的 numstat 不能被算到空提交上
+	if len(commits[0].Changes) != 0 {
+		t.Errorf("空提交的 Changes = %+v", commits[0].Changes)
+	}
+	if len(commits[1].Changes) != 2 || len(commits[2].Changes) != 1 {
+		t.Errorf("Changes 数量 = %d/%d, 期望 2/1", len(commits[1].Changes), len(commits[2].Changes))
+	}
+	if len(commits[2].CoAuthors) != 2 {
+		t.Errorf("CoAuthors = %+v", commits[2].CoAuthors)
+	}
+}
+
+func TestParseCommitsErrors(t *testing.T) {
+	header := "\x1eabc\x00张三\x00zhangsan@example.com\x002026-10-12T09:00:00+08:00\x00" +
+		"张三\x00zhangsan@example.com\x002026-10-12T09:00:00+08:00\x00subject\x00\x00"
+
+	tests := []struct {
+		name   string
+		output string
+	}{
+		{"提交头不完整", "\x1eabc\x00张三\x00zhangsan@example.com"},
+		{"日期格式错误", "\x1eabc\x00张三\x00zhangsan@example.com\x00yesterday\x00张三\x00zhangsan@example.com\x002026-10-12T09:00:00+08:00\x00subject\x00\x00"},
+		{"提交头之前的内容", "garbage\x00" + header},
+		{"numstat 缺少字段", header + "\n1\tmain.go\x00"},
+		{"numstat 行数不是数字", header + "\nx\t1\tmain.go\x00"},
+		{"重命名条目不完整", header + "\n0\t0\t\x00old.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (&GitParser{}).parseCommits(tt.output); err == nil {
+				t.Error("期望返回错误")
+			}
+		})
+	}
+}
+
+func TestParseCommitsEmptyOutput(t *testing.T) {
+	commits, err := (&GitParser{}).parseCommits("")
+	if err != nil {
+		t.Fatalf("parseCommits 返回错误: %v", err)
+	}
+	if len(commits) != 0 {
+		t.Errorf("提交数量 = %d, 期望 0", len(commits))
+	}
+}
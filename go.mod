@@ -5,4 +5,8 @@ go 1.21
 require (
 	github.com/gorilla/mux v1.8.0
 	github.com/rs/cors v1.10.1
+	go.etcd.io/bbolt v1.3.10
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/sys v0.4.0 // indirect
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// HistoryEntry 报告历史中的一份报告
+type HistoryEntry struct {
+	ID            uint64    `json:"id"`
+	Type          string    `json:"type"`
+	Author        string    `json:"author"`
+	Team          bool      `json:"team"`
+	Period        string    `json:"period"`
+	Since         time.Time `json:"since"`
+	Until         time.Time `json:"until"`
+	Repos         []string  `json:"repos"`
+	Template      string    `json:"template,omitempty"` // 生成时使用的模板
+	Commits       int       `json:"commits"`
+	Source        string    `json:"source"` // cli、api 或 schedule
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	RevisionCount int       `json:"revision_count"` // 修订数量
+	LatestKind    string    `json:"latest_kind"`    // 最新修订的类型
+	Fingerprint   string    `json:"fingerprint"`    // 相同的报告只记录一次
+}
+
+// HistoryRevision 报告内容的一个版本
+type HistoryRevision struct {
+	Number    int       `json:"number"`
+	Kind      string    `json:"kind"` // generated、optimized 或 edited
+	Content   string    `json:"content"`
+	Provider  string    `json:"provider,omitempty"` // AI 优化使用的服务商
+	Prompt    string    `json:"prompt,omitempty"`   // AI 优化使用的提示词模板
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HistoryRecord 一份报告的完整记录
+type HistoryRecord struct {
+	*HistoryEntry
+	Report    *ReportExport      `json:"report"`
+	Revisions []*HistoryRevision `json:"revisions"`
+}
+
+// HistoryFilter 列出报告时的筛选条件，零值表示不限
+type HistoryFilter struct {
+	Author string
+	Type   string
+	Since  time.Time // 报告周期与 [Since, Until] 有交集
+	Until  time.Time
+	Limit  int
+}
+
+// 修订类型
+const (
+	RevisionGenerated = "generated"
+	RevisionOptimized = "optimized"
+	RevisionEdited    = "edited"
+)
+
+// 报告来源
+const (
+	HistorySourceCLI      = "cli"
+	HistorySourceAPI      = "api"
+	HistorySourceSchedule = "schedule"
+)
+
+// 报告历史相关的错误
+var (
+	ErrReportNotFound   = errors.New("report not found")
+	ErrRevisionNotFound = errors.New("revision not found")
+)
+
+// errHistoryEmpty 还没有记录任何报告
+var errHistoryEmpty = errors.New("history is empty")
+
+// historyLockTimeout 等待其他进程释放数据库文件的最长时间
+const historyLockTimeout = 5 * time.Second
+
+var (
+	historyEntriesBucket      = []byte("entries")
+	historyReportsBucket      = []byte("reports")
+	historyRevisionsBucket    = []byte("revisions")
+	historyFingerprintsBucket = []byte("fingerprints")
+)
+
+// HistoryStore 保存生成的报告及其修订，数据存放在一个 bbolt 文件中
+// 每次操作时打开文件、结束后关闭，服务器运行时命令行也可以查询
+type HistoryStore struct {
+	filename string
+	mu       sync.Mutex
+}
+
+// NewHistoryStore 创建报告历史，文件在第一次写入时创建
+func NewHistoryStore(filename string) *HistoryStore {
+	return &HistoryStore{filename: filename}
+}
+
+// Record 记录生成的报告，content 为渲染后的 Markdown
+// 相同参数生成的报告包含的提交相同时不重复记录，返回已有的记录
+func (h *HistoryStore) Record(report *Report, content, template, source string, repoPaths []string) (*HistoryEntry, error) {
+	export := NewReportExport(report)
+	fingerprint := reportFingerprint(export, template, repoPaths)
+
+	var entry *HistoryEntry
+	err := h.update(func(tx *bolt.Tx) error {
+		if id := tx.Bucket(historyFingerprintsBucket).Get([]byte(fingerprint)); id != nil {
+			existing, err := getHistoryEntry(tx, binary.BigEndian.Uint64(id))
+			if err == nil {
+				entry = existing
+				return nil
+			}
+		}
+
+		entries := tx.Bucket(historyEntriesBucket)
+		id, err := entries.NextSequence()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		entry = &HistoryEntry{
+			ID:          id,
+			Type:        report.Type,
+			Author:      report.Author,
+			Team:        report.Team,
+			Period:      report.Period,
+			Since:       report.Since,
+			Until:       report.Until,
+			Repos:       repoPaths,
+			Template:    template,
+			Commits:     len(report.Commits),
+			Source:      source,
+			CreatedAt:   now,
+			Fingerprint: fingerprint,
+		}
+
+		if err := putJSON(tx.Bucket(historyReportsBucket), historyKey(id), export); err != nil {
+			return err
+		}
+		if _, err := tx.Bucket(historyRevisionsBucket).CreateBucket(historyKey(id)); err != nil {
+			return err
+		}
+		if err := tx.Bucket(historyFingerprintsBucket).Put([]byte(fingerprint), historyKey(id)); err != nil {
+			return err
+		}
+		_, err = addRevision(tx, entry, &HistoryRevision{Kind: RevisionGenerated, Content: content, CreatedAt: now})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("保存报告历史失败: %v", err)
+	}
+	return entry, nil
+}
+
+// AddRevision 为报告添加一个修订，内容与最新修订相同时不重复添加
+func (h *HistoryStore) AddRevision(id uint64, revision *HistoryRevision) (*HistoryRevision, error) {
+	var added *HistoryRevision
+	err := h.update(func(tx *bolt.Tx) error {
+		entry, err := getHistoryEntry(tx, id)
+		if err != nil {
+			return err
+		}
+		revision.CreatedAt = time.Now()
+		added, err = addRevision(tx, entry, revision)
+		return err
+	})
+	if errors.Is(err, ErrReportNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("保存报告修订失败: %v", err)
+	}
+	return added, nil
+}
+
+// List 按生成时间从新到旧列出报告
+func (h *HistoryStore) List(filter HistoryFilter) ([]*HistoryEntry, error) {
+	entries := []*HistoryEntry{}
+	err := h.view(func(tx *bolt.Tx) error {
+		c := tx.Bucket(historyEntriesBucket).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var entry HistoryEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			if !filter.match(&entry) {
+				continue
+			}
+			entries = append(entries, &entry)
+			if filter.Limit > 0 && len(entries) >= filter.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errHistoryEmpty) {
+		return nil, fmt.Errorf("读取报告历史失败: %v", err)
+	}
+	return entries, nil
+}
+
+// Get 返回报告的完整记录，包括结构化报告和所有修订
+func (h *HistoryStore) Get(id uint64) (*HistoryRecord, error) {
+	var record *HistoryRecord
+	err := h.view(func(tx *bolt.Tx) error {
+		entry, err := getHistoryEntry(tx, id)
+		if err != nil {
+			return err
+		}
+		record = &HistoryRecord{HistoryEntry: entry, Revisions: []*HistoryRevision{}}
+
+		if data := tx.Bucket(historyReportsBucket).Get(historyKey(id)); data != nil {
+			if err := json.Unmarshal(data, &record.Report); err != nil {
+				return err
+			}
+		}
+
+		revisions := tx.Bucket(historyRevisionsBucket).Bucket(historyKey(id))
+		if revisions == nil {
+			return nil
+		}
+		return revisions.ForEach(func(k, v []byte) error {
+			var revision HistoryRevision
+			if err := json.Unmarshal(v, &revision); err != nil {
+				return err
+			}
+			record.Revisions = append(record.Revisions, &revision)
+			return nil
+		})
+	})
+	if errors.Is(err, ErrReportNotFound) || errors.Is(err, errHistoryEmpty) {
+		return nil, ErrReportNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取报告历史失败: %v", err)
+	}
+	return record, nil
+}
+
+// Revision 返回报告的一个修订，number 为 0 时返回最新修订
+func (r *HistoryRecord) Revision(number int) (*HistoryRevision, error) {
+	if number == 0 && len(r.Revisions) > 0 {
+		return r.Revisions[len(r.Revisions)-1], nil
+	}
+	for _, revision := range r.Revisions {
+		if revision.Number == number {
+			return revision, nil
+		}
+	}
+	return nil, ErrRevisionNotFound
+}
+
+// Diff 比较报告的两个修订，from 为 0 时为第一个修订，to 为 0 时为最新修订
+func (r *HistoryRecord) Diff(from, to int) (string, *HistoryRevision, *HistoryRevision, error) {
+	if from == 0 {
+		from = 1
+	}
+	a, err := r.Revision(from)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("%w: %d", err, from)
+	}
+	b, err := r.Revision(to)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("%w: %d", err, to)
+	}
+
+	diff := UnifiedDiff(a.Content, b.Content,
+		fmt.Sprintf("report-%d revision %d (%s)", r.ID, a.Number, a.Kind),
+		fmt.Sprintf("report-%d revision %d (%s)", r.ID, b.Number, b.Kind))
+	return diff, a, b, nil
+}
+
+// match 判断报告是否满足筛选条件
+func (f HistoryFilter) match(entry *HistoryEntry) bool {
+	if f.Author != "" && !strings.EqualFold(entry.Author, f.Author) {
+		return false
+	}
+	if f.Type != "" && entry.Type != f.Type {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Until.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && entry.Since.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// update 打开数据库执行写事务，不存在的文件和分组会被创建
+func (h *HistoryStore) update(fn func(tx *bolt.Tx) error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if dir := filepath.Dir(h.filename); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	db, err := bolt.Open(h.filename, 0644, &bolt.Options{Timeout: historyLockTimeout})
+	if err != nil {
+		return historyOpenError(h.filename, err)
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{historyEntriesBucket, historyReportsBucket, historyRevisionsBucket, historyFingerprintsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// view 以只读方式打开数据库执行读事务，文件还不存在时返回 errHistoryEmpty
+func (h *HistoryStore) view(fn func(tx *bolt.Tx) error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, err := os.Stat(h.filename); errors.Is(err, os.ErrNotExist) {
+		return errHistoryEmpty
+	}
+	db, err := bolt.Open(h.filename, 0644, &bolt.Options{Timeout: historyLockTimeout, ReadOnly: true})
+	if err != nil {
+		return historyOpenError(h.filename, err)
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(historyEntriesBucket) == nil {
+			return errHistoryEmpty
+		}
+		return fn(tx)
+	})
+}
+
+// historyOpenError 数据库被其他进程长时间占用时给出提示
+func historyOpenError(filename string, err error) error {
+	if errors.Is(err, bolt.ErrTimeout) {
+		return fmt.Errorf("报告历史文件 %s 被其他进程占用", filename)
+	}
+	return fmt.Errorf("打开报告历史文件 %s 失败: %v", filename, err)
+}
+
+// getHistoryEntry 读取报告的元数据
+func getHistoryEntry(tx *bolt.Tx, id uint64) (*HistoryEntry, error) {
+	data := tx.Bucket(historyEntriesBucket).Get(historyKey(id))
+	if data == nil {
+		return nil, ErrReportNotFound
+	}
+	var entry HistoryEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// addRevision 写入修订并更新报告的修订数，内容与最新修订相同时返回最新修订
+func addRevision(tx *bolt.Tx, entry *HistoryEntry, revision *HistoryRevision) (*HistoryRevision, error) {
+	revisions := tx.Bucket(historyRevisionsBucket).Bucket(historyKey(entry.ID))
+	if revisions == nil {
+		return nil, ErrReportNotFound
+	}
+
+	if _, data := revisions.Cursor().Last(); data != nil {
+		var latest HistoryRevision
+		if err := json.Unmarshal(data, &latest); err != nil {
+			return nil, err
+		}
+		if latest.Content == revision.Content {
+			return &latest, nil
+		}
+	}
+
+	number, err := revisions.NextSequence()
+	if err != nil {
+		return nil, err
+	}
+	revision.Number = int(number)
+	if err := putJSON(revisions, historyKey(number), revision); err != nil {
+		return nil, err
+	}
+
+	entry.RevisionCount = revision.Number
+	entry.LatestKind = revision.Kind
+	entry.UpdatedAt = revision.CreatedAt
+	if err := putJSON(tx.Bucket(historyEntriesBucket), historyKey(entry.ID), entry); err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// putJSON 以 JSON 写入一个键
+func putJSON(bucket *bolt.Bucket, key []byte, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, data)
+}
+
+// historyKey 以大端序编码 ID，使游标按数值顺序遍历
+func historyKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// reportFingerprint 由报告参数和包含的提交计算指纹
+func reportFingerprint(export *ReportExport, template string, repoPaths []string) string {
+	hashes := make([]string, 0, len(export.Commits))
+	for _, commit := range export.Commits {
+		hashes = append(hashes, commit.Hash)
+	}
+	sort.Strings(hashes)
+	repos := append([]string(nil), repoPaths...)
+	sort.Strings(repos)
+
+	sum := sha256.New()
+	fmt.Fprintf(sum, "%s\n%s\n%t\n%s\n%s\n%s\n%s\n%s\n",
+		export.Type, export.Author, export.Team,
+		export.Since.Format(time.RFC3339), export.Until.Format(time.RFC3339),
+		template, strings.Join(repos, ","), strings.Join(hashes, ","))
+	return hex.EncodeToString(sum.Sum(nil))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// htmlReportTemplate HTML 报告模板，样式内嵌，不依赖外部资源和脚本
+//
+//go:embed templates/html/report.html
+var htmlReportTemplate string
+
+// RenderHTML 渲染为单个自包含的 HTML 文件，图表为服务端生成的内联 SVG
+// 使用 html/template 渲染，提交信息等内容会被转义
+func (rr *ReportRenderer) RenderHTML(report *Report) (string, error) {
+	funcs := template.FuncMap(rr.funcs())
+	funcs["paragraphs"] = paragraphs
+	funcs["percentWidth"] = percentWidth
+	funcs["trendChart"] = func(report *Report) template.HTML {
+		return barChartSVG("提交趋势", trendSeries(report), chartColorCommits)
+	}
+	funcs["fileTypeChart"] = func(fileTypes map[string]int) template.HTML {
+		return hbarChartSVG("技术栈分布", fileTypeSeries(fileTypes), chartColorFileTypes)
+	}
+	funcs["churnChart"] = func(report *Report) template.HTML {
+		return churnChartSVG("代码增删", churnSeries(report))
+	}
+	funcs["sortedCategories"] = sortedCategoryNames
+
+	tmpl, err := template.New("report.html").Funcs(funcs).Parse(htmlReportTemplate)
+	if err != nil {
+		return "", fmt.Errorf("解析HTML模板失败: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, report); err != nil {
+		return "", fmt.Errorf("执行HTML模板失败: %v", err)
+	}
+
+	return buf.String(), nil
+}
+
+// paragraphs 把以空行分隔的文本拆分为段落
+func paragraphs(text string) []string {
+	var result []string
+	for _, p := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// percentWidth 把百分比限制在 0 到 100 之间，用作进度条宽度
+func percentWidth(percent float64) string {
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	return fmt.Sprintf("%.0f%%", percent)
+}
@@ -1,77 +1,252 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
 func main() {
+	// 子命令，如 categorize
+	if len(os.Args) > 1 && runCommand(os.Args[1], os.Args[2:]) {
+		return
+	}
+
 	var (
-		reportType = flag.String("type", "daily", "报告类型: daily, weekly")
+		reportType = flag.String("type", "daily", "报告类型: daily, weekly, monthly, quarterly, yearly, range")
 		date = flag.String("date", "", "指定日期 (YYYY-MM-DD), 默认为今天")
-		repoPath = flag.String("repo", ".", "Git仓库路径")
+		repoPath = flag.String("repo", ".", "Git仓库路径，多个路径用逗号分隔，也可以是包含多个仓库的工作区目录")
 		author = flag.String("author", "", "指定作者，默认为当前Git用户")
 		output = flag.String("output", "", "输出文件路径，默认输出到控制台")
-		template = flag.String("template", "", "自定义模板文件路径")
+		template = flag.String("template", "", "内置模板名 (minimal, detailed, manager, okr, changelog) 或自定义模板文件路径")
+		format = flag.String("format", FormatMarkdown, "输出格式: markdown, html, json, yaml, csv (zip), xlsx")
+		bom = flag.Bool("bom", false, "csv 格式在文件开头写入 UTF-8 BOM，便于 Excel 正确显示中文")
 		server = flag.Bool("server", false, "启动HTTP服务器模式")
+		okrFile = flag.String("okr", "", "OKR定义文件路径，将提交归属到各KR并统计进度")
+		configFile = flag.String("config", "", "配置文件路径，参考 config.example.json")
+		since = flag.String("since", "", "range 报告的开始日期 (YYYY-MM-DD)")
+		until = flag.String("until", "", "range 报告的结束日期 (YYYY-MM-DD), 默认为今天")
+		team = flag.Bool("team", false, "团队模式：包含所有作者并按成员分组统计")
+		rosterFile = flag.String("roster", "", "团队花名册文件，将多个用户名/邮箱映射到同一成员")
+		deliver = flag.String("deliver", "", "推送到配置中 delivery.channels 的渠道（聊天机器人或邮件），多个渠道用逗号分隔")
 	)
 	flag.Parse()
 
+	// 加载配置
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+
 	// 如果是服务器模式，启动HTTP服务器
 	if *server {
-		startServer()
+		startServer(config)
 		return
 	}
 
+	// 未显式指定仓库路径时使用配置中的默认仓库
+	if !isFlagSet("repo") && config.DefaultRepoPath != "" {
+		*repoPath = config.DefaultRepoPath
+	}
+
+	// 模板只用于 Markdown 输出
+	if *format != FormatMarkdown && *template != "" {
+		log.Fatalf("-template 只适用于 %s 格式", FormatMarkdown)
+	}
+	spreadsheet := *format == FormatCSV || *format == FormatXLSX
+
+	// 推送渠道在生成报告前检查，避免生成后才发现渠道名写错
+	var channels []string
+	if *deliver != "" {
+		for _, name := range strings.Split(*deliver, ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			if _, err := config.Delivery.NewChannel(name); err != nil {
+				log.Fatalf("推送渠道无效: %v", err)
+			}
+			channels = append(channels, name)
+		}
+	}
+	if spreadsheet && *output == "" {
+		log.Fatalf("%s 格式需要使用 -output 指定输出文件", *format)
+	}
+
 	// 解析日期
 	targetDate, err := parseDate(*date)
 	if err != nil {
 		log.Fatalf("日期解析错误: %v", err)
 	}
 
+	// 展开仓库路径
+	repoPaths, err := DiscoverRepos(strings.Split(*repoPath, ","))
+	if err != nil {
+		log.Fatalf("查找仓库失败: %v", err)
+	}
+
 	// 创建报告生成器
-	generator := NewReportGenerator(*repoPath, *author)
+	generator := NewMultiRepoReportGenerator(repoPaths, *author, config)
+	generator.SetTeamMode(*team)
+	if *rosterFile == "" {
+		*rosterFile = config.RosterFile
+	}
+	if *rosterFile != "" {
+		roster, err := LoadRoster(*rosterFile)
+		if err != nil {
+			log.Fatalf("加载花名册失败: %v", err)
+		}
+		generator.SetRoster(roster)
+	}
+	if *okrFile == "" {
+		*okrFile = config.OKRFile
+	}
+	if *okrFile != "" {
+		okrSet, err := LoadOKRSet(*okrFile)
+		if err != nil {
+			log.Fatalf("加载OKR失败: %v", err)
+		}
+		generator.SetOKRSet(okrSet)
+	}
+
+	// 指定了 -since/-until 但未指定 -type 时，视为日期范围报告
+	if !isFlagSet("type") && (*since != "" || *until != "") {
+		*reportType = ReportTypeRange
+	}
 
 	// 生成报告
 	var report *Report
 	switch *reportType {
-	case "daily":
-		report, err = generator.GenerateDailyReport(targetDate)
-	case "weekly":
-		report, err = generator.GenerateWeeklyReport(targetDate)
+	case ReportTypeRange:
+		var sinceDate, untilDate time.Time
+		sinceDate, untilDate, err = parseDateRange(*since, *until)
+		if err != nil {
+			log.Fatalf("日期范围解析错误: %v", err)
+		}
+		report, err = generator.GenerateRangeReport(sinceDate, untilDate)
 	default:
-		log.Fatalf("不支持的报告类型: %s", *reportType)
+		report, err = generator.GenerateReport(*reportType, targetDate)
 	}
 
 	if err != nil {
 		log.Fatalf("生成报告失败: %v", err)
 	}
 
-	// 渲染报告
-	renderer := NewReportRenderer(*template)
-	content, err := renderer.Render(report)
+	// 渲染报告，表格格式直接从报告导出
+	var content []byte
+	if spreadsheet {
+		content, err = ExportSpreadsheet(report, *format, *bom)
+	} else {
+		var text string
+		text, err = NewReportRenderer(*template, config).RenderFormat(report, *format)
+		content = []byte(text)
+	}
 	if err != nil {
 		log.Fatalf("渲染报告失败: %v", err)
 	}
 
 	// 输出报告
 	if *output != "" {
-		err = os.WriteFile(*output, []byte(content), 0644)
+		outputPath := *output
+		if config.OutputDirectory != "" && !filepath.IsAbs(outputPath) {
+			outputPath = filepath.Join(config.OutputDirectory, outputPath)
+			if err := os.MkdirAll(config.OutputDirectory, 0755); err != nil {
+				log.Fatalf("创建输出目录失败: %v", err)
+			}
+		}
+		err = os.WriteFile(outputPath, content, 0644)
 		if err != nil {
 			log.Fatalf("写入文件失败: %v", err)
 		}
-		fmt.Printf("报告已保存到: %s\n", *output)
+		fmt.Printf("报告已保存到: %s\n", outputPath)
 	} else {
-		fmt.Print(content)
+		fmt.Print(string(content))
+	}
+
+	// 报告历史和推送都使用 Markdown，其他格式时另行渲染
+	history := config.History()
+	if history == nil && len(channels) == 0 {
+		return
+	}
+	renderer := NewReportRenderer(*template, config)
+	markdown := string(content)
+	if *format != FormatMarkdown {
+		markdown, err = renderer.Render(report)
+		if err != nil {
+			log.Fatalf("渲染报告失败: %v", err)
+		}
+	}
+
+	// 保存到报告历史，失败时只提示，不影响已输出的报告
+	if history != nil {
+		entry, err := history.Record(report, markdown, renderer.templateFile, HistorySourceCLI, repoPaths)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "报告已记录到历史 #%d\n", entry.ID)
+		}
+	}
+
+	// 推送到聊天渠道和邮件，邮件同时附带 HTML 版本
+	if len(channels) > 0 {
+		msg, err := NewReportMessage(renderer, report, markdown)
+		if err != nil {
+			log.Fatalf("渲染报告失败: %v", err)
+		}
+		if !deliverReport(config, channels, msg) {
+			os.Exit(1)
+		}
 	}
 }
 
+// deliverReport 推送报告并在标准错误输出每个渠道的结果，全部成功时返回 true
+func deliverReport(config *Config, channels []string, msg *DeliveryMessage) bool {
+	ok := true
+	for _, result := range config.Delivery.Deliver(context.Background(), channels, msg) {
+		if result.Error != "" {
+			fmt.Fprintf(os.Stderr, "推送到 %s 失败: %s\n", result.Channel, result.Error)
+			ok = false
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "报告已推送到 %s（%d 条消息）\n", result.Channel, result.Parts)
+	}
+	return ok
+}
+
 func parseDate(dateStr string) (time.Time, error) {
 	if dateStr == "" {
 		return time.Now(), nil
 	}
 	return time.Parse("2006-01-02", dateStr)
-}
\ No newline at end of file
+}
+
+// parseDateRange 解析日期范围，开始日期必填，结束日期默认为今天
+func parseDateRange(sinceStr, untilStr string) (time.Time, time.Time, error) {
+	if sinceStr == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("range 报告需要指定开始日期")
+	}
+	since, err := parseDate(sinceStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	until, err := parseDate(untilStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return since, until, nil
+}
+
+// isFlagSet 判断命令行参数是否被显式指定
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
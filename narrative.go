@@ -0,0 +1,659 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 叙述性总结支持的语言
+const (
+	NarrativeChinese = "zh"
+	NarrativeEnglish = "en"
+)
+
+// narrativeMaxHighlights 总结中列出的代表性工作数量
+const narrativeMaxHighlights = 3
+
+// categoryNamesEN 默认分类和文件类型的英文名称，其他名称在英文总结中保持原样
+var categoryNamesEN = map[string]string{
+	"功能开发":        "feature work",
+	"Bug修复":       "bug fixes",
+	"代码重构":        "refactoring",
+	"文档更新":        "documentation",
+	"测试相关":        "testing",
+	"配置修改":        "configuration",
+	OtherCategory: "other work",
+	"无扩展名":        "files without extension",
+	"配置文件":        "config files",
+}
+
+// countEntry 名称及计数，用于按数量排序
+type countEntry struct {
+	Name  string
+	Count int
+}
+
+// narrativeFacts 从报告中提取的总结要素
+type narrativeFacts struct {
+	reportType string
+	empty      bool
+	commits    int
+	files      int
+	additions  int
+	deletions  int
+	repos      int
+	categories []countEntry // 按提交数从多到少，不含其他
+	other      int          // 其他分类的提交数
+	scopes     []countEntry // 最常见的 scope
+	dirs       []countEntry // 改动最多的顶层目录
+	fileTypes  []countEntry // 改动最多的文件类型
+	highlights []string     // 代表性工作
+	breaking   []string     // 破坏性变更涉及的 scope，没有 scope 时为提交标题
+	busiest    countEntry   // 提交最多的日期、周或月份
+	busiestBy  string       // day, week, month
+	members    int          // 团队成员数
+	topMember  countEntry   // 提交最多的成员
+}
+
+// BuildNarrative 根据报告的统计和分类生成一段叙述性总结，lang 为 zh 或 en
+// 结果只取决于报告内容，不调用任何外部服务
+func BuildNarrative(report *Report, lang string) string {
+	facts := collectNarrativeFacts(report)
+	if lang == NarrativeEnglish {
+		return narrativeEN(facts)
+	}
+	return narrativeZH(facts)
+}
+
+// collectNarrativeFacts 提取报告中用于总结的要素
+func collectNarrativeFacts(report *Report) *narrativeFacts {
+	facts := &narrativeFacts{reportType: report.Type, repos: len(report.Repos)}
+	if report.Summary == nil || report.Summary.TotalCommits == 0 {
+		facts.empty = true
+		return facts
+	}
+
+	s := report.Summary
+	facts.commits = s.TotalCommits
+	facts.files = s.TotalFiles
+	facts.additions = s.TotalAdditions
+	facts.deletions = s.TotalDeletions
+
+	for name, commits := range report.Categories {
+		if name == OtherCategory {
+			facts.other = len(commits)
+			continue
+		}
+		facts.categories = append(facts.categories, countEntry{name, len(commits)})
+	}
+	sortCounts(facts.categories)
+
+	for name, commits := range report.Scopes {
+		facts.scopes = append(facts.scopes, countEntry{name, len(commits)})
+	}
+	sortCounts(facts.scopes)
+	facts.scopes = topCounts(facts.scopes, 3)
+
+	dirs := make(map[string]int)
+	for _, commit := range report.Commits {
+		seen := make(map[string]bool)
+		for _, file := range commit.Files {
+			dir, _, found := strings.Cut(file, "/")
+			if found && !seen[dir] {
+				seen[dir] = true
+				dirs[dir]++
+			}
+		}
+	}
+	facts.dirs = topCounts(countsFromMap(dirs), 3)
+	facts.fileTypes = topCounts(countsFromMap(s.FileTypes), 2)
+
+	// 代表性工作取自提交最多的分类
+	if len(facts.categories) > 0 {
+		seen := make(map[string]bool)
+		for _, commit := range report.Categories[facts.categories[0].Name] {
+			text := commit.Description
+			if text == "" {
+				text = commit.Message
+			}
+			if text == "" || seen[text] {
+				continue
+			}
+			seen[text] = true
+			facts.highlights = append(facts.highlights, text)
+			if len(facts.highlights) == narrativeMaxHighlights {
+				break
+			}
+		}
+	}
+
+	for _, commit := range report.Breaking {
+		if commit.Scope != "" {
+			facts.breaking = appendUnique(facts.breaking, commit.Scope)
+		} else {
+			facts.breaking = appendUnique(facts.breaking, commit.Message)
+		}
+	}
+
+	// 周期较长时按月或按周找出最活跃的时间段
+	for _, stats := range []struct {
+		by string
+		m  map[string]int
+	}{{"month", s.MonthlyStats}, {"week", s.WeeklyStats}, {"day", s.DailyStats}} {
+		if len(stats.m) > 1 {
+			counts := countsFromMap(stats.m)
+			facts.busiest, facts.busiestBy = counts[0], stats.by
+			break
+		}
+	}
+
+	if report.Team && len(report.Members) > 0 {
+		facts.members = len(report.Members)
+		for _, member := range report.Members {
+			if len(member.Commits) > facts.topMember.Count {
+				facts.topMember = countEntry{member.Name, len(member.Commits)}
+			}
+		}
+	}
+
+	return facts
+}
+
+// narrativeZH 生成中文总结
+func narrativeZH(f *narrativeFacts) string {
+	period := reportPeriodName(f.reportType)
+	if f.empty {
+		return period + "没有提交记录。"
+	}
+
+	var paragraphs []string
+
+	var work strings.Builder
+	if len(f.categories) > 0 {
+		items := make([]string, len(f.categories))
+		for i, c := range f.categories {
+			items[i] = fmt.Sprintf("%d 项%s", c.Count, c.Name)
+		}
+		fmt.Fprintf(&work, "%s主要完成了 %s", period, joinZH(items))
+		if f.other > 0 {
+			fmt.Fprintf(&work, "，另有 %d 项其他工作", f.other)
+		}
+	} else {
+		fmt.Fprintf(&work, "%s共完成 %d 项工作", period, f.other)
+	}
+	if focus := focusZH(f); focus != "" {
+		work.WriteString("，" + focus)
+	}
+	work.WriteString("。")
+	if len(f.highlights) > 0 {
+		fmt.Fprintf(&work, "主要工作包括：%s。", strings.Join(f.highlights, "；"))
+	}
+	paragraphs = append(paragraphs, work.String())
+
+	var stats strings.Builder
+	if f.members > 0 {
+		fmt.Fprintf(&stats, "团队 %d 人共提交 %d 次", f.members, f.commits)
+	} else {
+		fmt.Fprintf(&stats, "共提交 %d 次", f.commits)
+	}
+	if f.repos > 1 {
+		fmt.Fprintf(&stats, "，覆盖 %d 个仓库", f.repos)
+	}
+	if f.files > 0 {
+		fmt.Fprintf(&stats, "，修改 %d 个文件，新增 %d 行、删除 %d 行", f.files, f.additions, f.deletions)
+	}
+	if len(f.fileTypes) > 0 {
+		fmt.Fprintf(&stats, "，主要涉及 %s 文件", joinZH(countNames(f.fileTypes)))
+	}
+	stats.WriteString("。")
+	if f.busiest.Count > 0 {
+		fmt.Fprintf(&stats, "提交最集中的是%s（%d 次）。", busiestNameZH(f.busiest.Name, f.busiestBy), f.busiest.Count)
+	}
+	if f.members > 1 && f.topMember.Count > 0 {
+		fmt.Fprintf(&stats, "%s 提交最多（%d 次）。", f.topMember.Name, f.topMember.Count)
+	}
+	paragraphs = append(paragraphs, stats.String())
+
+	if len(f.breaking) > 0 {
+		paragraphs = append(paragraphs, fmt.Sprintf("需要注意，有 %d 项破坏性变更（%s），升级时请关注兼容性。", len(f.breaking), strings.Join(f.breaking, "、")))
+	}
+
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// focusZH 描述工作集中的模块或目录
+func focusZH(f *narrativeFacts) string {
+	if len(f.scopes) > 0 {
+		return fmt.Sprintf("集中在 %s 模块", joinZH(countNames(f.scopes)))
+	}
+	if len(f.dirs) > 0 {
+		return fmt.Sprintf("改动集中在 %s 目录", joinZH(countNames(f.dirs)))
+	}
+	return ""
+}
+
+// busiestNameZH 把统计键转换为中文日期描述
+func busiestNameZH(key, by string) string {
+	var year, month, day, week int
+	switch by {
+	case "month":
+		if _, err := fmt.Sscanf(key, "%d-%d", &year, &month); err == nil {
+			return fmt.Sprintf(" %d年%d月", year, month)
+		}
+	case "week":
+		if _, err := fmt.Sscanf(key, "%d-W%d", &year, &week); err == nil {
+			return fmt.Sprintf(" %d年第%d周", year, week)
+		}
+	case "day":
+		if _, err := fmt.Sscanf(key, "%d-%d", &month, &day); err == nil {
+			return fmt.Sprintf(" %d月%d日", month, day)
+		}
+	}
+	return " " + key
+}
+
+// narrativeEN 生成英文总结
+func narrativeEN(f *narrativeFacts) string {
+	period := reportPeriodNameEN(f.reportType)
+	if f.empty {
+		return fmt.Sprintf("No commits %s.", period)
+	}
+
+	var paragraphs []string
+
+	var work strings.Builder
+	if len(f.categories) > 0 {
+		items := make([]string, len(f.categories))
+		for i, c := range f.categories {
+			items[i] = fmt.Sprintf("%s (%d)", categoryNameEN(c.Name), c.Count)
+		}
+		fmt.Fprintf(&work, "Work %s covered %s", period, joinEN(items))
+		if f.other > 0 {
+			fmt.Fprintf(&work, ", plus %s", plural(f.other, "other change", "other changes"))
+		}
+	} else {
+		fmt.Fprintf(&work, "Work %s covered %s", period, plural(f.other, "change", "changes"))
+	}
+	if len(f.scopes) > 0 {
+		fmt.Fprintf(&work, ", focused on the %s %s", joinEN(countNames(f.scopes)), pluralWord(len(f.scopes), "module", "modules"))
+	} else if len(f.dirs) > 0 {
+		fmt.Fprintf(&work, ", mostly in the %s %s", joinEN(countNames(f.dirs)), pluralWord(len(f.dirs), "directory", "directories"))
+	}
+	work.WriteString(".")
+	if len(f.highlights) > 0 {
+		fmt.Fprintf(&work, " Highlights: %s.", strings.Join(f.highlights, "; "))
+	}
+	paragraphs = append(paragraphs, work.String())
+
+	var stats strings.Builder
+	if f.members > 0 {
+		fmt.Fprintf(&stats, "%s made %s", plural(f.members, "team member", "team members"), plural(f.commits, "commit", "commits"))
+	} else {
+		fmt.Fprintf(&stats, "%s in total", plural(f.commits, "commit", "commits"))
+	}
+	if f.repos > 1 {
+		fmt.Fprintf(&stats, " across %d repositories", f.repos)
+	}
+	if f.files > 0 {
+		fmt.Fprintf(&stats, ", touching %s (+%d/-%d lines)", plural(f.files, "file", "files"), f.additions, f.deletions)
+	}
+	if len(f.fileTypes) > 0 {
+		names := countNames(f.fileTypes)
+		for i, name := range names {
+			names[i] = categoryNameEN(name)
+		}
+		fmt.Fprintf(&stats, ", mostly %s", joinEN(names))
+	}
+	stats.WriteString(".")
+	if f.busiest.Count > 0 {
+		fmt.Fprintf(&stats, " The busiest %s was %s with %s.", f.busiestBy, f.busiest.Name, plural(f.busiest.Count, "commit", "commits"))
+	}
+	if f.members > 1 && f.topMember.Count > 0 {
+		fmt.Fprintf(&stats, " %s was the most active with %s.", f.topMember.Name, plural(f.topMember.Count, "commit", "commits"))
+	}
+	paragraphs = append(paragraphs, stats.String())
+
+	if len(f.breaking) > 0 {
+		paragraphs = append(paragraphs, fmt.Sprintf("Note: %s (%s) may affect compatibility.", plural(len(f.breaking), "breaking change", "breaking changes"), strings.Join(f.breaking, ", ")))
+	}
+
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// reportPeriodNameEN 返回报告周期的英文称呼
+func reportPeriodNameEN(reportType string) string {
+	switch reportType {
+	case ReportTypeDaily:
+		return "today"
+	case ReportTypeWeekly:
+		return "this week"
+	case ReportTypeMonthly:
+		return "this month"
+	case ReportTypeQuarterly:
+		return "this quarter"
+	case ReportTypeYearly:
+		return "this year"
+	default:
+		return "in this period"
+	}
+}
+
+// categoryNameEN 返回分类或文件类型的英文名称
+func categoryNameEN(name string) string {
+	if en, ok := categoryNamesEN[name]; ok {
+		return en
+	}
+	return name
+}
+
+// sortCounts 按计数从多到少排序，计数相同时按名称排序
+func sortCounts(counts []countEntry) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Name < counts[j].Name
+	})
+}
+
+// countsFromMap 把计数表转换为排序后的列表
+func countsFromMap(m map[string]int) []countEntry {
+	counts := make([]countEntry, 0, len(m))
+	for name, count := range m {
+		counts = append(counts, countEntry{name, count})
+	}
+	sortCounts(counts)
+	return counts
+}
+
+// topCounts 返回前 n 项
+func topCounts(counts []countEntry, n int) []countEntry {
+	if len(counts) > n {
+		return counts[:n]
+	}
+	return counts
+}
+
+// countNames 返回各项的名称
+func countNames(counts []countEntry) []string {
+	names := make([]string, len(counts))
+	for i, c := range counts {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// joinZH 以顿号连接
+func joinZH(items []string) string {
+	return strings.Join(items, "、")
+}
+
+// joinEN 以逗号连接，最后两项用 and 连接
+func joinEN(items []string) string {
+	switch len(items) {
+	case 0:
+		return ""
+	case 1:
+		return items[0]
+	case 2:
+		return items[0] + " and " + items[1]
+	default:
+		return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+	}
+}
+
+// plural 返回带数量的英文名词
+func plural(n int, singular, pluralForm string) string {
+	return fmt.Sprintf("%d %s", n, pluralWord(n, singular, pluralForm))
+}
+
+// pluralWord 按数量选择单复数
+func pluralWord(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return singular
+	}
+	return pluralForm
+}
+
+// reportFromExport 从结构化导出还原报告，用于离线生成总结等只拿到导出结果的场景
+// 分组中引用的哈希不在 commits 中时忽略；排行榜和OKR进度不还原
+func reportFromExport(export *ReportExport) *Report {
+	report := &Report{
+		Type:        export.Type,
+		Period:      export.Period,
+		Since:       export.Since,
+		Until:       export.Until,
+		Author:      export.Author,
+		Team:        export.Team,
+		Narrative:   export.Narrative,
+		GeneratedAt: export.GeneratedAt,
+		Summary:     importSummary(export.Summary),
+	}
+
+	commits := make(map[string]*GitCommit, len(export.Commits))
+	for _, c := range export.Commits {
+		commit := importCommit(c)
+		commits[commit.Hash] = commit
+		report.Commits = append(report.Commits, commit)
+	}
+	lookup := func(hashes []string) []*GitCommit {
+		var result []*GitCommit
+		for _, hash := range hashes {
+			if commit, ok := commits[hash]; ok {
+				result = append(result, commit)
+			}
+		}
+		return result
+	}
+
+	for _, repo := range export.Repos {
+		report.Repos = append(report.Repos, &RepoSummary{
+			Name:      repo.Name,
+			Path:      repo.Path,
+			Branch:    repo.Branch,
+			URL:       repo.URL,
+			Commits:   repo.Commits,
+			Files:     repo.Files,
+			Additions: repo.Additions,
+			Deletions: repo.Deletions,
+			Error:     repo.Error,
+		})
+	}
+
+	report.Categories = importGroups(export.Categories, lookup)
+	report.Scopes = importGroups(export.Scopes, lookup)
+	report.Breaking = lookup(export.Breaking)
+
+	for _, m := range export.Members {
+		member := &MemberReport{
+			Name:       m.Name,
+			Summary:    importSummary(m.Summary),
+			Categories: importGroups(m.Categories, lookup),
+		}
+		// 成员的提交为其各分类提交的并集，保持报告中的顺序
+		inMember := make(map[*GitCommit]bool)
+		for _, group := range member.Categories {
+			for _, commit := range group {
+				inMember[commit] = true
+			}
+		}
+		for _, commit := range report.Commits {
+			if inMember[commit] {
+				member.Commits = append(member.Commits, commit)
+			}
+		}
+		report.Members = append(report.Members, member)
+	}
+
+	return report
+}
+
+// importSummary 还原统计摘要
+func importSummary(export SummaryExport) *ReportSummary {
+	summary := &ReportSummary{
+		TotalCommits:   export.TotalCommits,
+		TotalFiles:     export.TotalFiles,
+		TotalAdditions: export.TotalAdditions,
+		TotalDeletions: export.TotalDeletions,
+		FileTypes:      map[string]int{},
+		DailyStats:     map[string]int{},
+		WeeklyStats:    map[string]int{},
+		MonthlyStats:   map[string]int{},
+	}
+	copyCounts(summary.FileTypes, export.FileTypes)
+	copyCounts(summary.DailyStats, export.DailyStats)
+	copyCounts(summary.WeeklyStats, export.WeeklyStats)
+	copyCounts(summary.MonthlyStats, export.MonthlyStats)
+	for _, file := range export.TopFiles {
+		summary.TopFiles = append(summary.TopFiles, file.Path)
+		summary.TopFileCounts = append(summary.TopFileCounts, FileCount{Path: file.Path, Count: file.Count})
+	}
+	return summary
+}
+
+// importGroups 按哈希还原分组
+func importGroups(groups []GroupExport, lookup func(hashes []string) []*GitCommit) map[string][]*GitCommit {
+	result := make(map[string][]*GitCommit, len(groups))
+	for _, group := range groups {
+		if commits := lookup(group.Commits); len(commits) > 0 {
+			result[group.Name] = commits
+		}
+	}
+	return result
+}
+
+// importCommit 还原提交记录
+func importCommit(export CommitExport) *GitCommit {
+	commit := &GitCommit{
+		Hash:           export.Hash,
+		Repo:           export.Repo,
+		Author:         export.Author,
+		Email:          export.Email,
+		Date:           export.Date,
+		Committer:      export.Committer,
+		CommitterEmail: export.CommitterEmail,
+		CommitDate:     export.CommitDate,
+		Message:        export.Message,
+		Body:           export.Body,
+		Type:           export.Type,
+		Scope:          export.Scope,
+		Description:    export.Description,
+		Breaking:       export.Breaking,
+		BreakingNote:   export.BreakingNote,
+		Additions:      export.Additions,
+		Deletions:      export.Deletions,
+		Files:          []string{},
+	}
+
+	for _, trailer := range export.Trailers {
+		commit.Trailers = append(commit.Trailers, Trailer{Key: trailer.Key, Value: trailer.Value})
+	}
+	for _, identity := range export.CoAuthors {
+		commit.CoAuthors = append(commit.CoAuthors, Identity{Name: identity.Name, Email: identity.Email})
+	}
+	for _, file := range export.Files {
+		commit.Files = append(commit.Files, file.Path)
+		commit.Changes = append(commit.Changes, FileChange{
+			Path:      file.Path,
+			OldPath:   file.OldPath,
+			Additions: file.Additions,
+			Deletions: file.Deletions,
+			Binary:    file.Binary,
+		})
+	}
+
+	return commit
+}
+
+// narrativeItemRegex 匹配渲染后报告中的工作条目，如 "# 3. [api] feat: xxx"、"2. xxx" 或 "- `1a2b3c4d` xxx"
+var narrativeItemRegex = regexp.MustCompile(`^(?:#{1,6}\s+)?(?:\d+\.|[-*])\s+(?:\x60?([0-9a-f]{7,40})\x60?\s+)?(?:\[([^\]]+)\]\s+)?(.+)$`)
+
+// narrativeSuffixRegex 匹配条目末尾的代码行数或哈希，如 "（+5/-0）" 或 " (`1a2b3c4d`)"
+var narrativeSuffixRegex = regexp.MustCompile(`\s*(?:（\+\d+/-\d+）|\(\x60([0-9a-f]{7,40})\x60\))$`)
+
+// narrativeSkipSections 解析报告文本时跳过的章节，这些章节重复列出已有的提交
+var narrativeSkipSections = []string{"破坏性变更", "OKR", "统计", "热点文件", "修改最多的文件", "按模块", "趋势", "技术栈", "工作总结", "计划"}
+
+// reportFromMarkdown 从渲染后的报告文本中还原提交条目，用于只有报告文本时生成总结
+// 只能得到提交标题和仓库，文件和代码行数等统计为空
+func reportFromMarkdown(content, reportType string, config *Config) *Report {
+	var commits []*GitCommit
+	seen := make(map[string]bool)
+	skip, hashed := false, false
+
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		matches := narrativeItemRegex.FindStringSubmatch(line)
+		if matches == nil {
+			if level := headingLevel(line); level > 0 {
+				skip = false
+				for _, name := range narrativeSkipSections {
+					if strings.Contains(line, name) {
+						skip = true
+					}
+				}
+			}
+			continue
+		}
+		if skip {
+			continue
+		}
+
+		hash, message := matches[1], matches[3]
+		if suffix := narrativeSuffixRegex.FindStringSubmatch(message); suffix != nil {
+			message = strings.TrimSuffix(message, suffix[0])
+			if hash == "" {
+				hash = suffix[1]
+			}
+		}
+		message = strings.TrimSpace(strings.ReplaceAll(message, "**", ""))
+
+		key := hash
+		if key == "" {
+			key = matches[2] + "\x00" + message
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		commit := &GitCommit{Hash: hash, Repo: matches[2], Message: message}
+		parseConventionalCommit(commit)
+		commits = append(commits, commit)
+		hashed = hashed || hash != ""
+	}
+
+	// 带哈希的报告中，不带哈希的条目是文件列表、统计等附属信息
+	if hashed {
+		var withHash []*GitCommit
+		for _, commit := range commits {
+			if commit.Hash != "" {
+				withHash = append(withHash, commit)
+			}
+		}
+		commits = withHash
+	}
+
+	generator := NewMultiRepoReportGenerator(nil, "", config)
+	repos := make(map[string]bool)
+	for _, commit := range commits {
+		if commit.Repo != "" {
+			repos[commit.Repo] = true
+		}
+	}
+
+	report := &Report{
+		Type:       reportType,
+		Commits:    commits,
+		Summary:    &ReportSummary{TotalCommits: len(commits)},
+		Categories: generator.categorizeCommits(commits),
+		Scopes:     groupByScope(commits),
+		Breaking:   collectBreakingChanges(commits),
+	}
+	for repo := range repos {
+		report.Repos = append(report.Repos, &RepoSummary{Name: repo})
+	}
+	return report
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestReport 不读取仓库，直接用给定提交生成报告
+func newTestReport(t *testing.T, team bool, commits ...*GitCommit) *Report {
+	t.Helper()
+	since := time.Date(2026, 10, 12, 0, 0, 0, 0, time.Local)
+	until := since.AddDate(0, 0, 7).Add(-time.Second)
+
+	for _, commit := range commits {
+		parseConventionalCommit(commit)
+		for _, change := range commit.Changes {
+			commit.Files = append(commit.Files, change.Path)
+			commit.Additions += change.Additions
+			commit.Deletions += change.Deletions
+		}
+	}
+
+	rg := NewReportGenerator("", "张三", DefaultConfig())
+	rg.SetTeamMode(team)
+	report := &Report{
+		Type:        ReportTypeWeekly,
+		Since:       since,
+		Until:       until,
+		Period:      rg.formatRange(since, until),
+		Author:      "张三",
+		Repos:       []*RepoSummary{{Name: "api", Commits: len(commits)}},
+		Commits:     commits,
+		Summary:     rg.generateSummary(commits, ReportTypeWeekly, since, until),
+		Categories:  rg.categorizeCommits(commits),
+		Scopes:      groupByScope(commits),
+		Breaking:    collectBreakingChanges(commits),
+		GeneratedAt: until,
+	}
+	if team {
+		report.Team = true
+		report.Members = rg.buildMemberReports(commits, ReportTypeWeekly, report)
+	}
+	return report
+}
+
+// testReportCommits 覆盖分类、模块、破坏性变更和多个作者的提交
+func testReportCommits() []*GitCommit {
+	day := time.Date(2026, 10, 13, 10, 0, 0, 0, time.Local)
+	return []*GitCommit{
+		{
+			Hash: "1111111111111111111111111111111111111111", Repo: "api", Author: "张三", Email: "zhangsan@example.com", Date: day,
+			Message: "feat(export): add yaml export",
+			Changes: []FileChange{{Path: "export/yaml.go", Additions: 120, Deletions: 4}, {Path: "export/yaml_test.go", Additions: 60}},
+		},
+		{
+			Hash: "2222222222222222222222222222222222222222", Repo: "api", Author: "李四", Email: "lisi@example.com", Date: day.AddDate(0, 0, 1),
+			Message: "fix(auth)!: reject expired tokens", Body: "BREAKING CHANGE: tokens are checked on every request",
+			Changes:  []FileChange{{Path: "auth/token.go", Additions: 15, Deletions: 8}},
+			Trailers: []Trailer{{Key: "Refs", Value: "#42"}},
+		},
+		{
+			Hash: "3333333333333333333333333333333333333333", Repo: "api", Author: "张三", Email: "zhangsan@example.com", Date: day.AddDate(0, 0, 2),
+			Message: "更新部署文档",
+			Changes: []FileChange{{Path: "docs/deploy.md", Additions: 30, Deletions: 10}, {Path: "logo.png", Binary: true}},
+		},
+	}
+}
+
+func TestReportFromExport(t *testing.T) {
+	for _, team := range []bool{false, true} {
+		name := "个人报告"
+		if team {
+			name = "团队报告"
+		}
+		t.Run(name, func(t *testing.T) {
+			report := newTestReport(t, team, testReportCommits()...)
+			export := NewReportExport(report)
+			restored := reportFromExport(export)
+
+			if got := NewReportExport(restored); !reflect.DeepEqual(got, export) {
+				t.Errorf("还原后再次导出的结果不同:\n得到 %+v\n期望 %+v", got, export)
+			}
+			for _, lang := range []string{NarrativeChinese, NarrativeEnglish} {
+				if got, want := BuildNarrative(restored, lang), BuildNarrative(report, lang); got != want {
+					t.Errorf("%s 总结 = %q, 期望 %q", lang, got, want)
+				}
+			}
+			if team && len(restored.Members) > 0 && len(restored.Members[0].Commits) == 0 {
+				t.Errorf("成员 %s 没有还原提交", restored.Members[0].Name)
+			}
+		})
+	}
+}
+
+func TestReportFromExportIgnoresUnknownHashes(t *testing.T) {
+	export := NewReportExport(newTestReport(t, false, testReportCommits()...))
+	export.Categories = append(export.Categories, GroupExport{Name: "未知", Count: 1, Commits: []string{"ffff"}})
+	export.Breaking = append(export.Breaking, "ffff")
+
+	report := reportFromExport(export)
+	if _, ok := report.Categories["未知"]; ok {
+		t.Error("只引用未知哈希的分组应被忽略")
+	}
+	if len(report.Breaking) != 1 {
+		t.Errorf("Breaking = %d, 期望 1", len(report.Breaking))
+	}
+}
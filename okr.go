@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// OKRSet OKR定义文件
+type OKRSet struct {
+	Period     string       `json:"period"`     // OKR周期，如 2024-Q1
+	Objectives []*Objective `json:"objectives"` // 目标列表
+}
+
+// Objective 目标(O)
+type Objective struct {
+	ID         string       `json:"id"`
+	Title      string       `json:"title"`
+	KeyResults []*KeyResult `json:"key_results"`
+}
+
+// KeyResult 关键结果(KR)
+type KeyResult struct {
+	ID     string  `json:"id"`
+	Title  string  `json:"title"`
+	Target float64 `json:"target"` // 目标值
+	Metric string  `json:"metric"` // 度量方式: commits, additions, changes, files
+	Match  KRMatch `json:"match"`  // 提交匹配规则
+
+	issueKeys []*regexp.Regexp // 编译后的问题单号，由 Validate 生成
+	keywords  []*regexp.Regexp // 编译后的关键词，由 Validate 生成
+}
+
+// KRMatch 提交与KR的匹配规则，任意一条命中即视为相关
+type KRMatch struct {
+	IssueKeys []string `json:"issue_keys"` // 问题单号，如 AUTH-12
+	Paths     []string `json:"paths"`      // 文件路径通配符，支持 **
+	Keywords  []string `json:"keywords"`   // 提交标题关键词，英文按整词匹配
+}
+
+// OKRProgress OKR进度统计
+type OKRProgress struct {
+	Period     string               // OKR周期
+	Objectives []*ObjectiveProgress // 各目标进度
+	Unmapped   []*GitCommit         // 未关联任何KR的提交
+}
+
+// ObjectiveProgress 目标进度
+type ObjectiveProgress struct {
+	Objective  *Objective
+	KeyResults []*KRProgress
+	Progress   float64 // 各KR进度的平均值(0-100)
+}
+
+// KRProgress KR进度
+type KRProgress struct {
+	KeyResult *KeyResult
+	Value     float64      // 当前度量值
+	Progress  float64      // 完成百分比(0-100)
+	Commits   []*GitCommit // 佐证提交
+}
+
+// 支持的KR度量方式
+const (
+	MetricCommits   = "commits"
+	MetricAdditions = "additions"
+	MetricChanges   = "changes"
+	MetricFiles     = "files"
+)
+
+// LoadOKRSet 从JSON文件加载OKR定义
+func LoadOKRSet(filename string) (*OKRSet, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("读取OKR文件失败: %v", err)
+	}
+
+	var set OKRSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("解析OKR文件失败: %v", err)
+	}
+
+	if err := set.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &set, nil
+}
+
+// Validate 校验OKR定义
+func (s *OKRSet) Validate() error {
+	if len(s.Objectives) == 0 {
+		return fmt.Errorf("OKR文件中没有定义任何目标")
+	}
+
+	ids := make(map[string]bool)
+	for i, obj := range s.Objectives {
+		if obj.ID == "" {
+			return fmt.Errorf("第%d个目标缺少id", i+1)
+		}
+		if ids[obj.ID] {
+			return fmt.Errorf("目标id重复: %s", obj.ID)
+		}
+		ids[obj.ID] = true
+
+		for j, kr := range obj.KeyResults {
+			if kr.ID == "" {
+				return fmt.Errorf("目标 %s 的第%d个KR缺少id", obj.ID, j+1)
+			}
+			if ids[kr.ID] {
+				return fmt.Errorf("KR id重复: %s", kr.ID)
+			}
+			ids[kr.ID] = true
+
+			if kr.Target <= 0 {
+				return fmt.Errorf("KR %s 的target必须大于0", kr.ID)
+			}
+			switch kr.Metric {
+			case "":
+				kr.Metric = MetricCommits
+			case MetricCommits, MetricAdditions, MetricChanges, MetricFiles:
+			default:
+				return fmt.Errorf("KR %s 的metric不受支持: %s", kr.ID, kr.Metric)
+			}
+			if len(kr.Match.IssueKeys) == 0 && len(kr.Match.Paths) == 0 && len(kr.Match.Keywords) == 0 {
+				return fmt.Errorf("KR %s 没有定义匹配规则", kr.ID)
+			}
+			kr.compile()
+		}
+	}
+
+	return nil
+}
+
+// Evaluate 将提交归属到各KR并计算进度
+func (s *OKRSet) Evaluate(commits []*GitCommit) *OKRProgress {
+	progress := &OKRProgress{
+		Period: s.Period,
+	}
+
+	mapped := make(map[*GitCommit]bool)
+
+	for _, obj := range s.Objectives {
+		objProgress := &ObjectiveProgress{Objective: obj}
+
+		for _, kr := range obj.KeyResults {
+			krProgress := &KRProgress{KeyResult: kr}
+			files := make(map[string]bool)
+
+			for _, commit := range commits {
+				if !kr.Matches(commit) {
+					continue
+				}
+				mapped[commit] = true
+				krProgress.Commits = append(krProgress.Commits, commit)
+
+				switch kr.Metric {
+				case MetricAdditions:
+					additions, _ := kr.lineChanges(commit)
+					krProgress.Value += float64(additions)
+				case MetricChanges:
+					additions, deletions := kr.lineChanges(commit)
+					krProgress.Value += float64(additions + deletions)
+				case MetricFiles:
+					// 配置了路径规则时只统计命中的文件
+					for _, file := range commit.Files {
+						if len(kr.Match.Paths) == 0 || kr.matchesPath(file) {
+							files[file] = true
+						}
+					}
+				default:
+					krProgress.Value++
+				}
+			}
+
+			if kr.Metric == MetricFiles {
+				krProgress.Value = float64(len(files))
+			}
+
+			krProgress.Progress = krProgress.Value / kr.Target * 100
+			if krProgress.Progress > 100 {
+				krProgress.Progress = 100
+			}
+
+			objProgress.KeyResults = append(objProgress.KeyResults, krProgress)
+			objProgress.Progress += krProgress.Progress
+		}
+
+		if len(objProgress.KeyResults) > 0 {
+			objProgress.Progress /= float64(len(objProgress.KeyResults))
+		}
+
+		progress.Objectives = append(progress.Objectives, objProgress)
+	}
+
+	for _, commit := range commits {
+		if !mapped[commit] {
+			progress.Unmapped = append(progress.Unmapped, commit)
+		}
+	}
+
+	return progress
+}
+
+// compile 编译问题单号和关键词，匹配每个提交时不再重复编译
+func (kr *KeyResult) compile() {
+	kr.issueKeys, kr.keywords = nil, nil
+	for _, key := range kr.Match.IssueKeys {
+		if key != "" {
+			kr.issueKeys = append(kr.issueKeys, issueKeyRegexp(key))
+		}
+	}
+	for _, keyword := range kr.Match.Keywords {
+		if keyword != "" {
+			kr.keywords = append(kr.keywords, keywordRegexp(strings.ToLower(keyword)))
+		}
+	}
+}
+
+// Matches 判断提交是否与KR相关，KR需先经过 Validate
+func (kr *KeyResult) Matches(commit *GitCommit) bool {
+	// 问题单号也可能出现在正文或 Refs、Closes 等 trailer 中
+	fullMessage := commit.FullMessage()
+	for _, re := range kr.issueKeys {
+		if re.MatchString(fullMessage) {
+			return true
+		}
+	}
+
+	message := strings.ToLower(commit.Message)
+	for _, re := range kr.keywords {
+		if re.MatchString(message) {
+			return true
+		}
+	}
+
+	for _, file := range commit.Files {
+		if kr.matchesPath(file) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesPath 判断文件是否命中KR的路径规则
+func (kr *KeyResult) matchesPath(file string) bool {
+	for _, pattern := range kr.Match.Paths {
+		if matchPathGlob(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// lineChanges 返回提交中计入KR的新增和删除行数
+// 配置了路径规则时只统计命中的文件，与 files 度量一致
+func (kr *KeyResult) lineChanges(commit *GitCommit) (int, int) {
+	if len(kr.Match.Paths) == 0 {
+		return commit.Additions, commit.Deletions
+	}
+
+	var additions, deletions int
+	for _, change := range commit.Changes {
+		if kr.matchesPath(change.Path) {
+			additions += change.Additions
+			deletions += change.Deletions
+		}
+	}
+	return additions, deletions
+}
+
+// issueKeyRegexp 匹配完整的问题单号，AUTH-1 不会命中 AUTH-12
+func issueKeyRegexp(key string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(^|[^A-Za-z0-9_-])` + regexp.QuoteMeta(key) + `($|[^A-Za-z0-9_])`)
+}
+
+// matchPathGlob 匹配文件路径，在 path.Match 的基础上支持 ** 匹配任意层级目录
+func matchPathGlob(pattern, name string) bool {
+	return matchGlobSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchGlobSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// ** 可以匹配零个或多个目录
+			for i := 0; i <= len(name); i++ {
+				if matchGlobSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
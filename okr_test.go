@@ -0,0 +1,88 @@
+package main
+
+import "testing"
+
+func TestKeyResultMatches(t *testing.T) {
+	kr := &KeyResult{
+		ID: "KR1",
+		Match: KRMatch{
+			IssueKeys: []string{"AUTH-12"},
+			Paths:     []string{"auth/**"},
+			Keywords:  []string{"login", "单点登录"},
+		},
+	}
+	kr.compile()
+
+	tests := []struct {
+		name   string
+		commit *GitCommit
+		want   bool
+	}{
+		{"标题中的问题单号", &GitCommit{Message: "AUTH-12 refresh tokens"}, true},
+		{"trailer 中的问题单号", &GitCommit{Message: "refresh tokens", Body: "Refs: auth-12"}, true},
+		{"问题单号需完整", &GitCommit{Message: "AUTH-123 refresh tokens"}, false},
+		{"英文关键词整词匹配", &GitCommit{Message: "Fix Login redirect"}, true},
+		{"英文关键词允许词尾", &GitCommit{Message: "add logins audit"}, true},
+		{"英文关键词不匹配词的一部分", &GitCommit{Message: "support loginless mode"}, false},
+		{"中文关键词子串匹配", &GitCommit{Message: "接入单点登录服务"}, true},
+		{"路径匹配", &GitCommit{Message: "tidy", Files: []string{"auth/session/store.go"}}, true},
+		{"路径不匹配", &GitCommit{Message: "tidy", Files: []string{"web/auth.go"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := kr.Matches(tt.commit); got != tt.want {
+				t.Errorf("Matches = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOKRSetEvaluateMetrics(t *testing.T) {
+	commits := []*GitCommit{
+		{
+			Message:   "feat: sso login",
+			Files:     []string{"auth/sso.go", "web/login.tsx", "auth/sso_test.go"},
+			Additions: 180, Deletions: 25,
+			Changes: []FileChange{
+				{Path: "auth/sso.go", Additions: 100, Deletions: 20},
+				{Path: "web/login.tsx", Additions: 50, Deletions: 5},
+				{Path: "auth/sso_test.go", Additions: 30},
+			},
+		},
+		{
+			Message:   "docs: login guide",
+			Files:     []string{"docs/login.md"},
+			Additions: 40, Deletions: 2,
+			Changes: []FileChange{{Path: "docs/login.md", Additions: 40, Deletions: 2}},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		metric string
+		match  KRMatch
+		want   float64
+	}{
+		{"路径规则下新增行数只统计命中的文件", MetricAdditions, KRMatch{Paths: []string{"auth/**"}}, 130},
+		{"路径规则下变更行数只统计命中的文件", MetricChanges, KRMatch{Paths: []string{"auth/**"}}, 150},
+		{"关键词命中的提交在路径规则下只统计命中的文件", MetricChanges, KRMatch{Paths: []string{"auth/**"}, Keywords: []string{"login"}}, 150},
+		{"没有路径规则时统计整个提交", MetricChanges, KRMatch{Keywords: []string{"login"}}, 247},
+		{"路径规则下文件数", MetricFiles, KRMatch{Paths: []string{"auth/**"}}, 2},
+		{"提交数", MetricCommits, KRMatch{Keywords: []string{"login"}}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := &OKRSet{Objectives: []*Objective{{
+				ID:         "O1",
+				KeyResults: []*KeyResult{{ID: "KR1", Target: 1000, Metric: tt.metric, Match: tt.match}},
+			}}}
+			if err := set.Validate(); err != nil {
+				t.Fatalf("Validate 返回错误: %v", err)
+			}
+			progress := set.Evaluate(commits)
+			if got := progress.Objectives[0].KeyResults[0].Value; got != tt.want {
+				t.Errorf("Value = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultOKRDraftAttempts 模型输出不符合 schema 时的最大尝试次数
+const DefaultOKRDraftAttempts = 3
+
+// okrDraftMaxCommits 发送给模型的提交数上限，超出部分只计入统计
+const okrDraftMaxCommits = 300
+
+// okrDraftHashLength 发送给模型的提交哈希长度，模型引用的佐证哈希据此还原为完整哈希
+const okrDraftHashLength = 8
+
+// okrDraftSchema OKR草稿的 JSON Schema，写入提示词并由 validate 按相同约束校验
+const okrDraftSchema = `{
+  "type": "object",
+  "required": ["objectives"],
+  "additionalProperties": false,
+  "properties": {
+    "period": {"type": "string"},
+    "objectives": {
+      "type": "array", "minItems": 1, "maxItems": 5,
+      "items": {
+        "type": "object",
+        "required": ["title", "key_results"],
+        "additionalProperties": false,
+        "properties": {
+          "title": {"type": "string", "minLength": 1},
+          "key_results": {
+            "type": "array", "minItems": 1, "maxItems": 5,
+            "items": {
+              "type": "object",
+              "required": ["title", "evidence"],
+              "additionalProperties": false,
+              "properties": {
+                "title": {"type": "string", "minLength": 1},
+                "evidence": {"type": "array", "minItems": 1, "items": {"type": "string", "pattern": "^[0-9a-f]{7,40}$"}}
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// okrDraftSystemPrompt 生成OKR草稿时使用的系统提示词
+const okrDraftSystemPrompt = `你是一名熟悉 OKR 的工程经理。用户会提供一个季度的Git提交统计，包括分类、提交记录和修改最多的文件。
+请据此总结这个季度已经达成的目标（Objective）和关键结果（Key Result）：
+- 目标 1 到 5 个，概括这一季度工作的方向和价值
+- 每个目标下 1 到 5 个关键结果，尽量可衡量，引用提交数、功能或修复的问题作为结果
+- 每个关键结果的 evidence 列出支撑它的提交哈希，只能使用输入中出现的哈希
+- 只总结已经完成的工作，不要编造输入中没有的内容
+- 标题使用中文；输入中形如 [[EMAIL_1]] 的占位符原样保留
+
+只输出一个符合以下 JSON Schema 的 JSON 对象，不要输出其他内容：
+` + okrDraftSchema
+
+// OKRDraft AI根据季度提交生成的OKR草稿
+type OKRDraft struct {
+	Period     string            `json:"period"`
+	Objectives []*DraftObjective `json:"objectives"`
+}
+
+// DraftObjective 草稿中的目标
+type DraftObjective struct {
+	Title      string            `json:"title"`
+	KeyResults []*DraftKeyResult `json:"key_results"`
+}
+
+// DraftKeyResult 草稿中的关键结果
+type DraftKeyResult struct {
+	Title    string   `json:"title"`
+	Evidence []string `json:"evidence"` // 佐证提交的完整哈希
+}
+
+// OKRDraftResult 生成OKR草稿的结果
+type OKRDraftResult struct {
+	Draft      *OKRDraft
+	Attempts   int              // 实际调用模型的次数
+	Redactions []RedactionEntry // 发送前被替换的敏感信息
+}
+
+// DraftOKR 把季度报告发送给AI服务商生成OKR草稿
+// 输出不是合法JSON或不符合 schema 时，把错误反馈给模型重试，最多 attempts 次
+func DraftOKR(ctx context.Context, config *Config, report *Report, providerName string, attempts int) (*OKRDraftResult, error) {
+	if len(report.Commits) == 0 {
+		return nil, fmt.Errorf("no commits in %s", report.Period)
+	}
+	if attempts <= 0 {
+		attempts = DefaultOKRDraftAttempts
+	}
+
+	provider, err := config.AI.NewProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	redactor, err := config.Redactor()
+	if err != nil {
+		return nil, err
+	}
+	if redactor, err = redactor.WithTerms(report.Author); err != nil {
+		return nil, err
+	}
+	input, session := redactor.Redact(okrDraftInput(report))
+
+	messages := []ChatMessage{
+		{Role: "system", Content: okrDraftSystemPrompt},
+		{Role: "user", Content: input},
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		reply, err := provider.Complete(ctx, &CompletionRequest{Messages: messages})
+		if err != nil {
+			return nil, err
+		}
+
+		draft, err := parseOKRDraft(session.Restore(reply), report.Commits)
+		if err == nil {
+			if draft.Period == "" {
+				draft.Period = report.Period
+			}
+			return &OKRDraftResult{Draft: draft, Attempts: attempt, Redactions: session.Audit()}, nil
+		}
+
+		lastErr = err
+		messages = append(messages,
+			ChatMessage{Role: "assistant", Content: reply},
+			ChatMessage{Role: "user", Content: fmt.Sprintf("上面的输出不符合要求：%v。请修正后只输出符合 JSON Schema 的 JSON 对象。", err)},
+		)
+	}
+
+	return nil, fmt.Errorf("no valid OKR draft after %d attempts: %v", attempts, lastErr)
+}
+
+// okrDraftInput 把报告整理为发送给模型的文本：统计、按分类列出的提交和热点文件
+func okrDraftInput(report *Report) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "周期: %s\n", report.Period)
+	if report.Author != "" {
+		fmt.Fprintf(&b, "作者: %s\n", report.Author)
+	}
+	if s := report.Summary; s != nil {
+		fmt.Fprintf(&b, "提交数: %d，修改文件: %d，新增: %d 行，删除: %d 行\n", s.TotalCommits, s.TotalFiles, s.TotalAdditions, s.TotalDeletions)
+	}
+
+	// 提交较多的分类排在前面
+	categories := make([]string, 0, len(report.Categories))
+	for category := range report.Categories {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		ci, cj := len(report.Categories[categories[i]]), len(report.Categories[categories[j]])
+		if ci != cj {
+			return ci > cj
+		}
+		return categories[i] < categories[j]
+	})
+
+	listed := 0
+	for _, category := range categories {
+		commits := report.Categories[category]
+		fmt.Fprintf(&b, "\n## %s（%d 个提交）\n", category, len(commits))
+		for _, commit := range commits {
+			if listed >= okrDraftMaxCommits {
+				b.WriteString("- ……\n")
+				break
+			}
+			listed++
+			fmt.Fprintf(&b, "- %s %s\n", shortHash(commit.Hash, okrDraftHashLength), commit.Message)
+		}
+	}
+
+	if report.Summary != nil && len(report.Summary.TopFiles) > 0 {
+		b.WriteString("\n## 修改最多的文件\n")
+		for _, file := range report.Summary.TopFiles {
+			fmt.Fprintf(&b, "- %s\n", file)
+		}
+	}
+
+	return b.String()
+}
+
+// parseOKRDraft 从模型回复中提取JSON并按 schema 校验，佐证哈希还原为完整哈希
+func parseOKRDraft(reply string, commits []*GitCommit) (*OKRDraft, error) {
+	start := strings.Index(reply, "{")
+	end := strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("response contains no JSON object")
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(reply[start : end+1]))
+	decoder.DisallowUnknownFields()
+
+	var draft OKRDraft
+	if err := decoder.Decode(&draft); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	if err := draft.validate(commits); err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// validate 按 okrDraftSchema 的约束校验草稿，并要求佐证哈希对应报告中的提交
+func (d *OKRDraft) validate(commits []*GitCommit) error {
+	if len(d.Objectives) == 0 || len(d.Objectives) > 5 {
+		return fmt.Errorf("objectives must contain 1 to 5 items, got %d", len(d.Objectives))
+	}
+
+	for i, obj := range d.Objectives {
+		if obj == nil || strings.TrimSpace(obj.Title) == "" {
+			return fmt.Errorf("objectives[%d].title is required", i)
+		}
+		if len(obj.KeyResults) == 0 || len(obj.KeyResults) > 5 {
+			return fmt.Errorf("objectives[%d].key_results must contain 1 to 5 items, got %d", i, len(obj.KeyResults))
+		}
+
+		for j, kr := range obj.KeyResults {
+			if kr == nil || strings.TrimSpace(kr.Title) == "" {
+				return fmt.Errorf("objectives[%d].key_results[%d].title is required", i, j)
+			}
+			if len(kr.Evidence) == 0 {
+				return fmt.Errorf("objectives[%d].key_results[%d].evidence must not be empty", i, j)
+			}
+
+			var evidence []string
+			for _, hash := range kr.Evidence {
+				full, err := resolveEvidence(hash, commits)
+				if err != nil {
+					return fmt.Errorf("objectives[%d].key_results[%d].evidence: %v", i, j, err)
+				}
+				evidence = appendUnique(evidence, full)
+			}
+			kr.Evidence = evidence
+		}
+	}
+
+	return nil
+}
+
+// resolveEvidence 把模型引用的哈希前缀还原为报告中提交的完整哈希
+func resolveEvidence(hash string, commits []*GitCommit) (string, error) {
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	if len(hash) < 7 || len(hash) > 40 || strings.Trim(hash, "0123456789abcdef") != "" {
+		return "", fmt.Errorf("%q is not a commit hash", hash)
+	}
+
+	var found string
+	for _, commit := range commits {
+		if strings.HasPrefix(commit.Hash, hash) {
+			if found != "" && found != commit.Hash {
+				return "", fmt.Errorf("hash %s is ambiguous", hash)
+			}
+			found = commit.Hash
+		}
+	}
+	if found == "" {
+		return "", fmt.Errorf("hash %s is not one of the listed commits", hash)
+	}
+	return found, nil
+}
+
+// Markdown 以 Markdown 列表输出草稿，佐证哈希截断为 hashLength 位
+func (d *OKRDraft) Markdown(hashLength int) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# OKR 草稿 - %s\n", d.Period)
+	for i, obj := range d.Objectives {
+		fmt.Fprintf(&b, "\n## O%d %s\n\n", i+1, obj.Title)
+		for j, kr := range obj.KeyResults {
+			hashes := make([]string, len(kr.Evidence))
+			for k, hash := range kr.Evidence {
+				hashes[k] = shortHash(hash, hashLength)
+			}
+			fmt.Fprintf(&b, "- **KR%d.%d** %s\n  - 佐证: %s\n", i+1, j+1, kr.Title, strings.Join(hashes, ", "))
+		}
+	}
+
+	return b.String()
+}
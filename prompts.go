@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// builtinPrompts 内置的提示词模板
+//
+//go:embed prompts/*.tmpl
+var builtinPrompts embed.FS
+
+// DefaultPromptName 未指定提示词时使用的模板
+const DefaultPromptName = "default"
+
+// promptFileExt 提示词模板文件扩展名
+const promptFileExt = ".tmpl"
+
+// redactionNotice 附加在所有提示词之后，要求模型保留脱敏占位符
+const redactionNotice = "报告中形如 [[EMAIL_1]] 的占位符代表已隐藏的信息，请原样保留。"
+
+// Prompt 提示词模板
+// 模板文件以 --- 包围的头部声明标题和说明，正文为 text/template 格式的系统提示词
+type Prompt struct {
+	Name        string `json:"name"`        // 模板名，即不含扩展名的文件名
+	Title       string `json:"title"`       // 显示名称
+	Description string `json:"description"` // 用途说明
+	Source      string `json:"source"`      // builtin 或 custom
+
+	template *template.Template
+}
+
+// PromptData 提示词模板中可用的变量
+type PromptData struct {
+	Type     string // 报告类型，如 weekly
+	TypeName string // 报告类型名称，如 周报
+	Period   string // 报告周期，如 2024年01月15日 至 2024年01月21日
+	Author   string // 报告作者
+}
+
+// PromptLibrary 提示词模板库，自定义目录中的同名模板覆盖内置模板
+type PromptLibrary struct {
+	prompts map[string]*Prompt
+}
+
+// LoadPromptLibrary 加载内置提示词和 dir 目录中的自定义提示词，dir 为空或不存在时只使用内置提示词
+func LoadPromptLibrary(dir string) (*PromptLibrary, error) {
+	library := &PromptLibrary{prompts: make(map[string]*Prompt)}
+
+	if err := library.load(builtinPrompts, "prompts", "builtin"); err != nil {
+		return nil, err
+	}
+
+	if dir != "" {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			if err := library.load(os.DirFS(dir), ".", "custom"); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if _, ok := library.prompts[DefaultPromptName]; !ok {
+		return nil, fmt.Errorf("缺少默认提示词 %s", DefaultPromptName)
+	}
+
+	return library, nil
+}
+
+// load 加载目录中的所有提示词模板
+func (l *PromptLibrary) load(fsys fs.FS, dir, source string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("读取提示词目录失败: %v", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != promptFileExt {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, filepath.ToSlash(filepath.Join(dir, entry.Name())))
+		if err != nil {
+			return fmt.Errorf("读取提示词 %s 失败: %v", entry.Name(), err)
+		}
+
+		prompt, err := parsePrompt(strings.TrimSuffix(entry.Name(), promptFileExt), string(data))
+		if err != nil {
+			return err
+		}
+		prompt.Source = source
+		l.prompts[prompt.Name] = prompt
+	}
+
+	return nil
+}
+
+// parsePrompt 解析提示词模板文件
+func parsePrompt(name, content string) (*Prompt, error) {
+	prompt := &Prompt{Name: name, Title: name}
+
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if rest, ok := strings.CutPrefix(content, "---\n"); ok {
+		header, body, found := strings.Cut(rest, "\n---\n")
+		if !found {
+			return nil, fmt.Errorf("提示词 %s 的头部缺少结束的 ---", name)
+		}
+		for _, line := range strings.Split(header, "\n") {
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			switch strings.TrimSpace(key) {
+			case "title":
+				prompt.Title = strings.TrimSpace(value)
+			case "description":
+				prompt.Description = strings.TrimSpace(value)
+			}
+		}
+		content = body
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(strings.TrimSpace(content))
+	if err != nil {
+		return nil, fmt.Errorf("解析提示词 %s 失败: %v", name, err)
+	}
+	prompt.template = tmpl
+
+	return prompt, nil
+}
+
+// List 返回按名称排序的提示词，默认提示词排在最前
+func (l *PromptLibrary) List() []*Prompt {
+	prompts := make([]*Prompt, 0, len(l.prompts))
+	for _, prompt := range l.prompts {
+		prompts = append(prompts, prompt)
+	}
+	sort.Slice(prompts, func(i, j int) bool {
+		if (prompts[i].Name == DefaultPromptName) != (prompts[j].Name == DefaultPromptName) {
+			return prompts[i].Name == DefaultPromptName
+		}
+		return prompts[i].Name < prompts[j].Name
+	})
+	return prompts
+}
+
+// Render 渲染指定提示词，name 为空时使用默认提示词
+func (l *PromptLibrary) Render(name string, data *PromptData) (string, error) {
+	if name == "" {
+		name = DefaultPromptName
+	}
+
+	prompt, ok := l.prompts[name]
+	if !ok {
+		return "", fmt.Errorf("未知的提示词: %s", name)
+	}
+
+	if data.TypeName == "" {
+		data.TypeName = reportTypeName(data.Type)
+	}
+
+	var buf bytes.Buffer
+	if err := prompt.template.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("渲染提示词 %s 失败: %v", name, err)
+	}
+
+	return strings.TrimSpace(buf.String()) + "\n\n" + redactionNotice, nil
+}
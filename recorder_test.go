@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// requestRecorder 记录测试服务器收到的 JSON 请求，并依次返回预设的响应
+// 服务商和推送渠道的测试共用
+type requestRecorder struct {
+	mu        sync.Mutex
+	paths     []string
+	queries   []string
+	headers   []http.Header
+	bodies    []map[string]interface{}
+	status    int      // 响应状态码，为 0 时返回 200
+	responses []string // 依次返回的响应体，最后一个重复使用；为空时返回 {}
+}
+
+func (r *requestRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, _ := io.ReadAll(req.Body)
+	var body map[string]interface{}
+	json.Unmarshal(data, &body)
+	r.paths = append(r.paths, req.URL.Path)
+	r.queries = append(r.queries, req.URL.RawQuery)
+	r.headers = append(r.headers, req.Header.Clone())
+	r.bodies = append(r.bodies, body)
+
+	if r.status != 0 {
+		w.WriteHeader(r.status)
+	}
+	response := "{}"
+	if len(r.responses) > 0 {
+		response = r.responses[0]
+		if len(r.responses) > 1 {
+			r.responses = r.responses[1:]
+		}
+	}
+	io.WriteString(w, response)
+}
+
+// startTestServer 启动测试服务器并在测试结束时关闭，返回服务器地址
+func startTestServer(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server.URL
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// RedactionSettings 发送给AI前的脱敏配置
+type RedactionSettings struct {
+	Enabled         bool               `json:"enabled"`          // 是否脱敏，默认开启
+	Detectors       []string           `json:"detectors"`        // 启用的内置检测器，为空时全部启用
+	InternalDomains []string           `json:"internal_domains"` // 内部域名，如 corp.example.com，其下的主机名和URL都会被替换
+	Terms           []string           `json:"terms"`            // 需要替换的固定词语，如项目代号、客户名
+	Patterns        []RedactionPattern `json:"patterns"`         // 自定义正则，包含名为 value 的分组时只替换该分组
+}
+
+// RedactionPattern 自定义脱敏正则
+type RedactionPattern struct {
+	Name    string `json:"name"`    // 名称，决定占位符前缀
+	Pattern string `json:"pattern"` // 正则表达式
+}
+
+// RedactionEntry 脱敏审计记录，不包含原始值
+type RedactionEntry struct {
+	Detector    string `json:"detector"`    // 命中的检测器
+	Placeholder string `json:"placeholder"` // 替换后的占位符
+	Preview     string `json:"preview"`     // 遮盖后的原始值，如 zh***@ex***.com
+	Count       int    `json:"count"`       // 出现次数
+	Restored    bool   `json:"restored"`    // 占位符是否出现在AI的回复中并已还原
+}
+
+// 内置检测器名称
+const (
+	DetectorPrivateKey   = "private_key"
+	DetectorToken        = "token"
+	DetectorSecret       = "secret"
+	DetectorRepoURL      = "repo_url"
+	DetectorInternalHost = "internal_host"
+	DetectorEmail        = "email"
+	DetectorIP           = "ip"
+	DetectorTerm         = "term"
+)
+
+// builtinDetectors 按执行顺序排列的内置检测器，靠前的检测器先替换
+var builtinDetectors = []struct {
+	name    string
+	prefix  string
+	pattern string
+}{
+	{DetectorPrivateKey, "PRIVATE_KEY", `-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`},
+	{DetectorToken, "TOKEN", `\b(?:gh[pousr]_[A-Za-z0-9]{30,}|github_pat_[A-Za-z0-9_]{30,}|glpat-[A-Za-z0-9_-]{20,}|xox[abprs]-[A-Za-z0-9-]{10,}|sk-[A-Za-z0-9_-]{20,}|AKIA[0-9A-Z]{16}|AIza[0-9A-Za-z_-]{35}|eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})`},
+	{DetectorSecret, "SECRET", `(?i)\b(?:api[_-]?key|secret|token|password|passwd|pwd|access[_-]?key)\b["']?\s*[:=]\s*["']?(?P<value>[^\s"'` + "`" + `,;]{4,})`},
+	{DetectorRepoURL, "REPO_URL", `(?:\b[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:[A-Za-z0-9._/~-]+|\b(?:ssh|git)://[^\s)>\]]+|\bhttps?://[^\s)>\]]+\.git\b)`},
+	{DetectorInternalHost, "HOST", ``}, // 由内部域名生成
+	{DetectorEmail, "EMAIL", `\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`},
+	{DetectorIP, "IP", `\b(?:\d{1,3}\.){3}\d{1,3}\b`},
+	{DetectorTerm, "NAME", ``}, // 由固定词语生成
+}
+
+// internalHostSuffixes 默认视为内部网络的域名后缀
+var internalHostSuffixes = []string{"internal", "local", "localdomain", "corp", "lan", "intranet"}
+
+// placeholderRegex 匹配占位符，容忍模型在括号内加入空白
+var placeholderRegex = regexp.MustCompile(`\[\[\s*([A-Z][A-Z0-9_]*_\d+)\s*\]\]`)
+
+// Redactor 可逆脱敏器，同一个值在一次脱敏中始终替换为同一个占位符
+type Redactor struct {
+	settings  RedactionSettings
+	terms     []string
+	detectors []*detector
+}
+
+// detector 编译后的检测器
+type detector struct {
+	name   string
+	prefix string
+	re     *regexp.Regexp
+	value  int // 名为 value 的分组序号，0 表示替换整个匹配
+}
+
+// RedactionSession 一次脱敏的上下文，用于还原AI的回复
+type RedactionSession struct {
+	mu           sync.Mutex
+	values       map[string]string // 占位符 -> 原始值
+	placeholders map[string]string // 检测器 + 原始值 -> 占位符
+	counters     map[string]int
+	entries      []*RedactionEntry
+}
+
+// NewRedactor 根据配置创建脱敏器，terms 为额外需要替换的词语（如花名册中的成员名）
+func NewRedactor(settings RedactionSettings, terms []string) (*Redactor, error) {
+	redactor := &Redactor{settings: settings, terms: terms}
+	if !settings.Enabled {
+		return redactor, nil
+	}
+
+	for _, pattern := range settings.Patterns {
+		if pattern.Name == "" || pattern.Pattern == "" {
+			return nil, fmt.Errorf("自定义脱敏规则需要 name 和 pattern")
+		}
+		d, err := newDetector(pattern.Name, placeholderPrefix(pattern.Name), pattern.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("脱敏规则 %s 无效: %v", pattern.Name, err)
+		}
+		redactor.detectors = append(redactor.detectors, d)
+	}
+
+	enabled := make(map[string]bool)
+	for _, name := range settings.Detectors {
+		enabled[name] = true
+	}
+
+	for _, builtin := range builtinDetectors {
+		if len(enabled) > 0 && !enabled[builtin.name] {
+			continue
+		}
+
+		pattern := builtin.pattern
+		switch builtin.name {
+		case DetectorInternalHost:
+			pattern = internalHostPattern(settings.InternalDomains)
+		case DetectorTerm:
+			pattern = termsPattern(append(append([]string{}, settings.Terms...), terms...))
+		}
+		if pattern == "" {
+			continue
+		}
+
+		d, err := newDetector(builtin.name, builtin.prefix, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("脱敏检测器 %s 无效: %v", builtin.name, err)
+		}
+		redactor.detectors = append(redactor.detectors, d)
+	}
+
+	return redactor, nil
+}
+
+// validateRedaction 校验脱敏配置
+func validateRedaction(settings RedactionSettings) []string {
+	var problems []string
+
+	known := make(map[string]bool)
+	for _, builtin := range builtinDetectors {
+		known[builtin.name] = true
+	}
+	for _, name := range settings.Detectors {
+		if !known[name] {
+			problems = append(problems, fmt.Sprintf("redaction.detectors 中未知的检测器 %s", name))
+		}
+	}
+
+	if _, err := NewRedactor(settings, nil); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	return problems
+}
+
+// newDetector 编译检测器
+func newDetector(name, prefix, pattern string) (*detector, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	d := &detector{name: name, prefix: prefix, re: re}
+	for i, group := range re.SubexpNames() {
+		if group == "value" {
+			d.value = i
+		}
+	}
+	return d, nil
+}
+
+// internalHostPattern 匹配内部域名下的主机名及其URL
+// 配置的内部域名本身也会被替换；内置后缀只替换带有主机名的完整域名，如 git.corp
+func internalHostPattern(domains []string) string {
+	const label = `(?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)`
+
+	var hosts []string
+	var configured []string
+	for _, domain := range domains {
+		domain = strings.Trim(strings.TrimSpace(domain), ".")
+		if domain != "" {
+			configured = append(configured, regexp.QuoteMeta(domain))
+		}
+	}
+	if len(configured) > 0 {
+		hosts = append(hosts, label+`*(?:`+strings.Join(configured, "|")+`)`)
+	}
+	hosts = append(hosts, label+`+(?:`+strings.Join(internalHostSuffixes, "|")+`)`)
+
+	return `(?i)(?:\b[a-z][a-z0-9+.-]*://)?\b(?:` + strings.Join(hosts, "|") + `)\b(?::\d+)?(?:/[^\s)>\]]*)?`
+}
+
+// termsPattern 匹配固定词语，长词优先；英文词语按整词、不区分大小写匹配
+func termsPattern(terms []string) string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		// 过短的词语容易误伤正文
+		if len([]rune(term)) < 2 || seen[strings.ToLower(term)] {
+			continue
+		}
+		seen[strings.ToLower(term)] = true
+		unique = append(unique, term)
+	}
+	if len(unique) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(unique, func(i, j int) bool {
+		return len(unique[i]) > len(unique[j])
+	})
+
+	alternatives := make([]string, len(unique))
+	for i, term := range unique {
+		if isASCIIWord(strings.ReplaceAll(term, " ", "")) {
+			alternatives[i] = `(?i:\b` + regexp.QuoteMeta(term) + `\b)`
+		} else {
+			alternatives[i] = regexp.QuoteMeta(term)
+		}
+	}
+	return strings.Join(alternatives, "|")
+}
+
+// placeholderPrefix 把规则名称转换为占位符前缀，如 "jira-key" -> "JIRA_KEY"
+func placeholderPrefix(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(name) {
+		if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	prefix := strings.Trim(b.String(), "_")
+	if prefix == "" || prefix[0] >= '0' && prefix[0] <= '9' {
+		prefix = "CUSTOM_" + prefix
+	}
+	return prefix
+}
+
+// WithTerms 返回额外替换 terms 的脱敏器，如本次报告的作者；没有新词语时返回自身
+func (r *Redactor) WithTerms(terms ...string) (*Redactor, error) {
+	if !r.settings.Enabled {
+		return r, nil
+	}
+
+	known := make(map[string]bool)
+	for _, term := range append(append([]string{}, r.settings.Terms...), r.terms...) {
+		known[strings.ToLower(strings.TrimSpace(term))] = true
+	}
+	var extra []string
+	for _, term := range terms {
+		if key := strings.ToLower(strings.TrimSpace(term)); key != "" && !known[key] {
+			known[key] = true
+			extra = append(extra, term)
+		}
+	}
+	if len(extra) == 0 {
+		return r, nil
+	}
+
+	return NewRedactor(r.settings, append(append([]string{}, r.terms...), extra...))
+}
+
+// Redact 替换文本中的敏感信息，返回脱敏后的文本和用于还原的会话
+func (r *Redactor) Redact(text string) (string, *RedactionSession) {
+	session := &RedactionSession{
+		values:       make(map[string]string),
+		placeholders: make(map[string]string),
+		counters:     make(map[string]int),
+	}
+	return r.RedactWith(session, text), session
+}
+
+// RedactWith 在已有会话中继续脱敏，同一个值与会话中已替换的值使用相同的占位符
+// 用于同一次请求中的多段文本，如系统提示词和报告内容
+func (r *Redactor) RedactWith(session *RedactionSession, text string) string {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	for _, d := range r.detectors {
+		text = d.redact(text, session)
+	}
+	return text
+}
+
+// redact 用占位符替换检测器命中的内容
+func (d *detector) redact(text string, session *RedactionSession) string {
+	var b strings.Builder
+	last := 0
+
+	for _, match := range d.re.FindAllStringSubmatchIndex(text, -1) {
+		start, end := match[0], match[1]
+		if d.value > 0 {
+			start, end = match[2*d.value], match[2*d.value+1]
+			if start < 0 {
+				continue
+			}
+		}
+
+		value := text[start:end]
+		if !d.accept(value, text[end:]) || placeholderRegex.MatchString(value) {
+			continue
+		}
+
+		b.WriteString(text[last:start])
+		b.WriteString("[[" + session.placeholder(d, value) + "]]")
+		last = end
+	}
+
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// accept 过滤检测器的误报，rest 为匹配之后的文本
+func (d *detector) accept(value, rest string) bool {
+	switch d.name {
+	case DetectorIP:
+		// 排除版本号等每段超过255的数字
+		return net.ParseIP(value) != nil
+	case DetectorInternalHost:
+		// 排除 config.local.json 这类文件名
+		if len(rest) > 1 && rest[0] == '.' && unicode.IsLetter(rune(rest[1])) {
+			return false
+		}
+	}
+	return true
+}
+
+// placeholder 返回值对应的占位符，同一个值复用同一个占位符
+func (s *RedactionSession) placeholder(d *detector, value string) string {
+	key := d.name + "\x00" + value
+	if placeholder, ok := s.placeholders[key]; ok {
+		for _, entry := range s.entries {
+			if entry.Placeholder == "[["+placeholder+"]]" {
+				entry.Count++
+			}
+		}
+		return placeholder
+	}
+
+	s.counters[d.prefix]++
+	placeholder := fmt.Sprintf("%s_%d", d.prefix, s.counters[d.prefix])
+	s.placeholders[key] = placeholder
+	s.values[placeholder] = value
+	s.entries = append(s.entries, &RedactionEntry{
+		Detector:    d.name,
+		Placeholder: "[[" + placeholder + "]]",
+		Preview:     maskValue(value),
+		Count:       1,
+	})
+	return placeholder
+}
+
+// Restore 把AI回复中的占位符还原为原始值，并记录哪些占位符被还原
+func (s *RedactionSession) Restore(text string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return placeholderRegex.ReplaceAllStringFunc(text, func(match string) string {
+		name := placeholderRegex.FindStringSubmatch(match)[1]
+		value, ok := s.values[name]
+		if !ok {
+			return match
+		}
+		for _, entry := range s.entries {
+			if entry.Placeholder == "[["+name+"]]" {
+				entry.Restored = true
+			}
+		}
+		return value
+	})
+}
+
+// Audit 返回脱敏审计记录
+func (s *RedactionSession) Audit() []RedactionEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	audit := make([]RedactionEntry, len(s.entries))
+	for i, entry := range s.entries {
+		audit[i] = *entry
+	}
+	return audit
+}
+
+// NewRestorer 创建流式还原器
+func (s *RedactionSession) NewRestorer() *StreamRestorer {
+	return &StreamRestorer{session: s}
+}
+
+// StreamRestorer 还原流式输出中的占位符，占位符可能被拆分在多个增量中
+type StreamRestorer struct {
+	session *RedactionSession
+	pending string
+}
+
+// Write 追加增量内容，返回可以安全输出的已还原内容
+func (r *StreamRestorer) Write(delta string) string {
+	text := r.pending + delta
+	r.pending = ""
+
+	// 末尾可能是未完成的占位符，暂不输出
+	if i := strings.LastIndex(text, "["); i >= 0 && !strings.Contains(text[i:], "]]") {
+		if i > 0 && text[i-1] == '[' {
+			i--
+		}
+		if len(text)-i <= 64 {
+			r.pending = text[i:]
+			text = text[:i]
+		}
+	}
+
+	return r.session.Restore(text)
+}
+
+// Flush 输出剩余的内容
+func (r *StreamRestorer) Flush() string {
+	text := r.pending
+	r.pending = ""
+	return r.session.Restore(text)
+}
+
+// maskValue 遮盖原始值，只保留少量字符便于审计
+func maskValue(value string) string {
+	if local, domain, ok := strings.Cut(value, "@"); ok && !strings.ContainsAny(value, " :/") {
+		return maskPart(local) + "@" + maskPart(domain)
+	}
+	return maskPart(value)
+}
+
+// maskPart 保留前两个字符，其余替换为 ***
+func maskPart(value string) string {
+	runes := []rune(value)
+	if len(runes) <= 2 {
+		return "***"
+	}
+	keep := 2
+	if len(runes) <= 4 {
+		keep = 1
+	}
+	return string(runes[:keep]) + "***"
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// newTestRedactor 创建启用全部内置检测器的脱敏器
+func newTestRedactor(t *testing.T, settings RedactionSettings, terms ...string) *Redactor {
+	t.Helper()
+	settings.Enabled = true
+	redactor, err := NewRedactor(settings, terms)
+	if err != nil {
+		t.Fatalf("NewRedactor 返回错误: %v", err)
+	}
+	return redactor
+}
+
+func TestRedactDetectors(t *testing.T) {
+	settings := RedactionSettings{
+		InternalDomains: []string{"corp.example.com"},
+		Patterns: []RedactionPattern{
+			{Name: "jira-key", Pattern: `\bticket (?P<value>PROJ-\d+)\b`},
+		},
+	}
+	redactor := newTestRedactor(t, settings, "张三", "Alice")
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"邮箱", "联系 zhangsan@example.com 确认", "联系 [[EMAIL_1]] 确认"},
+		{"GitHub token", "token ghp_" + strings.Repeat("a", 36) + " 已轮换", "token [[TOKEN_1]] 已轮换"},
+		{"OpenAI key", "使用 sk-" + strings.Repeat("B", 24), "使用 [[TOKEN_1]]"},
+		{"只替换密码的值", "password=hunter22 登录", "password=[[SECRET_1]] 登录"},
+		{"IPv4 地址", "部署到 10.0.0.12 后重启", "部署到 [[IP_1]] 后重启"},
+		{"版本号不是IP", "升级到 1.2.3.456", "升级到 1.2.3.456"},
+		{"SSH 仓库地址", "克隆 git@github.com:org/repo.git 后构建", "克隆 [[REPO_URL_1]] 后构建"},
+		{"配置的内部域名及路径", "见 https://wiki.corp.example.com/team/page", "见 [[HOST_1]]"},
+		{"内置内部后缀的主机和路径", "推送到 git.corp/team/repo 完成", "推送到 [[HOST_1]] 完成"},
+		{"文件名不是主机名", "修改 config.local.json", "修改 config.local.json"},
+		{"中文成员名", "张三完成了登录", "[[NAME_1]]完成了登录"},
+		{"英文成员名按整词不区分大小写", "alice and Alicia", "[[NAME_1]] and Alicia"},
+		{"自定义规则只替换 value 分组", "修复 ticket PROJ-123", "修复 ticket [[JIRA_KEY_1]]"},
+		{"没有敏感信息", "重构报告渲染", "重构报告渲染"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, session := redactor.Redact(tt.input)
+			if got != tt.want {
+				t.Errorf("Redact(%q) = %q, 期望 %q", tt.input, got, tt.want)
+			}
+			if restored := session.Restore(got); restored != tt.input {
+				t.Errorf("Restore = %q, 期望 %q", restored, tt.input)
+			}
+		})
+	}
+}
+
+func TestRedactRoundTrip(t *testing.T) {
+	redactor := newTestRedactor(t, RedactionSettings{}, "张三")
+	original := "张三 在 10.0.0.12 上修复了登录问题，联系 zhangsan@example.com，抄送 zhangsan@example.com"
+	secrets := []string{"张三", "10.0.0.12", "zhangsan@example.com"}
+
+	content, session := redactor.Redact(original)
+	system := redactor.RedactWith(session, "你是张三的助手")
+
+	// FakeProvider 原样返回用户消息，模拟模型保留占位符
+	provider := NewFakeProvider("")
+	reply, err := provider.Complete(context.Background(), &CompletionRequest{Messages: []ChatMessage{
+		{Role: "system", Content: system},
+		{Role: "user", Content: content},
+	}})
+	if err != nil {
+		t.Fatalf("Complete 返回错误: %v", err)
+	}
+
+	for _, req := range provider.Requests() {
+		for _, message := range req.Messages {
+			for _, secret := range secrets {
+				if strings.Contains(message.Content, secret) {
+					t.Errorf("%s 消息中包含原始值 %q: %q", message.Role, secret, message.Content)
+				}
+			}
+		}
+	}
+	if system != "你是[[NAME_1]]的助手" {
+		t.Errorf("系统提示词 = %q, 期望与内容共用占位符", system)
+	}
+
+	if restored := session.Restore(reply); restored != original {
+		t.Errorf("Restore = %q, 期望 %q", restored, original)
+	}
+
+	counts := map[string]int{}
+	for _, entry := range session.Audit() {
+		if !entry.Restored {
+			t.Errorf("%s 未标记为已还原", entry.Placeholder)
+		}
+		if strings.Contains(entry.Preview, "zhangsan@example.com") {
+			t.Errorf("审计记录包含原始值: %+v", entry)
+		}
+		counts[entry.Placeholder] = entry.Count
+	}
+	want := map[string]int{"[[EMAIL_1]]": 2, "[[IP_1]]": 1, "[[NAME_1]]": 2}
+	for placeholder, count := range want {
+		if counts[placeholder] != count {
+			t.Errorf("%s 出现次数 = %d, 期望 %d", placeholder, counts[placeholder], count)
+		}
+	}
+}
+
+func TestRestoreTolerantPlaceholders(t *testing.T) {
+	redactor := newTestRedactor(t, RedactionSettings{})
+	_, session := redactor.Redact("联系 zhangsan@example.com")
+
+	tests := []struct {
+		name  string
+		reply string
+		want  string
+	}{
+		{"括号内有空白", "请联系 [[ EMAIL_1 ]]", "请联系 zhangsan@example.com"},
+		{"未知占位符保持不变", "[[EMAIL_2]] 和 [[IP_1]]", "[[EMAIL_2]] 和 [[IP_1]]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := session.Restore(tt.reply); got != tt.want {
+				t.Errorf("Restore = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamRestorer(t *testing.T) {
+	redactor := newTestRedactor(t, RedactionSettings{}, "张三")
+	_, session := redactor.Redact("张三 zhangsan@example.com")
+
+	tests := []struct {
+		name   string
+		deltas []string
+		want   string
+	}{
+		{"占位符被拆分", []string{"由 [[NA", "ME_1]] 负责，联系 [", "[EMAIL_1", "]]"}, "由 张三 负责，联系 zhangsan@example.com"},
+		{"普通方括号", []string{"列表 [1, 2", "] 完成"}, "列表 [1, 2] 完成"},
+		{"结尾的未完成内容", []string{"结尾 [[NAME"}, "结尾 [[NAME"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restorer := session.NewRestorer()
+			var out strings.Builder
+			for _, delta := range tt.deltas {
+				out.WriteString(restorer.Write(delta))
+			}
+			out.WriteString(restorer.Flush())
+			if out.String() != tt.want {
+				t.Errorf("输出 = %q, 期望 %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactDisabled(t *testing.T) {
+	redactor, err := NewRedactor(RedactionSettings{}, []string{"张三"})
+	if err != nil {
+		t.Fatalf("NewRedactor 返回错误: %v", err)
+	}
+	input := "张三 zhangsan@example.com 10.0.0.12"
+	if got, session := redactor.Redact(input); got != input || len(session.Audit()) != 0 {
+		t.Errorf("Redact = %q, 期望原样返回", got)
+	}
+	if extended, err := redactor.WithTerms("李四"); err != nil || extended != redactor {
+		t.Errorf("WithTerms = %p, %v, 期望返回自身", extended, err)
+	}
+}
+
+func TestRedactorWithTerms(t *testing.T) {
+	redactor := newTestRedactor(t, RedactionSettings{Detectors: []string{DetectorTerm}}, "张三")
+
+	if same, err := redactor.WithTerms("张三", " ", ""); err != nil || same != redactor {
+		t.Errorf("没有新词语时 WithTerms = %p, %v, 期望返回自身", same, err)
+	}
+
+	extended, err := redactor.WithTerms("Wang Wu")
+	if err != nil {
+		t.Fatalf("WithTerms 返回错误: %v", err)
+	}
+	got, _ := extended.Redact("张三 和 wang wu")
+	if got != "[[NAME_1]] 和 [[NAME_2]]" {
+		t.Errorf("Redact = %q", got)
+	}
+	if got, _ := redactor.Redact("wang wu"); got != "wang wu" {
+		t.Errorf("原脱敏器被修改: %q", got)
+	}
+}
+
+func TestConfigRedactorMasksDefaultAuthor(t *testing.T) {
+	config := DefaultConfig()
+	config.DefaultAuthor = "Zhang San"
+	redactor, err := config.Redactor()
+	if err != nil {
+		t.Fatalf("Redactor 返回错误: %v", err)
+	}
+	if got, _ := redactor.Redact("Zhang San 的周报"); got != "[[NAME_1]] 的周报" {
+		t.Errorf("Redact = %q, 期望替换默认作者", got)
+	}
+}
+
+func TestNewRedactorErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []RedactionPattern
+	}{
+		{"缺少名称", []RedactionPattern{{Pattern: `x`}}},
+		{"正则无效", []RedactionPattern{{Name: "bad", Pattern: `(`}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRedactor(RedactionSettings{Enabled: true, Patterns: tt.patterns}, nil); err == nil {
+				t.Error("期望返回错误")
+			}
+		})
+	}
+}
@@ -3,57 +3,91 @@ package main
 import (
 	"bytes"
 	"fmt"
-	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
 )
 
+// 报告输出格式
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatJSON     = "json"
+	FormatYAML     = "yaml"
+)
+
 // ReportRenderer 报告渲染器
 type ReportRenderer struct {
 	templateFile string
+	config       *Config
 }
 
-// NewReportRenderer 创建报告渲染器
-func NewReportRenderer(templateFile string) *ReportRenderer {
+// NewReportRenderer 创建报告渲染器，未指定模板时使用配置中的 default_template
+func NewReportRenderer(templateFile string, config *Config) *ReportRenderer {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if templateFile == "" {
+		templateFile = config.DefaultTemplate
+	}
 	return &ReportRenderer{
 		templateFile: templateFile,
+		config:       config,
+	}
+}
+
+// RenderFormat 按指定格式渲染报告，format 为空时输出 Markdown
+func (rr *ReportRenderer) RenderFormat(report *Report, format string) (string, error) {
+	switch format {
+	case "", FormatMarkdown:
+		return rr.Render(report)
+	case FormatHTML:
+		return rr.RenderHTML(report)
+	case FormatJSON, FormatYAML:
+		data, err := MarshalReport(report, format)
+		return string(data), err
+	default:
+		return "", fmt.Errorf("不支持的输出格式: %s，可选 %s、%s、%s、%s", format, FormatMarkdown, FormatHTML, FormatJSON, FormatYAML)
 	}
 }
 
 // Render 渲染报告
 func (rr *ReportRenderer) Render(report *Report) (string, error) {
-	var templateContent string
-	var err error
+	templateContent, err := loadTemplate(rr.templateFile)
+	if err != nil {
+		return "", err
+	}
 	
-	if rr.templateFile != "" {
-		// 使用自定义模板
-		content, err := os.ReadFile(rr.templateFile)
-		if err != nil {
-			return "", fmt.Errorf("读取模板文件失败: %v", err)
-		}
-		templateContent = string(content)
-	} else {
-		// 使用默认模板
-		if report.Type == "daily" {
-			templateContent = rr.getDefaultDailyTemplate()
-		} else {
-			templateContent = rr.getDefaultWeeklyTemplate()
-		}
+	// 解析模板
+	tmpl, err := template.New("report").Funcs(rr.funcs()).Parse(templateContent)
+	if err != nil {
+		return "", fmt.Errorf("解析模板失败: %v", err)
+	}
+	
+	// 渲染模板
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, report)
+	if err != nil {
+		return "", fmt.Errorf("执行模板失败: %v", err)
 	}
 	
-	// 创建模板函数
-	funcMap := template.FuncMap{
+	return buf.String(), nil
+}
+
+// funcs 返回文本和 HTML 模板共用的模板函数
+func (rr *ReportRenderer) funcs() map[string]interface{} {
+	return map[string]interface{}{
 		"formatTime": func(t time.Time) string {
-			return t.Format("2006-01-02 15:04:05")
+			return t.Format(rr.config.TimeFormat)
 		},
 		"formatDate": func(t time.Time) string {
-			return t.Format("2006年01月02日")
+			return t.Format(rr.config.DateFormat)
 		},
 		"formatShortHash": func(hash string) string {
-			if len(hash) > 8 {
-				return hash[:8]
+			if n := rr.config.ReportSettings.ShortHashLength; len(hash) > n {
+				return hash[:n]
 			}
 			return hash
 		},
@@ -90,36 +124,81 @@ func (rr *ReportRenderer) Render(report *Report) (string, error) {
 		"sub": func(a, b int) int {
 			return a - b
 		},
+		"trailer": func(commit *GitCommit, key string) string {
+			return strings.Join(commit.TrailerValues(key), ", ")
+		},
+		"narrative":  BuildNarrative,
+		"typeName":   reportTypeName,
+		"periodName": reportPeriodName,
+		"formatPercent": func(f float64) string {
+			return fmt.Sprintf("%.0f%%", f)
+		},
+		"formatFloat": func(f float64) string {
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		},
+		"commitsOfType":     commitsOfType,
+		"commitsExceptType": commitsExceptType,
 	}
-	
-	// 解析模板
-	tmpl, err := template.New("report").Funcs(funcMap).Parse(templateContent)
-	if err != nil {
-		return "", fmt.Errorf("解析模板失败: %v", err)
+}
+
+// reportTypeName 返回报告类型的中文名称
+func reportTypeName(reportType string) string {
+	switch reportType {
+	case ReportTypeDaily:
+		return "日报"
+	case ReportTypeWeekly:
+		return "周报"
+	case ReportTypeMonthly:
+		return "月报"
+	case ReportTypeQuarterly:
+		return "季报"
+	case ReportTypeYearly:
+		return "年报"
+	default:
+		return "报告"
 	}
-	
-	// 渲染模板
-	var buf bytes.Buffer
-	err = tmpl.Execute(&buf, report)
-	if err != nil {
-		return "", fmt.Errorf("执行模板失败: %v", err)
+}
+
+// reportPeriodName 返回报告周期的中文称呼，如"本周"
+func reportPeriodName(reportType string) string {
+	switch reportType {
+	case ReportTypeDaily:
+		return "今日"
+	case ReportTypeWeekly:
+		return "本周"
+	case ReportTypeMonthly:
+		return "本月"
+	case ReportTypeQuarterly:
+		return "本季度"
+	case ReportTypeYearly:
+		return "本年"
+	default:
+		return "本期"
 	}
-	
-	return buf.String(), nil
 }
 
-// getDefaultDailyTemplate 获取默认日报模板
-func (rr *ReportRenderer) getDefaultDailyTemplate() string {
-	return `{{range $index, $commit := .Commits}}
-# {{add $index 1}}. {{$commit.Message}}
-{{end}}
-`
+// commitsOfType 返回 Conventional Commits 类型为 commitType 的提交
+func commitsOfType(commits []*GitCommit, commitType string) []*GitCommit {
+	var result []*GitCommit
+	for _, commit := range commits {
+		if commit.Type == commitType {
+			result = append(result, commit)
+		}
+	}
+	return result
 }
 
-// getDefaultWeeklyTemplate 获取默认周报模板
-func (rr *ReportRenderer) getDefaultWeeklyTemplate() string {
-	return `{{range $index, $commit := .Commits}}
-# {{add $index 1}}. {{$commit.Message}}
-{{end}}
-`
-}
\ No newline at end of file
+// commitsExceptType 返回类型不在 types 中的提交，包括不符合 Conventional Commits 规范的提交
+func commitsExceptType(commits []*GitCommit, types ...string) []*GitCommit {
+	var result []*GitCommit
+	excluded := make(map[string]bool, len(types))
+	for _, t := range types {
+		excluded[t] = true
+	}
+	for _, commit := range commits {
+		if !excluded[commit.Type] {
+			result = append(result, commit)
+		}
+	}
+	return result
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -9,14 +10,25 @@ import (
 
 // Report 报告结构
 type Report struct {
-	Type        string            // daily, weekly
+	Type        string            // daily, weekly, monthly, quarterly, yearly, range
 	Date        time.Time         // 报告日期
+	Since       time.Time         // 统计开始时间
+	Until       time.Time         // 统计结束时间
 	Period      string            // 时间范围描述
 	Author      string            // 作者
 	RepoInfo    map[string]string // 仓库信息
+	Repos       []*RepoSummary    // 各仓库小计（多仓库时）
 	Commits     []*GitCommit      // 提交记录
 	Summary     *ReportSummary    // 统计摘要
 	Categories  map[string][]*GitCommit // 按类别分组的提交
+	Scopes      map[string][]*GitCommit // 按 Conventional Commits scope 分组的提交
+	Breaking    []*GitCommit      // 包含破坏性变更的提交
+	Team        bool              // 是否为团队报告
+	Members     []*MemberReport   // 团队成员分组（团队报告）
+	Rankings    []*TeamRanking    // 团队排行榜（团队报告）
+	OKR         *OKRProgress      // OKR进度（指定OKR文件时）
+	Narrative   string            // 叙述性总结，语言由 narrative_language 配置
+	Settings    ReportSettings    // 报告设置，供模板判断是否展示各部分
 	GeneratedAt time.Time         // 生成时间
 }
 
@@ -27,30 +39,102 @@ type ReportSummary struct {
 	TotalAdditions int               // 总新增行数
 	TotalDeletions int               // 总删除行数
 	FileTypes      map[string]int    // 文件类型统计
-	DailyStats     map[string]int    // 每日统计（仅周报）
+	DailyStats     map[string]int    // 每日统计（周期不超过一个月时）
+	WeeklyStats    map[string]int    // 每周统计，键为ISO周如 2024-W03（周期超过一周时）
+	MonthlyStats   map[string]int    // 每月统计，键如 2024-01（周期超过一个月时）
 	TopFiles       []string          // 修改最多的文件
+	TopFileCounts  []FileCount       // 修改最多的文件及修改次数，与 TopFiles 一一对应
+}
+
+// FileCount 文件及其被修改的次数
+type FileCount struct {
+	Path  string
+	Count int
 }
 
 // ReportGenerator 报告生成器
 type ReportGenerator struct {
-	gitParser *GitParser
-	author    string
+	gitParsers []*GitParser
+	author     string
+	okrSet     *OKRSet
+	config     *Config
+	roster     *Roster
+	team       bool
+}
+
+// NewReportGenerator 创建报告生成器，config 为 nil 时使用默认配置
+func NewReportGenerator(repoPath, author string, config *Config) *ReportGenerator {
+	return NewMultiRepoReportGenerator([]string{repoPath}, author, config)
 }
 
-// NewReportGenerator 创建报告生成器
-func NewReportGenerator(repoPath, author string) *ReportGenerator {
-	gitParser := NewGitParser(repoPath)
+// NewMultiRepoReportGenerator 创建汇总多个仓库的报告生成器
+func NewMultiRepoReportGenerator(repoPaths []string, author string, config *Config) *ReportGenerator {
+	var gitParsers []*GitParser
+	for _, repoPath := range repoPaths {
+		gitParsers = append(gitParsers, NewGitParser(repoPath))
+	}
+	
+	if config == nil {
+		config = DefaultConfig()
+	}
 	
-	// 如果没有指定作者，尝试获取当前Git用户
+	// 如果没有指定作者，依次使用配置中的默认作者和当前Git用户
 	if author == "" {
-		if currentUser, err := gitParser.GetCurrentUser(); err == nil {
+		author = config.DefaultAuthor
+	}
+	if author == "" && len(gitParsers) > 0 {
+		if currentUser, err := gitParsers[0].GetCurrentUser(); err == nil {
 			author = currentUser
 		}
 	}
 	
 	return &ReportGenerator{
-		gitParser: gitParser,
-		author:    author,
+		gitParsers: gitParsers,
+		author:     author,
+		config:     config,
+	}
+}
+
+// SetOKRSet 设置OKR定义，生成报告时将提交归属到各KR
+func (rg *ReportGenerator) SetOKRSet(okrSet *OKRSet) {
+	rg.okrSet = okrSet
+}
+
+// 支持的报告类型
+const (
+	ReportTypeDaily     = "daily"
+	ReportTypeWeekly    = "weekly"
+	ReportTypeMonthly   = "monthly"
+	ReportTypeQuarterly = "quarterly"
+	ReportTypeYearly    = "yearly"
+	ReportTypeRange     = "range"
+)
+
+// SetRoster 设置团队花名册，用于把多个Git用户名/邮箱归并为同一成员
+func (rg *ReportGenerator) SetRoster(roster *Roster) {
+	rg.roster = roster
+}
+
+// SetTeamMode 开启团队模式，报告包含所有作者并按成员分组统计
+func (rg *ReportGenerator) SetTeamMode(team bool) {
+	rg.team = team
+}
+
+// GenerateReport 按报告类型生成 date 所在周期的报告（range 类型请使用 GenerateRangeReport）
+func (rg *ReportGenerator) GenerateReport(reportType string, date time.Time) (*Report, error) {
+	switch reportType {
+	case ReportTypeDaily:
+		return rg.GenerateDailyReport(date)
+	case ReportTypeWeekly:
+		return rg.GenerateWeeklyReport(date)
+	case ReportTypeMonthly:
+		return rg.GenerateMonthlyReport(date)
+	case ReportTypeQuarterly:
+		return rg.GenerateQuarterlyReport(date)
+	case ReportTypeYearly:
+		return rg.GenerateYearlyReport(date)
+	default:
+		return nil, fmt.Errorf("不支持的报告类型: %s", reportType)
 	}
 }
 
@@ -60,26 +144,7 @@ func (rg *ReportGenerator) GenerateDailyReport(date time.Time) (*Report, error)
 	startOfDay := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
 	endOfDay := startOfDay.Add(24 * time.Hour).Add(-time.Second)
 	
-	commits, err := rg.gitParser.GetCommits(startOfDay, endOfDay, rg.author)
-	if err != nil {
-		return nil, err
-	}
-	
-	repoInfo, _ := rg.gitParser.GetRepoInfo()
-	
-	report := &Report{
-		Type:        "daily",
-		Date:        date,
-		Period:      date.Format("2006年01月02日"),
-		Author:      rg.author,
-		RepoInfo:    repoInfo,
-		Commits:     commits,
-		Summary:     rg.generateSummary(commits, false),
-		Categories:  rg.categorizeCommits(commits),
-		GeneratedAt: time.Now(),
-	}
-	
-	return report, nil
+	return rg.generateReport(ReportTypeDaily, date, startOfDay, endOfDay, date.Format(rg.config.DateFormat))
 }
 
 // GenerateWeeklyReport 生成周报
@@ -93,35 +158,130 @@ func (rg *ReportGenerator) GenerateWeeklyReport(date time.Time) (*Report, error)
 	startOfWeek = time.Date(startOfWeek.Year(), startOfWeek.Month(), startOfWeek.Day(), 0, 0, 0, 0, startOfWeek.Location())
 	endOfWeek := startOfWeek.AddDate(0, 0, 7).Add(-time.Second)
 	
-	commits, err := rg.gitParser.GetCommits(startOfWeek, endOfWeek, rg.author)
+	return rg.generateReport(ReportTypeWeekly, date, startOfWeek, endOfWeek, rg.formatRange(startOfWeek, endOfWeek))
+}
+
+// GenerateMonthlyReport 生成月报
+func (rg *ReportGenerator) GenerateMonthlyReport(date time.Time) (*Report, error) {
+	startOfMonth := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+	endOfMonth := startOfMonth.AddDate(0, 1, 0).Add(-time.Second)
+	
+	period := fmt.Sprintf("%d年%02d月（%s）", date.Year(), date.Month(), rg.formatRange(startOfMonth, endOfMonth))
+	return rg.generateReport(ReportTypeMonthly, date, startOfMonth, endOfMonth, period)
+}
+
+// GenerateQuarterlyReport 生成季报
+func (rg *ReportGenerator) GenerateQuarterlyReport(date time.Time) (*Report, error) {
+	quarter := (int(date.Month())-1)/3 + 1
+	startOfQuarter := time.Date(date.Year(), time.Month((quarter-1)*3+1), 1, 0, 0, 0, 0, date.Location())
+	endOfQuarter := startOfQuarter.AddDate(0, 3, 0).Add(-time.Second)
+	
+	period := fmt.Sprintf("%d年第%d季度（%s）", date.Year(), quarter, rg.formatRange(startOfQuarter, endOfQuarter))
+	return rg.generateReport(ReportTypeQuarterly, date, startOfQuarter, endOfQuarter, period)
+}
+
+// GenerateYearlyReport 生成年报
+func (rg *ReportGenerator) GenerateYearlyReport(date time.Time) (*Report, error) {
+	startOfYear := time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, date.Location())
+	endOfYear := startOfYear.AddDate(1, 0, 0).Add(-time.Second)
+	
+	period := fmt.Sprintf("%d年（%s）", date.Year(), rg.formatRange(startOfYear, endOfYear))
+	return rg.generateReport(ReportTypeYearly, date, startOfYear, endOfYear, period)
+}
+
+// GenerateRangeReport 生成任意日期范围的报告，since 和 until 均包含当天
+func (rg *ReportGenerator) GenerateRangeReport(since, until time.Time) (*Report, error) {
+	start := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, since.Location())
+	end := time.Date(until.Year(), until.Month(), until.Day(), 0, 0, 0, 0, until.Location()).AddDate(0, 0, 1).Add(-time.Second)
+	if end.Before(start) {
+		return nil, fmt.Errorf("结束日期 %s 早于开始日期 %s", until.Format("2006-01-02"), since.Format("2006-01-02"))
+	}
+	
+	return rg.generateReport(ReportTypeRange, until, start, end, rg.formatRange(start, end))
+}
+
+// generateReport 获取 [since, until] 内的提交并生成报告
+func (rg *ReportGenerator) generateReport(reportType string, date, since, until time.Time, period string) (*Report, error) {
+	commits, repos, err := rg.collectCommits(since, until)
 	if err != nil {
 		return nil, err
 	}
 	
-	repoInfo, _ := rg.gitParser.GetRepoInfo()
-	
 	report := &Report{
-		Type:     "weekly",
-		Date:     date,
-		Period:   fmt.Sprintf("%s 至 %s", startOfWeek.Format("2006年01月02日"), endOfWeek.Format("2006年01月02日")),
-		Author:   rg.author,
-		RepoInfo: repoInfo,
-		Commits:  commits,
-		Summary:  rg.generateSummary(commits, true),
-		Categories: rg.categorizeCommits(commits),
+		Type:        reportType,
+		Date:        date,
+		Since:       since,
+		Until:       until,
+		Period:      period,
+		Author:      rg.author,
+		RepoInfo:    combineRepoInfo(repos),
+		Repos:       repos,
+		Commits:     commits,
+		Summary:     rg.generateSummary(commits, reportType, since, until),
+		Categories:  rg.categorizeCommits(commits),
+		Scopes:      groupByScope(commits),
+		Breaking:    collectBreakingChanges(commits),
+		Settings:    rg.config.ReportSettings,
 		GeneratedAt: time.Now(),
 	}
 	
+	if rg.team {
+		report.Team = true
+		report.Author = "团队"
+		report.Members = rg.buildMemberReports(commits, reportType, report)
+		report.Rankings = buildTeamRankings(report.Members)
+	}
+	
+	if rg.okrSet != nil {
+		report.OKR = rg.okrSet.Evaluate(commits)
+	}
+	
+	report.Narrative = BuildNarrative(report, rg.config.NarrativeLanguage)
+	
 	return report, nil
 }
 
-// generateSummary 生成统计摘要
-func (rg *ReportGenerator) generateSummary(commits []*GitCommit, isWeekly bool) *ReportSummary {
+// combineRepoInfo 生成报告的仓库信息，多仓库时名称以逗号连接
+func combineRepoInfo(repos []*RepoSummary) map[string]string {
+	info := make(map[string]string)
+	if len(repos) == 1 {
+		info["name"] = repos[0].Name
+		info["branch"] = repos[0].Branch
+		if repos[0].URL != "" {
+			info["url"] = repos[0].URL
+		}
+		return info
+	}
+	
+	var names []string
+	for _, repo := range repos {
+		names = append(names, repo.Name)
+	}
+	info["name"] = strings.Join(names, ", ")
+	return info
+}
+
+// formatRange 格式化时间范围描述
+func (rg *ReportGenerator) formatRange(since, until time.Time) string {
+	return fmt.Sprintf("%s 至 %s", since.Format(rg.config.DateFormat), until.Format(rg.config.DateFormat))
+}
+
+// generateSummary 生成统计摘要，并根据周期长度生成按日、按周、按月的分布
+func (rg *ReportGenerator) generateSummary(commits []*GitCommit, reportType string, since, until time.Time) *ReportSummary {
 	summary := &ReportSummary{
 		FileTypes: make(map[string]int),
 		DailyStats: make(map[string]int),
+		WeeklyStats: make(map[string]int),
+		MonthlyStats: make(map[string]int),
 	}
 	
+	// 一个月以内按日统计，超过一周按周统计，超过一个月按月统计
+	days := int(until.Sub(since).Hours()/24) + 1
+	includeDistribution := rg.config.ReportSettings.IncludeDailyDistribution
+	withDaily := includeDistribution && reportType != ReportTypeDaily && days <= 31
+	withWeekly := includeDistribution && days > 7
+	withMonthly := includeDistribution && days > 31
+	
 	fileCount := make(map[string]int)
 	
 	for _, commit := range commits {
@@ -129,15 +289,25 @@ func (rg *ReportGenerator) generateSummary(commits []*GitCommit, isWeekly bool)
 		summary.TotalAdditions += commit.Additions
 		summary.TotalDeletions += commit.Deletions
 		
-		// 统计每日提交数（仅周报）
-		if isWeekly {
-			dayKey := commit.Date.Format("01-02")
-			summary.DailyStats[dayKey]++
+		// 统计提交分布
+		if withDaily {
+			summary.DailyStats[commit.Date.Format("01-02")]++
+		}
+		if withWeekly {
+			year, week := commit.Date.ISOWeek()
+			summary.WeeklyStats[fmt.Sprintf("%d-W%02d", year, week)]++
+		}
+		if withMonthly {
+			summary.MonthlyStats[commit.Date.Format("2006-01")]++
 		}
 		
-		// 统计文件
+		// 统计文件，多仓库时以仓库名区分同名文件
 		for _, file := range commit.Files {
-			fileCount[file]++
+			if len(rg.gitParsers) > 1 && commit.Repo != "" {
+				fileCount[commit.Repo+"/"+file]++
+			} else {
+				fileCount[file]++
+			}
 			
 			// 统计文件类型
 			ext := rg.getFileExtension(file)
@@ -147,7 +317,7 @@ func (rg *ReportGenerator) generateSummary(commits []*GitCommit, isWeekly bool)
 	
 	summary.TotalFiles = len(fileCount)
 	
-	// 获取修改最多的文件（前5个）
+	// 获取修改最多的文件（数量由 max_top_files 配置）
 	type fileFreq struct {
 		file  string
 		count int
@@ -163,69 +333,57 @@ func (rg *ReportGenerator) generateSummary(commits []*GitCommit, isWeekly bool)
 	})
 	
 	for i, ff := range fileFreqs {
-		if i >= 5 {
+		if i >= rg.config.ReportSettings.MaxTopFiles {
 			break
 		}
 		summary.TopFiles = append(summary.TopFiles, fmt.Sprintf("%s (%d次)", ff.file, ff.count))
+		summary.TopFileCounts = append(summary.TopFileCounts, FileCount{Path: ff.file, Count: ff.count})
 	}
 	
 	return summary
 }
 
-// categorizeCommits 按类别分组提交
+// categorizeCommits 按类别分组提交，命中多个分类的提交会出现在每个分类中
 func (rg *ReportGenerator) categorizeCommits(commits []*GitCommit) map[string][]*GitCommit {
 	categories := make(map[string][]*GitCommit)
 	
 	for _, commit := range commits {
-		category := rg.categorizeCommit(commit)
-		categories[category] = append(categories[category], commit)
+		for _, category := range rg.categorizeCommit(commit) {
+			categories[category] = append(categories[category], commit)
+		}
 	}
 	
 	return categories
 }
 
-// categorizeCommit 根据提交信息分类
-func (rg *ReportGenerator) categorizeCommit(commit *GitCommit) string {
-	message := strings.ToLower(commit.Message)
-	
-	// 功能开发
-	if strings.Contains(message, "feat") || strings.Contains(message, "feature") || 
-	   strings.Contains(message, "add") || strings.Contains(message, "新增") ||
-	   strings.Contains(message, "功能") {
-		return "功能开发"
-	}
-	
-	// Bug修复
-	if strings.Contains(message, "fix") || strings.Contains(message, "bug") ||
-	   strings.Contains(message, "修复") || strings.Contains(message, "修正") {
-		return "Bug修复"
-	}
-	
-	// 重构
-	if strings.Contains(message, "refactor") || strings.Contains(message, "重构") {
-		return "代码重构"
-	}
-	
-	// 文档
-	if strings.Contains(message, "doc") || strings.Contains(message, "readme") ||
-	   strings.Contains(message, "文档") {
-		return "文档更新"
-	}
-	
-	// 测试
-	if strings.Contains(message, "test") || strings.Contains(message, "测试") {
-		return "测试相关"
+// categorizeCommit 按配置的分类规则引擎对提交分类
+// 优先使用 "Category: xxx" trailer，其次是配置中的 rules，
+// 最后按 Conventional Commits 类型映射或标题中的关键词匹配
+func (rg *ReportGenerator) categorizeCommit(commit *GitCommit) []string {
+	return rg.config.RuleEngine().Categorize(commit)
+}
+
+// keywordRegexp 编译小写的关键词，被匹配的提交信息也需转换为小写
+// 英文关键词按整词匹配（允许 s/es/ed/d/ing 词尾），避免 "address" 命中 add、"prefix" 命中 fix；
+// 中文等其他关键词按子串匹配
+func keywordRegexp(keyword string) *regexp.Regexp {
+	if !isASCIIWord(keyword) {
+		return regexp.MustCompile(regexp.QuoteMeta(keyword))
 	}
-	
-	// 配置
-	if strings.Contains(message, "config") || strings.Contains(message, "配置") {
-		return "配置修改"
+	return regexp.MustCompile(`(^|[^a-z0-9])` + regexp.QuoteMeta(keyword) + `(s|es|ed|d|ing)?($|[^a-z0-9])`)
+}
+
+// isASCIIWord 判断关键词是否只包含英文字母、数字和连字符
+func isASCIIWord(s string) bool {
+	for _, r := range s {
+		if !(r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
 	}
-	
-	return "其他"
+	return true
 }
 
-// getFileExtension 获取文件扩展名
+// getFileExtension 获取文件扩展名，并按 file_type_mapping 合并为文件类型
 func (rg *ReportGenerator) getFileExtension(filename string) string {
 	parts := strings.Split(filename, ".")
 	if len(parts) < 2 {
@@ -233,27 +391,5 @@ func (rg *ReportGenerator) getFileExtension(filename string) string {
 	}
 	ext := strings.ToLower(parts[len(parts)-1])
 	
-	// 合并一些常见的扩展名
-	switch ext {
-	case "js", "jsx", "ts", "tsx":
-		return "JavaScript/TypeScript"
-	case "go":
-		return "Go"
-	case "py":
-		return "Python"
-	case "java":
-		return "Java"
-	case "cpp", "cc", "cxx", "c":
-		return "C/C++"
-	case "html", "htm":
-		return "HTML"
-	case "css", "scss", "sass":
-		return "CSS"
-	case "md", "markdown":
-		return "Markdown"
-	case "json", "yaml", "yml", "xml":
-		return "配置文件"
-	default:
-		return ext
-	}
-}
\ No newline at end of file
+	return rg.config.FileTypeName(ext)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RepoSummary 单个仓库的统计小计
+type RepoSummary struct {
+	Name      string // 仓库名称
+	Path      string // 本地路径
+	Branch    string // 当前分支
+	URL       string // 远程地址
+	Commits   int    // 提交数
+	Files     int    // 修改文件数
+	Additions int    // 新增行数
+	Deletions int    // 删除行数
+	Error     string // 获取提交失败时的错误信息
+}
+
+// 扫描工作区时的最大目录深度
+const workspaceScanDepth = 3
+
+// 并发扫描仓库的最大数量
+const maxConcurrentRepos = 8
+
+// DiscoverRepos 展开仓库路径列表：Git仓库直接使用，普通目录视为工作区并扫描其中的Git仓库
+func DiscoverRepos(paths []string) ([]string, error) {
+	var repos []string
+	seen := make(map[string]bool)
+
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("仓库路径不存在: %s", p)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("仓库路径不是目录: %s", p)
+		}
+
+		found := []string{p}
+		if !isGitRepo(p) {
+			found = scanWorkspace(p, workspaceScanDepth)
+			if len(found) == 0 {
+				return nil, fmt.Errorf("目录中没有找到Git仓库: %s", p)
+			}
+		}
+
+		for _, repo := range found {
+			key := repo
+			if abs, err := filepath.Abs(repo); err == nil {
+				key = abs
+			}
+			if !seen[key] {
+				seen[key] = true
+				repos = append(repos, repo)
+			}
+		}
+	}
+
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("没有指定仓库路径")
+	}
+
+	return repos, nil
+}
+
+// isGitRepo 判断目录是否为Git仓库（.git 可以是目录或 worktree/submodule 的文件）
+func isGitRepo(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// scanWorkspace 在工作区目录中查找Git仓库，不进入已找到的仓库内部
+func scanWorkspace(dir string, depth int) []string {
+	if depth <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var repos []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor" {
+			continue
+		}
+
+		sub := filepath.Join(dir, name)
+		if isGitRepo(sub) {
+			repos = append(repos, sub)
+		} else {
+			repos = append(repos, scanWorkspace(sub, depth-1)...)
+		}
+	}
+
+	return repos
+}
+
+// collectCommits 并发获取各仓库的提交，单个仓库失败时记录错误而不中断整体生成
+func (rg *ReportGenerator) collectCommits(since, until time.Time) ([]*GitCommit, []*RepoSummary, error) {
+	type repoResult struct {
+		commits []*GitCommit
+		summary *RepoSummary
+	}
+
+	results := make([]repoResult, len(rg.gitParsers))
+	semaphore := make(chan struct{}, maxConcurrentRepos)
+	var wg sync.WaitGroup
+
+	for i, parser := range rg.gitParsers {
+		wg.Add(1)
+		go func(i int, parser *GitParser) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			info, _ := parser.GetRepoInfo()
+			summary := &RepoSummary{
+				Name:   info["name"],
+				Path:   parser.repoPath,
+				Branch: info["branch"],
+				URL:    info["url"],
+			}
+
+			// 不使用 git log --author，以便共同作者和花名册别名也能匹配
+			commits, err := parser.GetCommits(since, until, "")
+			if err != nil {
+				summary.Error = err.Error()
+				results[i] = repoResult{summary: summary}
+				return
+			}
+			commits = rg.selectCommits(commits)
+
+			files := make(map[string]bool)
+			for _, commit := range commits {
+				commit.Repo = summary.Name
+				summary.Commits++
+				summary.Additions += commit.Additions
+				summary.Deletions += commit.Deletions
+				for _, file := range commit.Files {
+					files[file] = true
+				}
+			}
+			summary.Files = len(files)
+
+			results[i] = repoResult{commits: commits, summary: summary}
+		}(i, parser)
+	}
+	wg.Wait()
+
+	var commits []*GitCommit
+	var repos []*RepoSummary
+	var errs []string
+	for _, result := range results {
+		commits = append(commits, result.commits...)
+		repos = append(repos, result.summary)
+		if result.summary.Error != "" {
+			errs = append(errs, fmt.Sprintf("%s: %s", result.summary.Name, result.summary.Error))
+		}
+	}
+
+	// 所有仓库都失败时才返回错误
+	if len(errs) == len(repos) {
+		return nil, repos, fmt.Errorf("获取提交记录失败: %s", strings.Join(errs, "; "))
+	}
+
+	// 多仓库的提交按时间倒序合并
+	sort.SliceStable(commits, func(i, j int) bool {
+		return commits[i].Date.After(commits[j].Date)
+	})
+
+	return commits, repos, nil
+}
+
+// pathWithin 判断 path 是否为 roots 中的某个目录或位于其中，比较前转换为绝对路径并解析符号链接
+func pathWithin(path string, roots []string) bool {
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return false
+	}
+
+	for _, root := range roots {
+		if strings.TrimSpace(root) == "" {
+			continue
+		}
+		base, err := resolvePath(root)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(base, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePath 转换为绝对路径并解析符号链接，路径不存在时只转换为绝对路径
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real, nil
+	}
+	return abs, nil
+}
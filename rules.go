@@ -0,0 +1,451 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CategorizationRule 配置中的分类规则，规则内的各个条件需同时满足
+type CategorizationRule struct {
+	Name         string   `json:"name"`                   // 规则名称，用于 categorize -explain 输出
+	Categories   []string `json:"categories"`             // 命中后归入的分类，可以有多个
+	Priority     int      `json:"priority"`               // 优先级，数值大的先匹配，相同时按声明顺序
+	Message      string   `json:"message,omitempty"`      // 提交标题和正文需匹配的正则
+	Keywords     []string `json:"keywords,omitempty"`     // 提交标题中包含任一关键词
+	Types        []string `json:"types,omitempty"`        // Conventional Commits 类型之一
+	Conventional *bool    `json:"conventional,omitempty"` // true 只匹配符合规范的提交，false 只匹配不符合规范的提交
+	Files        []string `json:"files,omitempty"`        // 变更文件的通配符，如 **/*_test.go
+	FilesMatch   string   `json:"files_match,omitempty"`  // any: 任一文件命中即可（默认）；all: 所有文件都需命中
+	Authors      []string `json:"authors,omitempty"`      // 作者需匹配的正则（匹配 "Name <email>"）
+	MinChanges   *int     `json:"min_changes,omitempty"`  // 新增加删除行数下限
+	MaxChanges   *int     `json:"max_changes,omitempty"`  // 新增加删除行数上限
+	Stop         bool     `json:"stop,omitempty"`         // 命中后不再匹配后续规则
+}
+
+// RuleEngine 有序的分类规则引擎
+// 先按优先级匹配配置中的规则并收集所有命中的分类，直到遇到 stop 规则；
+// 没有遇到 stop 规则时，再用由 type_categories 和 categories 生成的内置规则补充一个分类；
+// 仍然无法分类，或只是因为类型未映射而归入其他时，使用分类模型的预测结果
+type RuleEngine struct {
+	categories    CategoryRules // 用于解析 "Category: xxx" trailer
+	rules         []*compiledRule
+	builtins      []*compiledRule
+	classifier    *Classifier // 所有规则都未命中时使用的分类模型，可以为空
+	minConfidence float64     // 采用模型预测的最低置信度
+}
+
+// compiledRule 预编译正则后的规则
+type compiledRule struct {
+	*CategorizationRule
+	message  *regexp.Regexp
+	keywords []*regexp.Regexp // 与 Keywords 一一对应，空关键词为 nil
+	authors  []*regexp.Regexp
+}
+
+// RuleMatch 单条规则的命中情况
+type RuleMatch struct {
+	Rule       string   // 规则名称
+	Categories []string // 规则给出的分类
+	Reasons    []string // 各条件的命中说明
+}
+
+// CategoryExplanation 分类结果及其依据
+type CategoryExplanation struct {
+	Categories []string     // 最终分类
+	Source     string       // 分类来源：trailer, rules, builtin, classifier, default
+	Matches    []*RuleMatch // 命中的规则
+}
+
+// NewRuleEngine 根据配置编译分类规则
+func NewRuleEngine(config *Config) (*RuleEngine, error) {
+	engine := &RuleEngine{
+		categories:    config.Categories,
+		classifier:    config.classifier,
+		minConfidence: config.ClassifierMinConfidence,
+	}
+
+	for i, rule := range config.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("分类规则 %s 无效: %v", rule.Name, err)
+		}
+		engine.rules = append(engine.rules, compiled)
+	}
+
+	// 优先级高的规则先匹配，相同优先级保持声明顺序
+	sort.SliceStable(engine.rules, func(i, j int) bool {
+		return engine.rules[i].Priority > engine.rules[j].Priority
+	})
+
+	for _, rule := range builtinRules(config) {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("内置分类规则 %s 无效: %v", rule.Name, err)
+		}
+		engine.builtins = append(engine.builtins, compiled)
+	}
+
+	return engine, nil
+}
+
+// unmappedTypeRule 符合规范但类型未在 type_categories 中映射时命中的内置规则
+const unmappedTypeRule = "type:unmapped"
+
+// builtinRules 把 type_categories 和 categories 转换为按顺序匹配、命中即停止的规则
+func builtinRules(config *Config) []*CategorizationRule {
+	var rules []*CategorizationRule
+	conventional, nonConventional := true, false
+
+	// 按类型名排序，保证规则顺序稳定
+	types := make([]string, 0, len(config.TypeCategories))
+	for commitType := range config.TypeCategories {
+		types = append(types, commitType)
+	}
+	sort.Strings(types)
+
+	for _, commitType := range types {
+		rules = append(rules, &CategorizationRule{
+			Name:       "type:" + commitType,
+			Categories: []string{config.TypeCategories[commitType]},
+			Types:      []string{commitType},
+			Stop:       true,
+		})
+	}
+
+	// 符合规范但类型未映射的提交归为其他，不再按关键词匹配；分类模型能够预测时以模型为准
+	rules = append(rules, &CategorizationRule{
+		Name:         unmappedTypeRule,
+		Categories:   []string{OtherCategory},
+		Conventional: &conventional,
+		Stop:         true,
+	})
+
+	for _, category := range config.Categories {
+		if len(category.Keywords) == 0 {
+			continue
+		}
+		rules = append(rules, &CategorizationRule{
+			Name:         "keywords:" + category.Name,
+			Categories:   []string{category.Name},
+			Keywords:     category.Keywords,
+			Conventional: &nonConventional,
+			Stop:         true,
+		})
+	}
+
+	return rules
+}
+
+// compileRule 校验规则并编译其中的正则
+func compileRule(rule *CategorizationRule) (*compiledRule, error) {
+	if len(rule.Categories) == 0 {
+		return nil, fmt.Errorf("没有指定 categories")
+	}
+	if rule.Message == "" && len(rule.Keywords) == 0 && len(rule.Types) == 0 && rule.Conventional == nil &&
+		len(rule.Files) == 0 && len(rule.Authors) == 0 && rule.MinChanges == nil && rule.MaxChanges == nil {
+		return nil, fmt.Errorf("没有指定任何匹配条件")
+	}
+	switch rule.FilesMatch {
+	case "", "any", "all":
+	default:
+		return nil, fmt.Errorf("files_match 只能是 any 或 all")
+	}
+
+	compiled := &compiledRule{CategorizationRule: rule}
+
+	if rule.Message != "" {
+		re, err := regexp.Compile(rule.Message)
+		if err != nil {
+			return nil, fmt.Errorf("message 正则无效: %v", err)
+		}
+		compiled.message = re
+	}
+
+	for _, keyword := range rule.Keywords {
+		var re *regexp.Regexp
+		if keyword != "" {
+			re = keywordRegexp(strings.ToLower(keyword))
+		}
+		compiled.keywords = append(compiled.keywords, re)
+	}
+
+	for _, author := range rule.Authors {
+		re, err := regexp.Compile(author)
+		if err != nil {
+			return nil, fmt.Errorf("authors 正则无效: %v", err)
+		}
+		compiled.authors = append(compiled.authors, re)
+	}
+
+	for _, pattern := range rule.Files {
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return nil, fmt.Errorf("files 通配符 %s 无效: %v", pattern, err)
+		}
+	}
+
+	return compiled, nil
+}
+
+// Categorize 返回提交的分类
+func (e *RuleEngine) Categorize(commit *GitCommit) []string {
+	return e.Explain(commit).Categories
+}
+
+// Explain 返回提交的分类以及命中的规则
+// 提交中通过 "Category: xxx" trailer 显式声明的分类优先于所有规则
+func (e *RuleEngine) Explain(commit *GitCommit) *CategoryExplanation {
+	for _, value := range commit.TrailerValues("Category") {
+		for _, category := range e.categories {
+			if strings.EqualFold(category.Name, value) {
+				return &CategoryExplanation{
+					Categories: []string{category.Name},
+					Source:     "trailer",
+					Matches: []*RuleMatch{{
+						Rule:       "trailer:Category",
+						Categories: []string{category.Name},
+						Reasons:    []string{fmt.Sprintf("提交声明了 Category: %s", value)},
+					}},
+				}
+			}
+		}
+	}
+
+	explanation := &CategoryExplanation{Source: "rules"}
+	matches, categories, stopped := matchRules(e.rules, commit)
+	explanation.Matches, explanation.Categories = matches, categories
+
+	// 没有遇到 stop 规则时，继续按类型映射或关键词补充分类
+	var unmapped []*RuleMatch
+	if !stopped {
+		// 内置规则命中即停止，最多只有一条命中
+		matches, categories, _ := matchRules(e.builtins, commit)
+		switch {
+		case len(categories) == 0:
+		case categories[0] == OtherCategory && len(explanation.Categories) > 0:
+			// 已有分类时不再归入其他
+		case matches[0].Rule == unmappedTypeRule && e.classifier != nil:
+			// 类型未映射时先交给分类模型，模型无法判断时再归入其他
+			unmapped = matches
+		default:
+			if len(explanation.Matches) == 0 {
+				explanation.Source = "builtin"
+			}
+			explanation.Matches = append(explanation.Matches, matches...)
+			explanation.Categories = appendUnique(explanation.Categories, categories[0])
+		}
+	}
+
+	if len(explanation.Categories) == 0 {
+		if match := e.predict(commit); match != nil {
+			explanation.Source = "classifier"
+			explanation.Matches = append(explanation.Matches, match)
+			explanation.Categories = match.Categories
+		}
+	}
+
+	if len(explanation.Categories) == 0 && unmapped != nil {
+		explanation.Source = "builtin"
+		explanation.Matches = append(explanation.Matches, unmapped...)
+		explanation.Categories = []string{OtherCategory}
+	}
+
+	if len(explanation.Categories) == 0 {
+		explanation.Source = "default"
+		explanation.Categories = []string{OtherCategory}
+	}
+
+	return explanation
+}
+
+// predict 使用分类模型预测分类，置信度不足或预测出未定义的分类时返回 nil
+func (e *RuleEngine) predict(commit *GitCommit) *RuleMatch {
+	if e.classifier == nil {
+		return nil
+	}
+
+	prediction := e.classifier.Predict(commit.Message, commit.Files)
+	if prediction == nil || prediction.Category == OtherCategory || prediction.Confidence < e.minConfidence {
+		return nil
+	}
+
+	for _, category := range e.categories {
+		if category.Name == prediction.Category {
+			reason := fmt.Sprintf("分类模型预测置信度 %.2f", prediction.Confidence)
+			if len(prediction.Features) > 0 {
+				reason += "，主要特征: " + strings.Join(prediction.Features, ", ")
+			}
+			return &RuleMatch{
+				Rule:       "classifier",
+				Categories: []string{category.Name},
+				Reasons:    []string{reason},
+			}
+		}
+	}
+	return nil
+}
+
+// matchRules 依次匹配规则，收集命中的分类，遇到 stop 规则时结束
+func matchRules(rules []*compiledRule, commit *GitCommit) ([]*RuleMatch, []string, bool) {
+	var matches []*RuleMatch
+	var categories []string
+
+	for _, rule := range rules {
+		reasons, ok := rule.match(commit)
+		if !ok {
+			continue
+		}
+
+		matches = append(matches, &RuleMatch{
+			Rule:       rule.Name,
+			Categories: rule.Categories,
+			Reasons:    reasons,
+		})
+		for _, category := range rule.Categories {
+			categories = appendUnique(categories, category)
+		}
+
+		if rule.Stop {
+			return matches, categories, true
+		}
+	}
+
+	return matches, categories, false
+}
+
+// appendUnique 追加不重复的分类
+func appendUnique(categories []string, category string) []string {
+	for _, existing := range categories {
+		if existing == category {
+			return categories
+		}
+	}
+	return append(categories, category)
+}
+
+// match 判断提交是否满足规则的所有条件，返回各条件的命中说明
+func (r *compiledRule) match(commit *GitCommit) ([]string, bool) {
+	var reasons []string
+
+	if r.message != nil {
+		if !r.message.MatchString(commit.FullMessage()) {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("提交信息匹配 /%s/", r.Message))
+	}
+
+	if len(r.Keywords) > 0 {
+		message := strings.ToLower(commit.Message)
+		matched := ""
+		for i, re := range r.keywords {
+			if re != nil && re.MatchString(message) {
+				matched = r.Keywords[i]
+				break
+			}
+		}
+		if matched == "" {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("标题包含关键词 %q", matched))
+	}
+
+	if r.Conventional != nil {
+		if *r.Conventional != (commit.Type != "") {
+			return nil, false
+		}
+		if *r.Conventional {
+			reasons = append(reasons, fmt.Sprintf("符合 Conventional Commits 规范（类型 %s）", commit.Type))
+		} else {
+			reasons = append(reasons, "不符合 Conventional Commits 规范")
+		}
+	}
+
+	if len(r.Types) > 0 {
+		matched := false
+		for _, commitType := range r.Types {
+			if strings.EqualFold(commitType, commit.Type) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("提交类型为 %s", commit.Type))
+	}
+
+	if len(r.Files) > 0 {
+		reason, ok := r.matchFiles(commit.Files)
+		if !ok {
+			return nil, false
+		}
+		reasons = append(reasons, reason)
+	}
+
+	if len(r.authors) > 0 {
+		identity := commit.Author + " <" + commit.Email + ">"
+		matched := false
+		for _, re := range r.authors {
+			if re.MatchString(identity) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("作者 %s 匹配", identity))
+	}
+
+	changes := commit.Additions + commit.Deletions
+	if r.MinChanges != nil {
+		if changes < *r.MinChanges {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("变更 %d 行 >= %d", changes, *r.MinChanges))
+	}
+	if r.MaxChanges != nil {
+		if changes > *r.MaxChanges {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("变更 %d 行 <= %d", changes, *r.MaxChanges))
+	}
+
+	return reasons, true
+}
+
+// matchFiles 按 files_match 匹配变更文件
+func (r *compiledRule) matchFiles(files []string) (string, bool) {
+	matchesAny := func(file string) bool {
+		for _, pattern := range r.Files {
+			if matchPathGlob(pattern, file) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if r.FilesMatch == "all" {
+		if len(files) == 0 {
+			return "", false
+		}
+		for _, file := range files {
+			if !matchesAny(file) {
+				return "", false
+			}
+		}
+		return fmt.Sprintf("所有 %d 个文件都匹配 %s", len(files), strings.Join(r.Files, ", ")), true
+	}
+
+	for _, file := range files {
+		if matchesAny(file) {
+			return fmt.Sprintf("文件 %s 匹配 %s", file, strings.Join(r.Files, ", ")), true
+		}
+	}
+	return "", false
+}
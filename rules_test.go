@@ -0,0 +1,240 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTestRuleEngine 使用默认配置和给定规则创建规则引擎
+func newTestRuleEngine(t *testing.T, classifier *Classifier, rules ...*CategorizationRule) *RuleEngine {
+	t.Helper()
+	config := DefaultConfig()
+	config.Rules = rules
+	config.classifier = classifier
+	engine, err := NewRuleEngine(config)
+	if err != nil {
+		t.Fatalf("NewRuleEngine 返回错误: %v", err)
+	}
+	return engine
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+func TestRuleEngineExplain(t *testing.T) {
+	rules := []*CategorizationRule{
+		{Name: "security", Categories: []string{"安全"}, Message: `(?i)\bCVE-\d+`, Priority: 10, Stop: true},
+		{Name: "tests-only", Categories: []string{"测试相关"}, Files: []string{"**/*_test.go"}, FilesMatch: "all"},
+		{Name: "docs", Categories: []string{"文档更新"}, Files: []string{"docs/**", "*.md"}},
+		{Name: "bot", Categories: []string{"配置修改"}, Authors: []string{`\[bot\]`}, Stop: true},
+		{Name: "large-feature", Categories: []string{"大型变更"}, Types: []string{"feat"}, MinChanges: intPtr(500)},
+		{Name: "tiny-legacy", Categories: []string{"小改动"}, Conventional: boolPtr(false), MaxChanges: intPtr(2), Keywords: []string{"typo"}},
+	}
+	engine := newTestRuleEngine(t, nil, rules...)
+
+	tests := []struct {
+		name       string
+		commit     *GitCommit
+		categories []string
+		source     string
+		rules      []string
+	}{
+		{
+			name:       "Category trailer 优先于所有规则",
+			commit:     &GitCommit{Message: "fix: CVE-2026-1 patch", Type: "fix", Trailers: []Trailer{{Key: "category", Value: "文档更新"}}},
+			categories: []string{"文档更新"},
+			source:     "trailer",
+			rules:      []string{"trailer:Category"},
+		},
+		{
+			name:       "未定义的 trailer 分类被忽略",
+			commit:     &GitCommit{Message: "fix: crash", Type: "fix", Trailers: []Trailer{{Key: "Category", Value: "不存在"}}},
+			categories: []string{"Bug修复"},
+			source:     "builtin",
+			rules:      []string{"type:fix"},
+		},
+		{
+			name:       "高优先级的 stop 规则阻止后续匹配",
+			commit:     &GitCommit{Message: "fix: CVE-2026-1 in docs", Type: "fix", Files: []string{"docs/security.md"}},
+			categories: []string{"安全"},
+			source:     "rules",
+			rules:      []string{"security"},
+		},
+		{
+			name:       "多条规则的分类合并后补充类型分类",
+			commit:     &GitCommit{Message: "test: cover parser", Type: "test", Files: []string{"parser_test.go", "README.md"}},
+			categories: []string{"文档更新", "测试相关"},
+			source:     "rules",
+			rules:      []string{"docs", "type:test"},
+		},
+		{
+			name:       "files_match all 要求所有文件都命中",
+			commit:     &GitCommit{Message: "add cases", Files: []string{"a_test.go", "pkg/b_test.go"}},
+			categories: []string{"测试相关", "功能开发"},
+			source:     "rules",
+			rules:      []string{"tests-only", "keywords:功能开发"},
+		},
+		{
+			name:       "作者规则",
+			commit:     &GitCommit{Message: "bump deps", Author: "renovate[bot]", Email: "bot@example.com"},
+			categories: []string{"配置修改"},
+			source:     "rules",
+			rules:      []string{"bot"},
+		},
+		{
+			name:       "类型与行数下限同时满足",
+			commit:     &GitCommit{Message: "feat: new exporter", Type: "feat", Additions: 450, Deletions: 60},
+			categories: []string{"大型变更", "功能开发"},
+			source:     "rules",
+			rules:      []string{"large-feature", "type:feat"},
+		},
+		{
+			name:       "行数不足时规则不命中",
+			commit:     &GitCommit{Message: "feat: small option", Type: "feat", Additions: 10},
+			categories: []string{"功能开发"},
+			source:     "builtin",
+			rules:      []string{"type:feat"},
+		},
+		{
+			name:       "非规范提交的关键词与行数上限",
+			commit:     &GitCommit{Message: "fix typo", Additions: 1, Deletions: 1},
+			categories: []string{"小改动", "Bug修复"},
+			source:     "rules",
+			rules:      []string{"tiny-legacy", "keywords:Bug修复"},
+		},
+		{
+			name:       "关键词按整词匹配",
+			commit:     &GitCommit{Message: "prefix handling"},
+			categories: []string{OtherCategory},
+			source:     "default",
+		},
+		{
+			name:       "复合词需单独列为关键词",
+			commit:     &GitCommit{Message: "hotfix login crash"},
+			categories: []string{"Bug修复"},
+			source:     "builtin",
+			rules:      []string{"keywords:Bug修复"},
+		},
+		{
+			name:       "规范但未映射的类型归为其他",
+			commit:     &GitCommit{Message: "chore: add tooling", Type: "chore"},
+			categories: []string{OtherCategory},
+			source:     "builtin",
+			rules:      []string{"type:unmapped"},
+		},
+		{
+			name:       "已有分类时不再补充其他",
+			commit:     &GitCommit{Message: "chore: update docs", Type: "chore", Files: []string{"docs/guide.md"}},
+			categories: []string{"文档更新"},
+			source:     "rules",
+			rules:      []string{"docs"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			explanation := engine.Explain(tt.commit)
+			if !reflect.DeepEqual(explanation.Categories, tt.categories) {
+				t.Errorf("Categories = %q, 期望 %q", explanation.Categories, tt.categories)
+			}
+			if explanation.Source != tt.source {
+				t.Errorf("Source = %q, 期望 %q", explanation.Source, tt.source)
+			}
+			var names []string
+			for _, match := range explanation.Matches {
+				names = append(names, match.Rule)
+				if len(match.Reasons) == 0 {
+					t.Errorf("规则 %s 没有命中说明", match.Rule)
+				}
+			}
+			if !reflect.DeepEqual(names, tt.rules) {
+				t.Errorf("命中规则 = %q, 期望 %q", names, tt.rules)
+			}
+		})
+	}
+}
+
+func TestRuleEngineClassifierFallback(t *testing.T) {
+	classifier := NewClassifier()
+	classifier.Train([]*TrainingExample{
+		{Message: "迁移数据库表结构", Files: []string{"migrations/001.sql"}, Category: "配置修改"},
+		{Message: "迁移用户数据", Files: []string{"migrations/002.sql"}, Category: "配置修改"},
+		{Message: "调整首页布局", Files: []string{"web/index.html"}, Category: "功能开发"},
+	})
+
+	t.Run("置信度足够时使用模型预测", func(t *testing.T) {
+		engine := newTestRuleEngine(t, classifier)
+		engine.minConfidence = 0.5
+		explanation := engine.Explain(&GitCommit{Message: "迁移订单表", Files: []string{"migrations/003.sql"}})
+		if explanation.Source != "classifier" || !reflect.DeepEqual(explanation.Categories, []string{"配置修改"}) {
+			t.Errorf("分类 = %q (%s)", explanation.Categories, explanation.Source)
+		}
+	})
+
+	t.Run("置信度不足时归为其他", func(t *testing.T) {
+		engine := newTestRuleEngine(t, classifier)
+		engine.minConfidence = 1.01
+		explanation := engine.Explain(&GitCommit{Message: "迁移订单表", Files: []string{"migrations/003.sql"}})
+		if explanation.Source != "default" || !reflect.DeepEqual(explanation.Categories, []string{OtherCategory}) {
+			t.Errorf("分类 = %q (%s)", explanation.Categories, explanation.Source)
+		}
+	})
+
+	t.Run("类型未映射时使用模型预测", func(t *testing.T) {
+		engine := newTestRuleEngine(t, classifier)
+		engine.minConfidence = 0.5
+		explanation := engine.Explain(&GitCommit{Message: "chore: 迁移订单表", Type: "chore", Files: []string{"migrations/003.sql"}})
+		if explanation.Source != "classifier" || !reflect.DeepEqual(explanation.Categories, []string{"配置修改"}) {
+			t.Errorf("分类 = %q (%s)", explanation.Categories, explanation.Source)
+		}
+	})
+
+	t.Run("类型未映射且置信度不足时归为其他", func(t *testing.T) {
+		engine := newTestRuleEngine(t, classifier)
+		engine.minConfidence = 1.01
+		explanation := engine.Explain(&GitCommit{Message: "chore: 迁移订单表", Type: "chore", Files: []string{"migrations/003.sql"}})
+		if explanation.Source != "builtin" || !reflect.DeepEqual(explanation.Categories, []string{OtherCategory}) {
+			t.Errorf("分类 = %q (%s)", explanation.Categories, explanation.Source)
+		}
+		if len(explanation.Matches) != 1 || explanation.Matches[0].Rule != unmappedTypeRule {
+			t.Errorf("命中规则 = %v, 期望 %s", explanation.Matches, unmappedTypeRule)
+		}
+	})
+
+	t.Run("规则命中时不使用模型", func(t *testing.T) {
+		engine := newTestRuleEngine(t, classifier)
+		engine.minConfidence = 0
+		explanation := engine.Explain(&GitCommit{Message: "fix: 迁移失败", Type: "fix", Files: []string{"migrations/003.sql"}})
+		if explanation.Source != "builtin" || !reflect.DeepEqual(explanation.Categories, []string{"Bug修复"}) {
+			t.Errorf("分类 = %q (%s)", explanation.Categories, explanation.Source)
+		}
+	})
+}
+
+func TestNewRuleEngineErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule *CategorizationRule
+	}{
+		{"没有分类", &CategorizationRule{Keywords: []string{"x"}}},
+		{"没有条件", &CategorizationRule{Categories: []string{"功能开发"}}},
+		{"files_match 无效", &CategorizationRule{Categories: []string{"功能开发"}, Files: []string{"*.go"}, FilesMatch: "some"}},
+		{"message 正则无效", &CategorizationRule{Categories: []string{"功能开发"}, Message: "("}},
+		{"authors 正则无效", &CategorizationRule{Categories: []string{"功能开发"}, Authors: []string{"["}}},
+		{"通配符无效", &CategorizationRule{Categories: []string{"功能开发"}, Files: []string{"[a-"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Rules = []*CategorizationRule{tt.rule}
+			if _, err := NewRuleEngine(config); err == nil {
+				t.Error("期望返回错误")
+			}
+		})
+	}
+}
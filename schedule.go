@@ -0,0 +1,768 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	// 内嵌时区数据，精简的容器镜像中没有 /usr/share/zoneinfo
+	_ "time/tzdata"
+)
+
+// SchedulerSettings 定时生成和推送报告的配置，只在服务器模式下运行
+type SchedulerSettings struct {
+	Enabled   bool        `json:"enabled"`    // 是否按计划自动运行，多实例部署时只在一个实例开启
+	Timezone  string      `json:"timezone"`   // 默认时区，如 Asia/Shanghai，为空时使用服务器本地时区
+	StoreFile string      `json:"store_file"` // 保存接口创建的任务和最近运行记录的文件，为空时只保存在内存中
+	RepoRoots []string    `json:"repo_roots"` // 接口创建的任务可以使用的仓库目录，为空时只允许 default_repo_path
+	Schedules []*Schedule `json:"schedules"`  // 配置文件中定义的任务，接口只能查看和立即运行
+}
+
+// Schedule 定时任务：按 cron 表达式生成报告并推送到指定渠道
+type Schedule struct {
+	ID             string   `json:"id"`                         // 任务标识，只能包含字母、数字、- 和 _
+	Name           string   `json:"name,omitempty"`             // 显示名称
+	Cron           string   `json:"cron"`                       // cron 表达式，如 "30 17 * * FRI"
+	Timezone       string   `json:"timezone,omitempty"`         // 时区，默认使用 scheduler.timezone
+	Type           string   `json:"type"`                       // 报告类型: daily, weekly, monthly, quarterly, yearly
+	DateOffsetDays int      `json:"date_offset_days,omitempty"` // 报告日期相对运行时间的偏移天数，如每天早上发昨天的日报填 -1
+	RepoPaths      []string `json:"repo_paths"`                 // 仓库或工作区目录
+	Authors        []string `json:"authors,omitempty"`          // 作者，每人生成一份报告；为空时使用默认作者
+	Team           bool     `json:"team,omitempty"`             // 团队模式，生成一份包含所有成员的报告
+	RosterFile     string   `json:"roster_file,omitempty"`      // 团队花名册文件，默认使用配置中的 roster_file
+	Template       string   `json:"template,omitempty"`         // 报告模板，默认使用配置中的 default_template
+	Channels       []string `json:"channels"`                   // 推送渠道，见 delivery.channels
+	CatchUp        string   `json:"catch_up,omitempty"`         // 服务停止期间错过的运行: once（默认，补发最近一次）或 skip
+	Disabled       bool     `json:"disabled,omitempty"`         // 暂停自动运行，仍可立即运行
+}
+
+// ScheduleRun 一次运行的记录
+type ScheduleRun struct {
+	Trigger     string             `json:"trigger"`         // schedule、catch_up 或 manual
+	ScheduledAt time.Time          `json:"scheduled_at"`    // 计划运行时间，立即运行时为触发时间
+	StartedAt   time.Time          `json:"started_at"`      // 开始时间
+	FinishedAt  time.Time          `json:"finished_at"`     // 结束时间
+	Status      string             `json:"status"`          // success 或 failed
+	Error       string             `json:"error,omitempty"` // 失败原因
+	Deliveries  []ScheduleDelivery `json:"deliveries"`      // 每份报告在各渠道的推送结果
+}
+
+// ScheduleDelivery 一份报告推送到一个渠道的结果
+type ScheduleDelivery struct {
+	Author string `json:"author,omitempty"`
+	DeliveryResult
+}
+
+// ScheduleStatus 任务及其运行状态
+type ScheduleStatus struct {
+	*Schedule
+	Source  string       `json:"source"`             // config（配置文件）或 api（接口创建）
+	NextRun *time.Time   `json:"next_run,omitempty"` // 下一次自动运行的时间，暂停或未开启调度时为空
+	Running bool         `json:"running"`            // 是否正在运行
+	LastRun *ScheduleRun `json:"last_run,omitempty"` // 最近一次运行
+}
+
+// 任务来源
+const (
+	ScheduleSourceConfig = "config"
+	ScheduleSourceAPI    = "api"
+)
+
+// 错过运行时的处理方式
+const (
+	CatchUpOnce = "once"
+	CatchUpSkip = "skip"
+)
+
+// 运行的触发方式
+const (
+	TriggerSchedule = "schedule"
+	TriggerCatchUp  = "catch_up"
+	TriggerManual   = "manual"
+)
+
+// 定时任务相关的错误
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrScheduleExists   = errors.New("schedule already exists")
+	ErrScheduleReadOnly = errors.New("schedule is defined in the config file and cannot be modified through the API")
+	ErrScheduleRunning  = errors.New("schedule is already running")
+	ErrScheduleInvalid  = errors.New("invalid schedule")
+)
+
+// scheduleRunTimeout 一次运行（生成所有报告并推送）的总时限
+const scheduleRunTimeout = 10 * time.Minute
+
+var scheduleIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Scheduler 定时任务调度器
+// 同一任务同时只会运行一次，计划时间到达时上一次仍未结束则跳过本次
+type Scheduler struct {
+	config   *Config
+	location *time.Location
+
+	mu      sync.Mutex
+	entries map[string]*scheduleEntry
+	wake    chan struct{}
+	started bool
+}
+
+// scheduleEntry 调度器中的一个任务
+type scheduleEntry struct {
+	schedule *Schedule
+	source   string
+	cron     *CronSchedule
+	location *time.Location
+	next     time.Time
+	running  bool
+	lastRun  *ScheduleRun
+}
+
+// scheduleStore 保存到 store_file 的内容
+type scheduleStore struct {
+	Schedules []*Schedule             `json:"schedules"` // 接口创建的任务
+	Runs      map[string]*ScheduleRun `json:"runs"`      // 每个任务最近一次运行，用于补发错过的运行
+}
+
+// NewScheduler 加载配置文件和 store_file 中的任务
+func NewScheduler(config *Config) (*Scheduler, error) {
+	location, err := loadLocation(config.Scheduler.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scheduler{
+		config:   config,
+		location: location,
+		entries:  make(map[string]*scheduleEntry),
+		wake:     make(chan struct{}, 1),
+	}
+
+	for _, schedule := range config.Scheduler.Schedules {
+		if err := s.add(schedule, ScheduleSourceConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	store, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, schedule := range store.Schedules {
+		if _, ok := s.entries[schedule.ID]; ok {
+			log.Printf("scheduler: ignoring stored schedule %s, an entry with the same id is defined in the config file", schedule.ID)
+			continue
+		}
+		if problems := schedule.validateAPI(config); len(problems) > 0 {
+			log.Printf("scheduler: stored schedule %s is invalid and will fail when run: %s", schedule.ID, strings.Join(problems, "; "))
+		}
+		if err := s.add(schedule, ScheduleSourceAPI); err != nil {
+			log.Printf("scheduler: skipping stored schedule %s: %v", schedule.ID, err)
+		}
+	}
+	for id, run := range store.Runs {
+		if entry, ok := s.entries[id]; ok {
+			entry.lastRun = run
+		}
+	}
+
+	return s, nil
+}
+
+// add 添加任务，调用方负责加锁
+func (s *Scheduler) add(schedule *Schedule, source string) error {
+	entry, err := s.newEntry(schedule, source)
+	if err != nil {
+		return err
+	}
+	s.entries[schedule.ID] = entry
+	return nil
+}
+
+// newEntry 解析任务的 cron 表达式和时区
+func (s *Scheduler) newEntry(schedule *Schedule, source string) (*scheduleEntry, error) {
+	cron, err := ParseCron(schedule.Cron)
+	if err != nil {
+		return nil, err
+	}
+	location := s.location
+	if schedule.Timezone != "" {
+		if location, err = loadLocation(schedule.Timezone); err != nil {
+			return nil, err
+		}
+	}
+	return &scheduleEntry{schedule: schedule, source: source, cron: cron, location: location}, nil
+}
+
+// Start 开始按计划运行任务，先补发服务停止期间错过的运行；ctx 取消时停止
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	s.started = true
+	now := time.Now()
+	for _, entry := range s.entries {
+		entry.next = s.nextRun(entry, now)
+		if missed, ok := s.missedRun(entry, now); ok {
+			log.Printf("scheduler: schedule %s missed its run at %s, catching up", entry.schedule.ID, missed.Format(time.RFC3339))
+			go s.run(entry, missed, TriggerCatchUp)
+		}
+	}
+	s.mu.Unlock()
+
+	go s.loop(ctx)
+}
+
+// loop 等待最近的计划时间，任务变化时重新计算
+func (s *Scheduler) loop(ctx context.Context) {
+	for {
+		s.mu.Lock()
+		var next time.Time
+		for _, entry := range s.entries {
+			if !entry.next.IsZero() && (next.IsZero() || entry.next.Before(next)) {
+				next = entry.next
+			}
+		}
+		s.mu.Unlock()
+
+		wait := time.Hour
+		if !next.IsZero() {
+			wait = time.Until(next)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+
+		s.mu.Lock()
+		now := time.Now()
+		for _, entry := range s.entries {
+			if entry.next.IsZero() || entry.next.After(now) {
+				continue
+			}
+			go s.run(entry, entry.next, TriggerSchedule)
+			entry.next = s.nextRun(entry, now)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// nextRun 计算任务下一次自动运行的时间，未开启调度或任务暂停时返回零值
+func (s *Scheduler) nextRun(entry *scheduleEntry, now time.Time) time.Time {
+	if !s.config.Scheduler.Enabled || entry.schedule.Disabled {
+		return time.Time{}
+	}
+	return entry.cron.Next(now.In(entry.location))
+}
+
+// missedRun 返回上次运行之后、now 之前最近的一个计划时间，只有从未运行过的任务不补发
+func (s *Scheduler) missedRun(entry *scheduleEntry, now time.Time) (time.Time, bool) {
+	if !s.config.Scheduler.Enabled || entry.schedule.Disabled || entry.lastRun == nil {
+		return time.Time{}, false
+	}
+	if entry.schedule.CatchUp == CatchUpSkip {
+		return time.Time{}, false
+	}
+
+	var missed time.Time
+	for t := entry.cron.Next(entry.lastRun.ScheduledAt.In(entry.location)); !t.IsZero() && !t.After(now); t = entry.cron.Next(t) {
+		missed = t
+	}
+	return missed, !missed.IsZero()
+}
+
+// poke 通知调度循环重新计算等待时间
+func (s *Scheduler) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// List 返回所有任务的状态，按 ID 排序
+func (s *Scheduler) List() []*ScheduleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]*ScheduleStatus, 0, len(s.entries))
+	for _, entry := range s.entries {
+		statuses = append(statuses, entry.status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+	return statuses
+}
+
+// Get 返回任务的状态
+func (s *Scheduler) Get(id string) (*ScheduleStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+	return entry.status(), nil
+}
+
+// Create 创建任务，未指定 ID 时自动生成
+func (s *Scheduler) Create(schedule *Schedule) (*ScheduleStatus, error) {
+	if schedule.ID == "" {
+		schedule.ID = newScheduleID()
+	}
+	if problems := schedule.validateAPI(s.config); len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrScheduleInvalid, strings.Join(problems, "; "))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[schedule.ID]; ok {
+		return nil, ErrScheduleExists
+	}
+	if err := s.add(schedule, ScheduleSourceAPI); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrScheduleInvalid, err)
+	}
+	entry := s.entries[schedule.ID]
+	if s.started {
+		entry.next = s.nextRun(entry, time.Now())
+	}
+	if err := s.save(); err != nil {
+		delete(s.entries, schedule.ID)
+		return nil, err
+	}
+
+	s.poke()
+	return entry.status(), nil
+}
+
+// Update 替换接口创建的任务，保留最近的运行记录
+func (s *Scheduler) Update(id string, schedule *Schedule) (*ScheduleStatus, error) {
+	schedule.ID = id
+	if problems := schedule.validateAPI(s.config); len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrScheduleInvalid, strings.Join(problems, "; "))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+	if entry.source != ScheduleSourceAPI {
+		return nil, ErrScheduleReadOnly
+	}
+	updated, err := s.newEntry(schedule, ScheduleSourceAPI)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrScheduleInvalid, err)
+	}
+
+	// 原地修改，正在进行的运行结束时仍能清除运行标记
+	old := *entry
+	entry.schedule, entry.cron, entry.location = updated.schedule, updated.cron, updated.location
+	if s.started {
+		entry.next = s.nextRun(entry, time.Now())
+	}
+	if err := s.save(); err != nil {
+		*entry = old
+		return nil, err
+	}
+
+	s.poke()
+	return entry.status(), nil
+}
+
+// Delete 删除接口创建的任务，正在进行的运行不受影响
+func (s *Scheduler) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return ErrScheduleNotFound
+	}
+	if entry.source != ScheduleSourceAPI {
+		return ErrScheduleReadOnly
+	}
+
+	delete(s.entries, id)
+	if err := s.save(); err != nil {
+		s.entries[id] = entry
+		return err
+	}
+
+	s.poke()
+	return nil
+}
+
+// RunNow 立即运行任务并等待结束，任务正在运行时返回 ErrScheduleRunning
+func (s *Scheduler) RunNow(id string) (*ScheduleRun, error) {
+	s.mu.Lock()
+	entry, ok := s.entries[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+
+	run := s.run(entry, time.Now().In(entry.location).Truncate(time.Second), TriggerManual)
+	if run == nil {
+		return nil, ErrScheduleRunning
+	}
+	return run, nil
+}
+
+// run 运行一次任务并保存运行记录，任务已在运行时跳过并返回 nil
+func (s *Scheduler) run(entry *scheduleEntry, scheduledAt time.Time, trigger string) *ScheduleRun {
+	s.mu.Lock()
+	if entry.running {
+		s.mu.Unlock()
+		log.Printf("scheduler: schedule %s is still running, skipping %s run", entry.schedule.ID, trigger)
+		return nil
+	}
+	entry.running = true
+	schedule := entry.schedule
+	s.mu.Unlock()
+
+	run := &ScheduleRun{Trigger: trigger, ScheduledAt: scheduledAt, StartedAt: time.Now()}
+	ctx, cancel := context.WithTimeout(context.Background(), scheduleRunTimeout)
+	err := s.execute(ctx, schedule, entry.source, scheduledAt, run)
+	cancel()
+
+	run.FinishedAt = time.Now()
+	run.Status = "success"
+	if err != nil {
+		run.Status = "failed"
+		run.Error = err.Error()
+		log.Printf("scheduler: schedule %s %s run failed: %v", schedule.ID, trigger, err)
+	} else {
+		log.Printf("scheduler: schedule %s %s run finished, %d deliveries", schedule.ID, trigger, len(run.Deliveries))
+	}
+
+	s.mu.Lock()
+	entry.running = false
+	entry.lastRun = run
+	if err := s.save(); err != nil {
+		log.Printf("scheduler: failed to save run of schedule %s: %v", schedule.ID, err)
+	}
+	s.mu.Unlock()
+
+	return run
+}
+
+// execute 生成任务的所有报告并推送，某份报告或某个渠道失败时继续处理其余的
+// 接口创建的任务在运行前重新校验，store_file 中的任务可能早于当前的目录限制
+func (s *Scheduler) execute(ctx context.Context, schedule *Schedule, source string, scheduledAt time.Time, run *ScheduleRun) error {
+	if source == ScheduleSourceAPI {
+		if problems := schedule.validateAPI(s.config); len(problems) > 0 {
+			return fmt.Errorf("%w: %s", ErrScheduleInvalid, strings.Join(problems, "; "))
+		}
+	}
+
+	repoPaths, err := DiscoverRepos(schedule.RepoPaths)
+	if err != nil {
+		return err
+	}
+
+	var roster *Roster
+	rosterFile := schedule.RosterFile
+	if rosterFile == "" {
+		rosterFile = s.config.RosterFile
+	}
+	if rosterFile != "" {
+		if roster, err = LoadRoster(rosterFile); err != nil {
+			return err
+		}
+	}
+
+	authors := schedule.Authors
+	if len(authors) == 0 {
+		authors = []string{s.config.DefaultAuthor}
+	}
+	date := scheduledAt.AddDate(0, 0, schedule.DateOffsetDays)
+	renderer := NewReportRenderer(schedule.Template, s.config)
+
+	var failures []string
+	for _, author := range authors {
+		if schedule.Team {
+			author = ""
+		}
+
+		generator := NewMultiRepoReportGenerator(repoPaths, author, s.config)
+		generator.SetTeamMode(schedule.Team)
+		if roster != nil {
+			generator.SetRoster(roster)
+		}
+
+		msg, err := s.renderMessage(generator, renderer, schedule.Type, date, repoPaths)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", displayAuthor(author), err))
+			continue
+		}
+
+		for _, result := range s.config.Delivery.Deliver(ctx, schedule.Channels, msg) {
+			run.Deliveries = append(run.Deliveries, ScheduleDelivery{Author: author, DeliveryResult: result})
+			if result.Error != "" {
+				failures = append(failures, fmt.Sprintf("%s -> %s: %s", displayAuthor(author), result.Channel, result.Error))
+			}
+		}
+
+		if schedule.Team {
+			break
+		}
+	}
+
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// renderMessage 生成报告并渲染为推送消息，配置了报告历史时同时保存
+func (s *Scheduler) renderMessage(generator *ReportGenerator, renderer *ReportRenderer, reportType string, date time.Time, repoPaths []string) (*DeliveryMessage, error) {
+	report, err := generator.GenerateReport(reportType, date)
+	if err != nil {
+		return nil, err
+	}
+	markdown, err := renderer.Render(report)
+	if err != nil {
+		return nil, err
+	}
+	if history := s.config.History(); history != nil {
+		if _, err := history.Record(report, markdown, renderer.templateFile, HistorySourceSchedule, repoPaths); err != nil {
+			log.Printf("scheduler: failed to save report history: %v", err)
+		}
+	}
+	return NewReportMessage(renderer, report, markdown)
+}
+
+// displayAuthor 日志和错误信息中显示的作者
+func displayAuthor(author string) string {
+	if author == "" {
+		return "default author"
+	}
+	return author
+}
+
+// status 任务的当前状态，调用方负责加锁
+func (e *scheduleEntry) status() *ScheduleStatus {
+	status := &ScheduleStatus{
+		Schedule: e.schedule,
+		Source:   e.source,
+		Running:  e.running,
+		LastRun:  e.lastRun,
+	}
+	if !e.next.IsZero() {
+		next := e.next
+		status.NextRun = &next
+	}
+	return status
+}
+
+// load 读取 store_file，文件不存在时返回空内容
+func (s *Scheduler) load() (*scheduleStore, error) {
+	store := &scheduleStore{Runs: make(map[string]*ScheduleRun)}
+	if s.config.Scheduler.StoreFile == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(s.config.Scheduler.StoreFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取定时任务文件失败: %v", err)
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("解析定时任务文件 %s 失败: %v", s.config.Scheduler.StoreFile, err)
+	}
+	if store.Runs == nil {
+		store.Runs = make(map[string]*ScheduleRun)
+	}
+	return store, nil
+}
+
+// save 写入 store_file，先写临时文件再替换，避免写到一半时中断留下损坏的文件；调用方负责加锁
+func (s *Scheduler) save() error {
+	if s.config.Scheduler.StoreFile == "" {
+		return nil
+	}
+
+	store := scheduleStore{Schedules: []*Schedule{}, Runs: make(map[string]*ScheduleRun)}
+	for id, entry := range s.entries {
+		if entry.source == ScheduleSourceAPI {
+			store.Schedules = append(store.Schedules, entry.schedule)
+		}
+		if entry.lastRun != nil {
+			store.Runs[id] = entry.lastRun
+		}
+	}
+	sort.Slice(store.Schedules, func(i, j int) bool { return store.Schedules[i].ID < store.Schedules[j].ID })
+
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := s.config.Scheduler.StoreFile
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("创建定时任务文件目录失败: %v", err)
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入定时任务文件失败: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("写入定时任务文件失败: %v", err)
+	}
+	return nil
+}
+
+// validate 校验任务，返回发现的问题
+func (sc *Schedule) validate(config *Config) []string {
+	var problems []string
+
+	if !scheduleIDRegex.MatchString(sc.ID) {
+		problems = append(problems, fmt.Sprintf("id %q 只能包含字母、数字、- 和 _", sc.ID))
+	}
+	if cron, err := ParseCron(sc.Cron); err != nil {
+		problems = append(problems, err.Error())
+	} else if cron.Next(time.Now()).IsZero() {
+		problems = append(problems, fmt.Sprintf("cron 表达式 %q 没有可以运行的时间", sc.Cron))
+	}
+	if sc.Timezone != "" {
+		if _, err := loadLocation(sc.Timezone); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	switch sc.Type {
+	case ReportTypeDaily, ReportTypeWeekly, ReportTypeMonthly, ReportTypeQuarterly, ReportTypeYearly:
+	default:
+		problems = append(problems, fmt.Sprintf("type 只支持 daily、weekly、monthly、quarterly 和 yearly，当前为 %q", sc.Type))
+	}
+	if sc.DateOffsetDays < -366 || sc.DateOffsetDays > 366 {
+		problems = append(problems, "date_offset_days 必须在 -366 到 366 之间")
+	}
+	if len(sc.RepoPaths) == 0 {
+		problems = append(problems, "repo_paths 不能为空")
+	}
+	if sc.Team && len(sc.Authors) > 0 {
+		problems = append(problems, "team 模式包含所有成员，不能同时指定 authors")
+	}
+	if len(sc.Channels) == 0 {
+		problems = append(problems, "channels 不能为空")
+	}
+	for _, channel := range sc.Channels {
+		if _, ok := config.Delivery.Channels[channel]; !ok {
+			problems = append(problems, fmt.Sprintf("推送渠道 %s 未在 delivery.channels 中配置", channel))
+		}
+	}
+	switch sc.CatchUp {
+	case "", CatchUpOnce, CatchUpSkip:
+	default:
+		problems = append(problems, fmt.Sprintf("catch_up 只支持 once 和 skip，当前为 %q", sc.CatchUp))
+	}
+
+	return problems
+}
+
+// validateAPI 校验接口提交的任务，接口不能读取服务器上任意的文件：
+// 只允许使用内置模板和配置中的花名册，仓库必须位于 scheduler.repo_roots 之内
+func (sc *Schedule) validateAPI(config *Config) []string {
+	problems := sc.validate(config)
+	if sc.Template != "" {
+		if _, ok := LookupBuiltinTemplate(sc.Template); !ok {
+			problems = append(problems, fmt.Sprintf("未知的内置模板 %s，可选 %s", sc.Template, builtinTemplateNames()))
+		}
+	}
+	if sc.RosterFile != "" {
+		problems = append(problems, "接口创建的任务不能指定 roster_file，使用配置中的 roster_file")
+	}
+
+	roots := config.Scheduler.apiRepoRoots(config)
+	if len(roots) == 0 && len(sc.RepoPaths) > 0 {
+		problems = append(problems, "未配置 scheduler.repo_roots 或 default_repo_path，接口创建的任务不能使用任何仓库")
+		return problems
+	}
+	for _, path := range sc.RepoPaths {
+		if !pathWithin(path, roots) {
+			problems = append(problems, fmt.Sprintf("仓库路径 %s 不在允许的目录中: %s", path, strings.Join(roots, ", ")))
+		}
+	}
+	return problems
+}
+
+// apiRepoRoots 接口创建的任务可以使用的仓库目录
+func (s *SchedulerSettings) apiRepoRoots(config *Config) []string {
+	if len(s.RepoRoots) > 0 {
+		return s.RepoRoots
+	}
+	if config.DefaultRepoPath != "" {
+		return []string{config.DefaultRepoPath}
+	}
+	return nil
+}
+
+// validate 校验调度配置和配置文件中的任务
+func (s *SchedulerSettings) validate(config *Config) []string {
+	var problems []string
+
+	if _, err := loadLocation(s.Timezone); err != nil {
+		problems = append(problems, fmt.Sprintf("scheduler.timezone 无效: %v", err))
+	}
+
+	seen := make(map[string]bool)
+	for i, schedule := range s.Schedules {
+		if schedule == nil {
+			continue
+		}
+		name := schedule.ID
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if seen[schedule.ID] {
+			problems = append(problems, fmt.Sprintf("scheduler.schedules 中 id 重复: %s", schedule.ID))
+		}
+		seen[schedule.ID] = true
+		for _, problem := range schedule.validate(config) {
+			problems = append(problems, fmt.Sprintf("scheduler.schedules.%s: %s", name, problem))
+		}
+	}
+
+	return problems
+}
+
+// loadLocation 加载时区，为空时使用服务器本地时区
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("未知的时区 %s", name)
+	}
+	return location, nil
+}
+
+// newScheduleID 生成随机的任务 ID
+func newScheduleID() string {
+	random := make([]byte, 4)
+	rand.Read(random)
+	return hex.EncodeToString(random)
+}
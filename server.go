@@ -1,45 +1,106 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
-	"strings"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 )
 
 type GenerateReportRequest struct {
-	RepoPath string `json:"repoPath"`
-	Type     string `json:"type"`
-	Date     string `json:"date"`
-	Author   string `json:"author,omitempty"`
+	RepoPath   string   `json:"repoPath"`
+	RepoPaths  []string `json:"repoPaths,omitempty"` // 多个仓库或工作区目录
+	Type       string   `json:"type"`
+	Date       string   `json:"date"`
+	Author     string   `json:"author,omitempty"`
+	OKRFile    string   `json:"okrFile,omitempty"`
+	Since      string   `json:"since,omitempty"`      // range 报告的开始日期
+	Until      string   `json:"until,omitempty"`      // range 报告的结束日期
+	Team       bool     `json:"team,omitempty"`       // 团队模式
+	RosterFile string   `json:"rosterFile,omitempty"` // 团队花名册文件
+	Template   string   `json:"template,omitempty"`   // 内置模板名，默认使用配置中的 default_template
+	Format     string   `json:"format,omitempty"`     // 输出格式: markdown（默认）、html、json、yaml、csv 或 xlsx
+	BOM        bool     `json:"bom,omitempty"`        // csv 格式是否写入 UTF-8 BOM
 }
 
 type GenerateReportResponse struct {
-	Content string `json:"content"`
-	Type    string `json:"type"`
-	Date    string `json:"date"`
+	Content string        `json:"content"`
+	Type    string        `json:"type"`
+	Date    string        `json:"date"`
+	Format  string        `json:"format"`
+	Report   *ReportExport `json:"report,omitempty"`   // format 为 json 时的结构化报告，此时 content 为空
+	ReportID uint64        `json:"reportId,omitempty"` // 报告历史中的编号，未配置 history_file 时为空
 }
 
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
+
 type OptimizeReportRequest struct {
-	Content string `json:"content"`
+	Content  string        `json:"content"`
+	Provider string        `json:"provider,omitempty"` // 服务商名称，默认使用配置中的 ai.provider
+	Prompt   string        `json:"prompt,omitempty"`   // 提示词模板名称，默认使用 default
+	Type     string        `json:"type,omitempty"`     // 报告类型，填入提示词模板
+	Author   string        `json:"author,omitempty"`   // 报告作者，填入提示词模板
+	Period   string        `json:"period,omitempty"`   // 报告周期，填入提示词模板
+	ReportID uint64        `json:"reportId,omitempty"` // 报告历史中的编号，优化结果保存为该报告的修订
+	Report   *ReportExport `json:"report,omitempty"`   // 生成接口返回的结构化报告，离线总结时优先于报告历史和 content
 }
 
 type OptimizeReportResponse struct {
-	OptimizedContent string `json:"optimizedContent"`
+	OptimizedContent string           `json:"optimizedContent"`
+	Redactions       []RedactionEntry `json:"redactions"`              // 发送给AI前被替换的敏感信息
+	SummaryRounds    int              `json:"summaryRounds,omitempty"` // 报告超出token预算时分段整理的轮数
+	Offline          bool             `json:"offline,omitempty"`       // 未配置API密钥，使用离线生成的总结
+	Revision         int              `json:"revision,omitempty"`      // 保存到报告历史的修订号
+}
+
+type OKRDraftRequest struct {
+	RepoPath   string   `json:"repoPath"`
+	RepoPaths  []string `json:"repoPaths,omitempty"` // 多个仓库或工作区目录
+	Date       string   `json:"date"`                // 季度内的任意日期，默认为今天
+	Author     string   `json:"author,omitempty"`
+	Team       bool     `json:"team,omitempty"`       // 团队模式
+	RosterFile string   `json:"rosterFile,omitempty"` // 团队花名册文件
+	Provider   string   `json:"provider,omitempty"`   // 服务商名称，默认使用配置中的 ai.provider
+	Attempts   int      `json:"attempts,omitempty"`   // 输出不符合 schema 时的最大尝试次数
+}
+
+type OKRDraftResponse struct {
+	Draft      *OKRDraft        `json:"draft"`
+	Markdown   string           `json:"markdown"`
+	Attempts   int              `json:"attempts"`   // 实际调用模型的次数
+	Redactions []RedactionEntry `json:"redactions"` // 发送给AI前被替换的敏感信息
 }
 
+type DeliverRequest struct {
+	Content  string   `json:"content"`          // 要推送的 Markdown 报告，可以是润色后的内容
+	HTML     string   `json:"html,omitempty"`   // 报告的 HTML 版本，只用于邮件，省略时邮件只有纯文本正文
+	Title    string   `json:"title,omitempty"`  // 消息标题，默认取报告的第一个标题
+	Type     string   `json:"type,omitempty"`   // 报告类型，填入邮件主题模板
+	Author   string   `json:"author,omitempty"` // 报告作者，填入邮件主题模板
+	Period   string   `json:"period,omitempty"` // 报告周期，填入邮件主题模板
+	Date     string   `json:"date,omitempty"`   // 报告日期，填入邮件主题模板
+	Channels []string `json:"channels"`         // 配置中 delivery.channels 的渠道名
+}
+
+type DeliverResponse struct {
+	Results []DeliveryResult `json:"results"`
+}
+
+// serverConfig 服务器模式下使用的配置，由 startServer 设置
+var serverConfig = DefaultConfig()
+
 func enableCORS(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
@@ -68,27 +129,50 @@ func generateReportHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 验证输入
-	if req.RepoPath == "" {
+	paths := req.RepoPaths
+	if req.RepoPath != "" {
+		paths = append([]string{req.RepoPath}, paths...)
+	}
+	if len(paths) == 0 {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(ErrorResponse{Error: "Repository path is required"})
 		return
 	}
 
-	// 检查仓库路径是否存在
-	if _, err := os.Stat(req.RepoPath); os.IsNotExist(err) {
+	// 只允许选择内置模板，避免通过请求读取服务器上的任意文件
+	if req.Template != "" {
+		if _, ok := LookupBuiltinTemplate(req.Template); !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Unknown template: %s. Available: %s", req.Template, builtinTemplateNames())})
+			return
+		}
+	}
+
+	switch req.Format {
+	case "":
+		req.Format = FormatMarkdown
+	case FormatMarkdown, FormatHTML, FormatJSON, FormatYAML, FormatCSV, FormatXLSX:
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid format. Use 'markdown', 'html', 'json', 'yaml', 'csv' or 'xlsx'"})
+		return
+	}
+	if req.Template != "" && req.Format != FormatMarkdown {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Repository path does not exist"})
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Template only applies to the markdown format"})
 		return
 	}
 
-	// 检查是否为Git仓库
-	gitDir := filepath.Join(req.RepoPath, ".git")
-	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+	// 检查仓库路径，目录中不是Git仓库时作为工作区扫描
+	repoPaths, err := DiscoverRepos(paths)
+	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Path is not a Git repository"})
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Invalid repository path: %v", err)})
 		return
 	}
 
@@ -102,19 +186,62 @@ func generateReportHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 创建报告生成器
-	generator := NewReportGenerator(req.RepoPath, req.Author)
+	generator := NewMultiRepoReportGenerator(repoPaths, req.Author, serverConfig)
+	generator.SetTeamMode(req.Team)
+	rosterFile, err := serverConfig.RequestFile(req.RosterFile, serverConfig.RosterFile)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Invalid roster file: %v", err)})
+		return
+	}
+	if rosterFile != "" {
+		roster, err := LoadRoster(rosterFile)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Invalid roster file: %v", err)})
+			return
+		}
+		generator.SetRoster(roster)
+	}
+	okrFile, err := serverConfig.RequestFile(req.OKRFile, serverConfig.OKRFile)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Invalid OKR file: %v", err)})
+		return
+	}
+	if okrFile != "" {
+		okrSet, err := LoadOKRSet(okrFile)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Invalid OKR file: %v", err)})
+			return
+		}
+		generator.SetOKRSet(okrSet)
+	}
 
 	// 生成报告
 	var report *Report
 	switch req.Type {
-	case "daily":
-		report, err = generator.GenerateDailyReport(targetDate)
-	case "weekly":
-		report, err = generator.GenerateWeeklyReport(targetDate)
+	case ReportTypeDaily, ReportTypeWeekly, ReportTypeMonthly, ReportTypeQuarterly, ReportTypeYearly:
+		report, err = generator.GenerateReport(req.Type, targetDate)
+	case ReportTypeRange:
+		var since, until time.Time
+		since, until, err = parseDateRange(req.Since, req.Until)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Invalid date range: %v", err)})
+			return
+		}
+		report, err = generator.GenerateRangeReport(since, until)
 	default:
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid report type. Use 'daily' or 'weekly'"})
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid report type. Use 'daily', 'weekly', 'monthly', 'quarterly', 'yearly' or 'range'"})
 		return
 	}
 
@@ -125,9 +252,69 @@ func generateReportHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 保存到报告历史，以相同参数重新生成其他格式时返回已有的记录；保存失败不影响返回报告
+	renderer := NewReportRenderer(req.Template, serverConfig)
+	var markdown string
+	var reportID uint64
+	if history := serverConfig.History(); history != nil {
+		markdown, err = renderer.Render(report)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Failed to render report: %v", err)})
+			return
+		}
+		entry, err := history.Record(report, markdown, renderer.templateFile, HistorySourceAPI, repoPaths)
+		if err != nil {
+			log.Printf("Failed to save report history: %v", err)
+		} else {
+			reportID = entry.ID
+		}
+	}
+
+	// 表格格式直接返回文件：csv 为包含各表的 zip，xlsx 为 Excel 文件
+	if req.Format == FormatCSV || req.Format == FormatXLSX {
+		data, err := ExportSpreadsheet(report, req.Format, req.BOM)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Failed to export report: %v", err)})
+			return
+		}
+
+		contentType, ext := "application/zip", "zip"
+		if req.Format == FormatXLSX {
+			contentType, ext = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-report-%s.%s"`, report.Type, report.Date.Format("2006-01-02"), ext))
+		if reportID != 0 {
+			w.Header().Set("X-Report-Id", strconv.FormatUint(reportID, 10))
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+		return
+	}
+
+	// json 格式直接返回结构化报告，不再嵌套一层字符串
+	if req.Format == FormatJSON {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(GenerateReportResponse{
+			Type:     req.Type,
+			Date:     req.Date,
+			Format:   req.Format,
+			Report:   NewReportExport(report),
+			ReportID: reportID,
+		})
+		return
+	}
+
 	// 渲染报告
-	renderer := NewReportRenderer("")
-	content, err := renderer.Render(report)
+	content := markdown
+	if req.Format != FormatMarkdown || markdown == "" {
+		content, err = renderer.RenderFormat(report, req.Format)
+	}
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -137,9 +324,11 @@ func generateReportHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 返回成功响应
 	response := GenerateReportResponse{
-		Content: content,
-		Type:    req.Type,
-		Date:    req.Date,
+		Content:  content,
+		Type:     req.Type,
+		Date:     req.Date,
+		Format:   req.Format,
+		ReportID: reportID,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -175,8 +364,21 @@ func optimizeReportHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 调用免费大模型API进行优化
-	optimizedContent, err := optimizeWithAI(req.Content)
+	systemPrompt, err := optimizePrompt(&req)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// 调用大模型API进行优化，客户端断开或超过总时限时取消
+	ctx, cancel := context.WithTimeout(r.Context(), serverConfig.AI.Deadline())
+	defer cancel()
+	response, err := optimizeWithAI(ctx, &req, systemPrompt)
+	if errors.Is(err, ErrAPIKeyMissing) {
+		response, err = offlineOptimize(&req), nil
+	}
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -185,120 +387,891 @@ func optimizeReportHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 返回优化后的内容
-	response := OptimizeReportResponse{
-		OptimizedContent: optimizedContent,
-	}
+	recordOptimized(&req, response)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
 
-func optimizeWithAI(content string) (string, error) {
-	// 使用智谱AI的Chat Completions API
-	apiURL := getAIAPIURL()
-	apiKey := getAIAPIKey()
-	
-	if apiURL == "" || apiKey == "" {
-		return "", fmt.Errorf("AI API configuration not found. Please set AI_API_URL and AI_API_KEY environment variables")
-	}
-
-	// 构建请求体 - 智谱AI格式
-	requestBody := map[string]interface{}{
-		"model": "glm-4-flash", // 智谱AI的免费模型
-		"messages": []map[string]string{
-			{
-				"role": "system",
-				"content": "你是一个专业的技术文档优化助手。请优化以下Git提交报告，使其更加清晰、专业和易读。保持原有的结构和信息完整性，但改进语言表达、格式和可读性。请用中文回复。",
-			},
-			{
-				"role": "user",
-				"content": content,
-			},
+// optimizePrompt 渲染请求选择的提示词模板
+func optimizePrompt(req *OptimizeReportRequest) (string, error) {
+	library, err := serverConfig.PromptLibrary()
+	if err != nil {
+		return "", err
+	}
+
+	return library.Render(req.Prompt, &PromptData{
+		Type:   req.Type,
+		Period: req.Period,
+		Author: optimizeAuthor(req),
+	})
+}
+
+// optimizeAuthor 请求中的报告作者，未指定时使用默认作者
+func optimizeAuthor(req *OptimizeReportRequest) string {
+	if req.Author != "" {
+		return req.Author
+	}
+	return serverConfig.DefaultAuthor
+}
+
+// redactOptimize 用同一个脱敏会话替换系统提示词和报告内容中的敏感信息
+// 提示词模板会填入作者等信息，报告作者也作为需要替换的词语
+func redactOptimize(req *OptimizeReportRequest, systemPrompt string) (string, string, *RedactionSession, error) {
+	redactor, err := serverConfig.Redactor()
+	if err != nil {
+		return "", "", nil, err
+	}
+	redactor, err = redactor.WithTerms(optimizeAuthor(req))
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	content, session := redactor.Redact(req.Content)
+	return redactor.RedactWith(session, systemPrompt), content, session, nil
+}
+
+// optimizeRequest 构建优化报告的对话
+func optimizeRequest(systemPrompt, content string) *CompletionRequest {
+	return &CompletionRequest{
+		Messages: []ChatMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: content},
 		},
-		"max_tokens": 2000,
-		"temperature": 0.7,
+	}
+}
+
+// optimizeWithAI 脱敏后调用大模型优化报告，回复中的占位符会被还原
+func optimizeWithAI(ctx context.Context, req *OptimizeReportRequest, systemPrompt string) (*OptimizeReportResponse, error) {
+	provider, err := serverConfig.AI.NewProvider(req.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	systemPrompt, redacted, session, err := redactOptimize(req, systemPrompt)
+	if err != nil {
+		return nil, err
 	}
 
-	jsonData, err := json.Marshal(requestBody)
+	request, rounds, err := prepareOptimize(ctx, provider, req.Provider, systemPrompt, redacted, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %v", err)
+		return nil, err
 	}
 
-	// 发送HTTP请求
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	optimized, err := provider.Complete(ctx, request)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
+		return nil, err
+	}
+
+	return &OptimizeReportResponse{
+		OptimizedContent: session.Restore(optimized),
+		Redactions:       session.Audit(),
+		SummaryRounds:    rounds,
+	}, nil
+}
+
+// offlineOptimize 未配置API密钥时的降级方案，在报告开头加上离线生成的叙述性总结
+func offlineOptimize(req *OptimizeReportRequest) *OptimizeReportResponse {
+	lang := serverConfig.NarrativeLanguage
+	if req.Prompt == "english" {
+		lang = NarrativeEnglish
+	}
+
+	heading := "## 总结"
+	if lang == NarrativeEnglish {
+		heading = "## Summary"
+	}
+
+	report := optimizeSourceReport(req)
+	return &OptimizeReportResponse{
+		OptimizedContent: heading + "\n\n" + BuildNarrative(report, lang) + "\n\n" + req.Content,
+		Redactions:       []RedactionEntry{},
+		Offline:          true,
+	}
+}
+
+// optimizeSourceReport 返回离线总结使用的结构化报告
+// 依次使用请求中的 report、报告历史中 reportId 对应的报告，都没有时才从 content 中解析提交条目
+func optimizeSourceReport(req *OptimizeReportRequest) *Report {
+	if req.Report != nil {
+		return reportFromExport(req.Report)
+	}
+
+	if history := serverConfig.History(); req.ReportID != 0 && history != nil {
+		record, err := history.Get(req.ReportID)
+		if err == nil && record.Report != nil {
+			return reportFromExport(record.Report)
+		}
+		if err != nil {
+			log.Printf("Failed to load report %d for offline summary: %v", req.ReportID, err)
+		}
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+	return reportFromMarkdown(req.Content, req.Type, serverConfig)
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// prepareOptimize 构建优化请求，报告超出服务商的token预算时先分段整理
+// 返回分段整理的轮数，未超出预算时为 0
+func prepareOptimize(ctx context.Context, provider Provider, providerName, systemPrompt, content string, onProgress func(done, total int)) (*CompletionRequest, int, error) {
+	budget, err := serverConfig.AI.Budget(providerName)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
+		return nil, 0, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	condensed, rounds, err := Condense(ctx, provider, budget, systemPrompt, content, onProgress)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %v", err)
+		return nil, rounds, err
+	}
+	if rounds > 0 {
+		systemPrompt += "\n\n" + condensedNotice
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	return optimizeRequest(systemPrompt, condensed), rounds, nil
+}
+
+// optimizeReportStreamHandler 以 Server-Sent Events 流式返回优化结果
+// 事件依次为若干 progress（分段整理进度，报告未超出预算时没有）、若干 delta（增量内容）和一个 done（完整内容及脱敏记录），出错时发送 error
+func optimizeReportStreamHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var req OptimizeReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid JSON format"})
+		return
 	}
 
-	// 解析响应
-	var response map[string]interface{}
-	if err := json.Unmarshal(body, &response); err != nil {
-		return "", fmt.Errorf("failed to parse response: %v", err)
+	if req.Content == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Content is required"})
+		return
 	}
 
-	// 提取优化后的内容
-	choices, ok := response["choices"].([]interface{})
-	if !ok || len(choices) == 0 {
-		return "", fmt.Errorf("invalid response format: no choices found")
+	systemPrompt, err := optimizePrompt(&req)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()})
+		return
 	}
 
-	firstChoice, ok := choices[0].(map[string]interface{})
+	flusher, ok := w.(http.Flusher)
 	if !ok {
-		return "", fmt.Errorf("invalid response format: invalid choice format")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Streaming not supported"})
+		return
 	}
 
-	message, ok := firstChoice["message"].(map[string]interface{})
-	if !ok {
-		return "", fmt.Errorf("invalid response format: no message found")
+	provider, err := serverConfig.AI.NewProvider(req.Provider)
+	if errors.Is(err, ErrAPIKeyMissing) {
+		// 离线总结一次性生成，作为一个 delta 发送
+		response := offlineOptimize(&req)
+		recordOptimized(&req, response)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		writeSSE(w, "delta", map[string]string{"content": response.OptimizedContent})
+		writeSSE(w, "done", response)
+		flusher.Flush()
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Failed to optimize report: %v", err)})
+		return
+	}
+
+	systemPrompt, redacted, session, err := redactOptimize(&req, systemPrompt)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Failed to redact report: %v", err)})
+		return
+	}
+	restorer := session.NewRestorer()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// 客户端断开时 r.Context() 被取消，上游请求随之中止
+	ctx, cancel := context.WithTimeout(r.Context(), serverConfig.AI.Deadline())
+	defer cancel()
+
+	// 报告超出token预算时先分段整理，每完成一段发送一个 progress 事件
+	var optimizedContent string
+	request, rounds, err := prepareOptimize(ctx, provider, req.Provider, systemPrompt, redacted, func(done, total int) {
+		writeSSE(w, "progress", map[string]int{"done": done, "total": total})
+		flusher.Flush()
+	})
+	if err == nil {
+		optimizedContent, err = StreamCompletion(ctx, provider, request, func(delta string) error {
+			// 占位符可能被拆分在多个增量中，还原后再发送
+			delta = restorer.Write(delta)
+			if delta == "" {
+				return nil
+			}
+			if err := writeSSE(w, "delta", map[string]string{"content": delta}); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		})
+	}
+	if err != nil {
+		if r.Context().Err() != nil {
+			log.Printf("optimize stream cancelled by client: %v", err)
+			return
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("deadline of %s exceeded", serverConfig.AI.Deadline())
+		}
+		writeSSE(w, "error", ErrorResponse{Error: fmt.Sprintf("Failed to optimize report: %v", err)})
+		flusher.Flush()
+		return
+	}
+
+	if rest := restorer.Flush(); rest != "" {
+		writeSSE(w, "delta", map[string]string{"content": rest})
+	}
+	response := &OptimizeReportResponse{
+		OptimizedContent: session.Restore(optimizedContent),
+		Redactions:       session.Audit(),
+		SummaryRounds:    rounds,
+	}
+	recordOptimized(&req, response)
+	writeSSE(w, "done", response)
+	flusher.Flush()
+}
+
+// recordOptimized 请求带有 reportId 时把优化结果保存为报告的修订，保存失败不影响返回结果
+func recordOptimized(req *OptimizeReportRequest, response *OptimizeReportResponse) {
+	history := serverConfig.History()
+	if req.ReportID == 0 || history == nil {
+		return
+	}
+
+	provider := serverConfig.AI.providerName(req.Provider)
+	if response.Offline {
+		provider = "offline"
+	}
+	prompt := req.Prompt
+	if prompt == "" {
+		prompt = DefaultPromptName
+	}
+
+	revision, err := history.AddRevision(req.ReportID, &HistoryRevision{
+		Kind:     RevisionOptimized,
+		Content:  response.OptimizedContent,
+		Provider: provider,
+		Prompt:   prompt,
+	})
+	if err != nil {
+		log.Printf("Failed to save optimized revision of report %d: %v", req.ReportID, err)
+		return
+	}
+	response.Revision = revision.Number
+}
+
+// writeSSE 写入一个 Server-Sent Event，data 编码为单行JSON
+func writeSSE(w io.Writer, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
+
+// okrDraftHandler 根据一个季度的提交生成OKR草稿
+func okrDraftHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var req OKRDraftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid JSON format"})
+		return
+	}
+
+	paths := req.RepoPaths
+	if req.RepoPath != "" {
+		paths = append([]string{req.RepoPath}, paths...)
+	}
+	if len(paths) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Repository path is required"})
+		return
+	}
+
+	repoPaths, err := DiscoverRepos(paths)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Invalid repository path: %v", err)})
+		return
+	}
+
+	targetDate, err := parseDate(req.Date)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Invalid date format: %v", err)})
+		return
+	}
+
+	generator := NewMultiRepoReportGenerator(repoPaths, req.Author, serverConfig)
+	generator.SetTeamMode(req.Team)
+	rosterFile, err := serverConfig.RequestFile(req.RosterFile, serverConfig.RosterFile)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Invalid roster file: %v", err)})
+		return
+	}
+	if rosterFile != "" {
+		roster, err := LoadRoster(rosterFile)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Invalid roster file: %v", err)})
+			return
+		}
+		generator.SetRoster(roster)
+	}
+
+	report, err := generator.GenerateQuarterlyReport(targetDate)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Failed to generate report: %v", err)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), serverConfig.AI.Deadline())
+	defer cancel()
+	result, err := DraftOKR(ctx, serverConfig, report, req.Provider, req.Attempts)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Failed to draft OKR: %v", err)})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(OKRDraftResponse{
+		Draft:      result.Draft,
+		Markdown:   result.Draft.Markdown(serverConfig.ReportSettings.ShortHashLength),
+		Attempts:   result.Attempts,
+		Redactions: result.Redactions,
+	})
+}
+
+// promptsHandler 列出可用的提示词模板
+func promptsHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	library, err := serverConfig.PromptLibrary()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Failed to load prompts: %v", err)})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"prompts": library.List()})
+}
+
+func templatesHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"templates": BuiltinTemplates()})
+}
+
+// deliverHandler 把报告推送到聊天渠道或邮件，任一渠道失败时返回 502，响应中包含每个渠道的结果
+func deliverHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var req DeliverRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid JSON format"})
+		return
+	}
+
+	if req.Content == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Content is required"})
+		return
+	}
+	if len(req.Channels) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "At least one channel is required"})
+		return
+	}
+	for _, name := range req.Channels {
+		if _, ok := serverConfig.Delivery.Channels[name]; !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Unknown channel: %s", name)})
+			return
+		}
+	}
+
+	title := req.Title
+	if title == "" {
+		title = markdownTitle(req.Content)
+	}
+
+	results := serverConfig.Delivery.Deliver(r.Context(), req.Channels, &DeliveryMessage{
+		Title:    title,
+		Markdown: req.Content,
+		HTML:     req.HTML,
+		Author:   req.Author,
+		Period:   req.Period,
+		Type:     req.Type,
+		Date:     req.Date,
+	})
+	status := http.StatusOK
+	for _, result := range results {
+		if result.Error != "" {
+			status = http.StatusBadGateway
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(DeliverResponse{Results: results})
+}
+
+// channelsHandler 列出配置的推送渠道，不返回地址和密钥
+func channelsHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	channels := []map[string]string{}
+	for _, name := range serverConfig.Delivery.Names() {
+		channels = append(channels, map[string]string{"name": name, "type": serverConfig.Delivery.ChannelType(name)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"channels": channels})
+}
+
+// serverScheduler 服务器模式下的定时任务调度器，由 startServer 创建
+var serverScheduler *Scheduler
+
+func schedulesHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method == "GET" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{"schedules": serverScheduler.List()})
+		return
+	}
+
+	var schedule Schedule
+	if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid JSON format"})
+		return
+	}
+
+	status, err := serverScheduler.Create(&schedule)
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(status)
+}
+
+func scheduleHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+
+	switch r.Method {
+	case "GET":
+		status, err := serverScheduler.Get(id)
+		if err != nil {
+			writeScheduleError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(status)
+
+	case "PUT":
+		var schedule Schedule
+		if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid JSON format"})
+			return
+		}
+		if schedule.ID != "" && schedule.ID != id {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Schedule id in the body does not match the URL"})
+			return
+		}
+		status, err := serverScheduler.Update(id, &schedule)
+		if err != nil {
+			writeScheduleError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(status)
+
+	case "DELETE":
+		if err := serverScheduler.Delete(id); err != nil {
+			writeScheduleError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// runScheduleHandler 立即运行任务，等待生成和推送完成后返回运行记录
+func runScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	run, err := serverScheduler.RunNow(mux.Vars(r)["id"])
+	if err != nil {
+		writeScheduleError(w, err)
+		return
 	}
 
-	optimizedContent, ok := message["content"].(string)
+	status := http.StatusOK
+	if run.Status != "success" {
+		status = http.StatusBadGateway
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(run)
+}
+
+// writeScheduleError 按错误类型返回对应的状态码
+func writeScheduleError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrScheduleNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrScheduleReadOnly):
+		status = http.StatusForbidden
+	case errors.Is(err, ErrScheduleExists), errors.Is(err, ErrScheduleRunning):
+		status = http.StatusConflict
+	case errors.Is(err, ErrScheduleInvalid):
+		status = http.StatusBadRequest
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()})
+}
+
+type ReportRevisionRequest struct {
+	Content string `json:"content"`
+}
+
+type ReportDiffResponse struct {
+	From     int    `json:"from"`
+	To       int    `json:"to"`
+	FromKind string `json:"fromKind"`
+	ToKind   string `json:"toKind"`
+	Diff     string `json:"diff"` // unified diff 格式，内容相同时为空
+}
+
+// reportsHandler 按作者、类型和时间范围列出报告历史
+func reportsHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	history := historyOrError(w)
+	if history == nil {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := HistoryFilter{Author: query.Get("author"), Type: query.Get("type"), Limit: 50}
+	var err error
+	if v := query.Get("since"); v != "" {
+		if filter.Since, err = time.ParseInLocation("2006-01-02", v, time.Local); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Invalid since date: %v", err)})
+			return
+		}
+	}
+	if v := query.Get("until"); v != "" {
+		if filter.Until, err = time.ParseInLocation("2006-01-02", v, time.Local); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Invalid until date: %v", err)})
+			return
+		}
+		filter.Until = filter.Until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid limit"})
+			return
+		}
+	}
+
+	entries, err := history.List(filter)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Failed to list reports: %v", err)})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"reports": entries})
+}
+
+// reportHandler 返回一份报告的结构化数据和所有修订
+func reportHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	record := historyRecordOrError(w, r)
+	if record == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(record)
+}
+
+// reportDiffHandler 比较报告的两个修订，默认比较第一个和最新的修订
+func reportDiffHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var from, to int
+	query := r.URL.Query()
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"from", &from}, {"to", &to}} {
+		v := query.Get(param.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Invalid %s revision: %s", param.name, v)})
+			return
+		}
+		*param.value = n
+	}
+
+	record := historyRecordOrError(w, r)
+	if record == nil {
+		return
+	}
+
+	diff, a, b, err := record.Diff(from, to)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ReportDiffResponse{From: a.Number, To: b.Number, FromKind: a.Kind, ToKind: b.Kind, Diff: diff})
+}
+
+// reportRevisionsHandler 保存手动编辑后的报告内容
+func reportRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	history := historyOrError(w)
+	if history == nil {
+		return
+	}
+	id, ok := reportIDOrError(w, r)
 	if !ok {
-		return "", fmt.Errorf("invalid response format: no content found")
+		return
 	}
 
-	return strings.TrimSpace(optimizedContent), nil
+	var req ReportRevisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid JSON format"})
+		return
+	}
+	if req.Content == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Content is required"})
+		return
+	}
+
+	revision, err := history.AddRevision(id, &HistoryRevision{Kind: RevisionEdited, Content: req.Content})
+	if errors.Is(err, ErrReportNotFound) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Report not found: %d", id)})
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Failed to save revision: %v", err)})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(revision)
 }
 
-func getAIAPIURL() string {
-	// 优先使用环境变量，如果没有则使用默认的免费API
-	if url := os.Getenv("AI_API_URL"); url != "" {
-		return url
+// historyOrError 返回报告历史，未配置时写入 404 并返回 nil
+func historyOrError(w http.ResponseWriter) *HistoryStore {
+	history := serverConfig.History()
+	if history == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Report history is disabled. Set history_file in the config to enable it"})
 	}
-	
-	// 默认使用DeepSeek API (提供免费额度)
-	return "https://api.deepseek.com/v1/chat/completions"
+	return history
 }
 
-func getAIAPIKey() string {
-	// 优先使用环境变量
-	if key := os.Getenv("AI_API_KEY"); key != "" {
-		return key
+// reportIDOrError 解析路径中的报告编号，无效时写入 400
+func reportIDOrError(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Invalid report id: %s", mux.Vars(r)["id"])})
+		return 0, false
 	}
-	
-	// 如果没有设置环境变量，返回空字符串
-	// 用户需要自己设置API密钥
-	return ""
+	return id, true
+}
+
+// historyRecordOrError 读取路径中指定的报告，失败时写入错误响应并返回 nil
+func historyRecordOrError(w http.ResponseWriter, r *http.Request) *HistoryRecord {
+	history := historyOrError(w)
+	if history == nil {
+		return nil
+	}
+	id, ok := reportIDOrError(w, r)
+	if !ok {
+		return nil
+	}
+
+	record, err := history.Get(id)
+	if errors.Is(err, ErrReportNotFound) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Report not found: %d", id)})
+		return nil
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Failed to read report: %v", err)})
+		return nil
+	}
+	return record
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -308,12 +1281,36 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
-func startServer() {
+func startServer(config *Config) {
+	serverConfig = config
+
+	scheduler, err := NewScheduler(config)
+	if err != nil {
+		log.Fatalf("Failed to load schedules: %v", err)
+	}
+	serverScheduler = scheduler
+	if config.Scheduler.Enabled {
+		serverScheduler.Start(context.Background())
+	}
+
 	r := mux.NewRouter()
 
 	// API routes
 	r.HandleFunc("/api/generate-report", generateReportHandler).Methods("POST", "OPTIONS")
 	r.HandleFunc("/api/optimize-report", optimizeReportHandler).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/optimize-report/stream", optimizeReportStreamHandler).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/okr-draft", okrDraftHandler).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/prompts", promptsHandler).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/templates", templatesHandler).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/channels", channelsHandler).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/deliver", deliverHandler).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/schedules", schedulesHandler).Methods("GET", "POST", "OPTIONS")
+	r.HandleFunc("/api/schedules/{id}", scheduleHandler).Methods("GET", "PUT", "DELETE", "OPTIONS")
+	r.HandleFunc("/api/schedules/{id}/run", runScheduleHandler).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/reports", reportsHandler).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/reports/{id}", reportHandler).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/reports/{id}/diff", reportDiffHandler).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/reports/{id}/revisions", reportRevisionsHandler).Methods("POST", "OPTIONS")
 	r.HandleFunc("/api/health", healthHandler).Methods("GET", "OPTIONS")
 
 	// Setup CORS
@@ -321,6 +1318,7 @@ func startServer() {
 		AllowedOrigins: []string{"*"},
 		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders: []string{"*"},
+		ExposedHeaders: []string{"X-Report-Id"},
 	})
 
 	handler := c.Handler(r)
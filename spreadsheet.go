@@ -0,0 +1,486 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 表格导出格式
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// utf8BOM Excel 依据 BOM 识别 UTF-8 编码的 CSV，否则中文会显示为乱码
+const utf8BOM = "\ufeff"
+
+// spreadsheetTimeFormat 表格中时间的格式，Excel 和脚本都能直接识别
+const spreadsheetTimeFormat = "2006-01-02 15:04:05"
+
+// Table 导出的一张表，单元格为 string、int 或 float64
+type Table struct {
+	Name   string // CSV 文件名（不含扩展名）
+	Sheet  string // XLSX 工作表名
+	Header []string
+	Rows   [][]interface{}
+}
+
+// ReportTables 把报告整理为概览、提交、文件、分类和作者五张表
+func ReportTables(report *Report) []*Table {
+	return []*Table{
+		summaryTable(report),
+		commitsTable(report),
+		filesTable(report),
+		categoriesTable(report),
+		authorsTable(report),
+	}
+}
+
+// summaryTable 报告概览
+func summaryTable(report *Report) *Table {
+	s := report.Summary
+	if s == nil {
+		s = &ReportSummary{}
+	}
+	table := &Table{Name: "summary", Sheet: "概览", Header: []string{"指标", "数值"}}
+	table.Rows = [][]interface{}{
+		{"报告类型", reportTypeName(report.Type)},
+		{"时间范围", report.Period},
+		{"作者", report.Author},
+		{"提交次数", s.TotalCommits},
+		{"修改文件", s.TotalFiles},
+		{"新增行数", s.TotalAdditions},
+		{"删除行数", s.TotalDeletions},
+		{"仓库数", len(report.Repos)},
+		{"生成时间", report.GeneratedAt.Format(spreadsheetTimeFormat)},
+	}
+	if report.Team {
+		table.Rows = append(table.Rows, []interface{}{"参与成员", len(report.Members)})
+	}
+	return table
+}
+
+// commitsTable 提交明细
+func commitsTable(report *Report) *Table {
+	table := &Table{
+		Name:   "commits",
+		Sheet:  "提交",
+		Header: []string{"哈希", "仓库", "作者", "邮箱", "时间", "分类", "类型", "模块", "标题", "破坏性变更", "新增", "删除", "文件数"},
+	}
+
+	categories := make(map[*GitCommit][]string)
+	for _, name := range sortedCategoryNames(report.Categories) {
+		for _, commit := range report.Categories[name] {
+			categories[commit] = append(categories[commit], name)
+		}
+	}
+
+	for _, c := range report.Commits {
+		breaking := ""
+		if c.Breaking {
+			breaking = "是"
+		}
+		table.Rows = append(table.Rows, []interface{}{
+			c.Hash, c.Repo, c.Author, c.Email, c.Date.Format(spreadsheetTimeFormat),
+			strings.Join(categories[c], "、"), c.Type, c.Scope, c.Message, breaking,
+			c.Additions, c.Deletions, len(c.Files),
+		})
+	}
+	return table
+}
+
+// fileStat 单个文件的汇总
+type fileStat struct {
+	repo, path                    string
+	commits, additions, deletions int
+}
+
+// filesTable 按文件汇总的修改次数和行数，修改次数多的在前
+func filesTable(report *Report) *Table {
+	table := &Table{Name: "files", Sheet: "文件", Header: []string{"仓库", "文件", "提交次数", "新增", "删除"}}
+
+	stats := make(map[string]*fileStat)
+	add := func(commit *GitCommit, path string, additions, deletions int) {
+		key := commit.Repo + "\x00" + path
+		if stats[key] == nil {
+			stats[key] = &fileStat{repo: commit.Repo, path: path}
+		}
+		stats[key].commits++
+		stats[key].additions += additions
+		stats[key].deletions += deletions
+	}
+	for _, commit := range report.Commits {
+		if len(commit.Changes) > 0 {
+			for _, change := range commit.Changes {
+				add(commit, change.Path, change.Additions, change.Deletions)
+			}
+			continue
+		}
+		for _, file := range commit.Files {
+			add(commit, file, 0, 0)
+		}
+	}
+
+	list := make([]*fileStat, 0, len(stats))
+	for _, stat := range stats {
+		list = append(list, stat)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].commits != list[j].commits {
+			return list[i].commits > list[j].commits
+		}
+		if list[i].repo != list[j].repo {
+			return list[i].repo < list[j].repo
+		}
+		return list[i].path < list[j].path
+	})
+
+	for _, stat := range list {
+		table.Rows = append(table.Rows, []interface{}{stat.repo, stat.path, stat.commits, stat.additions, stat.deletions})
+	}
+	return table
+}
+
+// categoriesTable 按分类汇总
+func categoriesTable(report *Report) *Table {
+	table := &Table{Name: "categories", Sheet: "分类", Header: []string{"分类", "提交次数", "新增", "删除"}}
+	for _, name := range sortedCategoryNames(report.Categories) {
+		additions, deletions := 0, 0
+		for _, commit := range report.Categories[name] {
+			additions += commit.Additions
+			deletions += commit.Deletions
+		}
+		table.Rows = append(table.Rows, []interface{}{name, len(report.Categories[name]), additions, deletions})
+	}
+	return table
+}
+
+// authorsTable 按作者汇总，团队报告使用花名册归并后的成员
+func authorsTable(report *Report) *Table {
+	table := &Table{Name: "authors", Sheet: "作者", Header: []string{"作者", "提交次数", "修改文件", "新增", "删除"}}
+
+	if report.Team {
+		for _, member := range report.Members {
+			s := member.Summary
+			table.Rows = append(table.Rows, []interface{}{member.Name, s.TotalCommits, s.TotalFiles, s.TotalAdditions, s.TotalDeletions})
+		}
+		return table
+	}
+
+	type authorStat struct {
+		name                          string
+		commits, additions, deletions int
+		files                         map[string]bool
+	}
+	stats := make(map[string]*authorStat)
+	var order []string
+	for _, commit := range report.Commits {
+		stat := stats[commit.Author]
+		if stat == nil {
+			stat = &authorStat{name: commit.Author, files: make(map[string]bool)}
+			stats[commit.Author] = stat
+			order = append(order, commit.Author)
+		}
+		stat.commits++
+		stat.additions += commit.Additions
+		stat.deletions += commit.Deletions
+		for _, file := range commit.Files {
+			stat.files[commit.Repo+"\x00"+file] = true
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return stats[order[i]].commits > stats[order[j]].commits })
+
+	for _, name := range order {
+		stat := stats[name]
+		table.Rows = append(table.Rows, []interface{}{stat.name, stat.commits, len(stat.files), stat.additions, stat.deletions})
+	}
+	return table
+}
+
+// ExportSpreadsheet 导出表格：csv 为包含各表 CSV 文件的 zip，xlsx 为每张表一个工作表的 Excel 文件
+// bom 为 true 时 CSV 以 UTF-8 BOM 开头并使用 CRLF 换行
+func ExportSpreadsheet(report *Report, format string, bom bool) ([]byte, error) {
+	tables := ReportTables(report)
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case FormatCSV:
+		err = WriteCSVZip(&buf, tables, bom)
+	case FormatXLSX:
+		err = WriteXLSX(&buf, tables)
+	default:
+		return nil, fmt.Errorf("不支持的表格格式: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteCSV 以 CSV 格式写入一张表
+func WriteCSV(w io.Writer, table *Table, bom bool) error {
+	if bom {
+		if _, err := io.WriteString(w, utf8BOM); err != nil {
+			return err
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	writer.UseCRLF = bom
+	if err := writer.Write(table.Header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(table.Header))
+	for _, row := range table.Rows {
+		for i, cell := range row {
+			record[i] = csvCell(cell)
+		}
+		if err := writer.Write(record[:len(row)]); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvCell 格式化 CSV 单元格，以 = + - @ 开头的文本加前缀 '，防止被表格软件当作公式执行
+// 是否写入 BOM 都会转义，没有 BOM 的文件同样可能被 Excel 或其他表格软件打开
+func csvCell(cell interface{}) string {
+	switch v := cell.(type) {
+	case string:
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// WriteCSVZip 把每张表写为一个 CSV 文件并打包为 zip
+func WriteCSVZip(w io.Writer, tables []*Table, bom bool) error {
+	archive := zip.NewWriter(w)
+	for _, table := range tables {
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: table.Name + ".csv", Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return fmt.Errorf("写入 %s.csv 失败: %v", table.Name, err)
+		}
+		if err := WriteCSV(file, table, bom); err != nil {
+			return fmt.Errorf("写入 %s.csv 失败: %v", table.Name, err)
+		}
+	}
+	return archive.Close()
+}
+
+// XLSX 文件的固定部分
+const (
+	xlsxContentTypesHead = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	// 样式 0 为默认，样式 1 为加粗的表头
+	xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs></styleSheet>`
+	xlsxMaxColumnWidth = 60
+)
+
+// WriteXLSX 把每张表写为一个工作表，生成 Office Open XML 格式的 Excel 文件
+// 文本使用内联字符串，表头加粗并冻结，数字保持数值类型以便求和和排序
+func WriteXLSX(w io.Writer, tables []*Table) error {
+	archive := zip.NewWriter(w)
+	files := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes(len(tables))},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook(tables)},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels(len(tables))},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for i, table := range tables {
+		files = append(files, struct{ name, content string }{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), xlsxSheet(table)})
+	}
+
+	for _, f := range files {
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return fmt.Errorf("写入 %s 失败: %v", f.name, err)
+		}
+		if _, err := io.WriteString(file, f.content); err != nil {
+			return fmt.Errorf("写入 %s 失败: %v", f.name, err)
+		}
+	}
+	return archive.Close()
+}
+
+// xlsxContentTypes 生成 [Content_Types].xml
+func xlsxContentTypes(sheets int) string {
+	var b strings.Builder
+	b.WriteString(xlsxContentTypesHead)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	b.WriteString(`</Types>`)
+	return b.String()
+}
+
+// xlsxWorkbook 生成 xl/workbook.xml
+func xlsxWorkbook(tables []*Table) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, table := range tables {
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(xlsxSheetName(table.Sheet)), i+1, i+1)
+	}
+	b.WriteString(`</sheets></workbook>`)
+	return b.String()
+}
+
+// xlsxWorkbookRels 生成 xl/_rels/workbook.xml.rels，样式的关系 ID 排在工作表之后
+func xlsxWorkbookRels(sheets int) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, sheets+1)
+	b.WriteString(`</Relationships>`)
+	return b.String()
+}
+
+// xlsxSheet 生成工作表
+func xlsxSheet(table *Table) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+
+	// 按内容估算列宽，中日韩文字按两个字符计
+	b.WriteString(`<cols>`)
+	for i, width := range xlsxColumnWidths(table) {
+		fmt.Fprintf(&b, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, width)
+	}
+	b.WriteString(`</cols><sheetData>`)
+
+	header := make([]interface{}, len(table.Header))
+	for i, h := range table.Header {
+		header[i] = h
+	}
+	xlsxRow(&b, 1, header, 1)
+	for i, row := range table.Rows {
+		xlsxRow(&b, i+2, row, 0)
+	}
+	b.WriteString(`</sheetData>`)
+
+	if len(table.Header) > 0 {
+		fmt.Fprintf(&b, `<autoFilter ref="A1:%s%d"/>`, xlsxColumn(len(table.Header)-1), len(table.Rows)+1)
+	}
+	b.WriteString(`</worksheet>`)
+	return b.String()
+}
+
+// xlsxRow 写入一行，style 为 styles.xml 中 cellXfs 的序号
+func xlsxRow(b *strings.Builder, index int, cells []interface{}, style int) {
+	fmt.Fprintf(b, `<row r="%d">`, index)
+	for i, cell := range cells {
+		ref := fmt.Sprintf("%s%d", xlsxColumn(i), index)
+		switch v := cell.(type) {
+		case int:
+			fmt.Fprintf(b, `<c r="%s" s="%d"><v>%d</v></c>`, ref, style, v)
+		case float64:
+			fmt.Fprintf(b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, style, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			text := fmt.Sprint(v)
+			if text == "" {
+				continue
+			}
+			fmt.Fprintf(b, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, style, xmlEscape(text))
+		}
+	}
+	b.WriteString(`</row>`)
+}
+
+// xlsxColumn 把从 0 开始的列序号转换为 A、B…Z、AA 形式的列名
+func xlsxColumn(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
+
+// xlsxColumnWidths 估算各列宽度
+func xlsxColumnWidths(table *Table) []int {
+	widths := make([]int, len(table.Header))
+	measure := func(i int, text string) {
+		width := 2
+		for _, r := range text {
+			if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+				width += 2
+			} else {
+				width++
+			}
+		}
+		if width > xlsxMaxColumnWidth {
+			width = xlsxMaxColumnWidth
+		}
+		if width > widths[i] {
+			widths[i] = width
+		}
+	}
+
+	for i, h := range table.Header {
+		measure(i, h)
+	}
+	for _, row := range table.Rows {
+		for i, cell := range row {
+			if i < len(widths) {
+				measure(i, fmt.Sprint(cell))
+			}
+		}
+	}
+	return widths
+}
+
+// xlsxSheetName 工作表名最长 31 个字符，且不能包含 []:*?/\
+func xlsxSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	return name
+}
+
+// xmlEscape 转义 XML 文本，并去掉 XML 中不允许出现的控制字符
+func xmlEscape(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' || r >= 0x20 && r != 0xFFFE && r != 0xFFFF {
+			return r
+		}
+		return -1
+	}, text)
+
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
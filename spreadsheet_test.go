@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		name string
+		cell interface{}
+		want string
+	}{
+		{"等号开头", "=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"加号开头", "+1", "'+1"},
+		{"减号开头", "-2+3", "'-2+3"},
+		{"@ 开头", "@SUM(A1)", "'@SUM(A1)"},
+		{"制表符开头", "\t=1", "'\t=1"},
+		{"普通文本", "feat: a=b", "feat: a=b"},
+		{"空字符串", "", ""},
+		{"负数不转义", -5, "-5"},
+		{"浮点数", 1.5, "1.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := csvCell(tt.cell); got != tt.want {
+				t.Errorf("csvCell(%v) = %q, 期望 %q", tt.cell, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	table := &Table{
+		Name:   "commits",
+		Header: []string{"message", "additions"},
+		Rows:   [][]interface{}{{"=cmd|' /C calc'!A0", 3}},
+	}
+
+	tests := []struct {
+		name string
+		bom  bool
+		want string
+	}{
+		{"不写入 BOM 时仍转义公式", false, "message,additions\n'=cmd|' /C calc'!A0,3\n"},
+		{"BOM 只控制文件头和换行", true, utf8BOM + "message,additions\r\n'=cmd|' /C calc'!A0,3\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteCSV(&buf, table, tt.bom); err != nil {
+				t.Fatalf("WriteCSV 返回错误: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("输出 = %q, 期望 %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
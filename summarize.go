@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// minChunkTokens 每段内容的最小token数，预算再小也按此切分，避免切得过碎
+const minChunkTokens = 500
+
+// summarizeConcurrency 分段整理时同时进行的请求数
+const summarizeConcurrency = 3
+
+// summarizeMaxRounds 分段整理的最大轮数，每轮把上一轮的结果合并后再次整理
+const summarizeMaxRounds = 3
+
+// condensedNotice 内容经过分段整理时附加在系统提示词之后
+const condensedNotice = "报告内容较长，已分段整理后合并，请在此基础上完成，不要遗漏其中的工作事项。"
+
+// chunkPrompt 分段整理时使用的系统提示词
+const chunkPrompt = `你在协助整理一份较长的Git提交报告，报告被拆分为 %d 段，下面是第 %d 段。
+请把这一段压缩为精简的 Markdown：
+- 保留原有的标题层级，以及每一项工作、作者、提交哈希和统计数字
+- 合并重复或琐碎的提交，但不要省略任何工作事项
+- 不要添加开场白、总结或评论
+` + redactionNotice
+
+// EstimateTokens 估算文本的token数
+// 中日韩文字按每字一个token，其他字符按每3个字符一个token，略高于常见分词器的实际值
+func EstimateTokens(text string) int {
+	units := 0
+	for _, r := range text {
+		units += runeTokenUnits(r)
+	}
+	return (units + 9) / 10
+}
+
+// runeTokenUnits 单个字符的token数，以十分之一token为单位
+func runeTokenUnits(r rune) int {
+	if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+		return 10
+	}
+	return 3
+}
+
+// ChunkLimit 返回每段内容的token上限，systemPrompt 为每次请求都会发送的系统提示词
+func (b TokenBudget) ChunkLimit(systemPrompt string) int {
+	limit := b.InputTokens() - EstimateTokens(systemPrompt)
+	if b.ChunkTokens > 0 && b.ChunkTokens < limit {
+		limit = b.ChunkTokens
+	}
+	if limit < minChunkTokens {
+		limit = minChunkTokens
+	}
+	return limit
+}
+
+// Condense 内容超出预算时分段整理（map），再把各段结果合并（reduce），返回可以在一次请求中发送的内容
+// rounds 为整理的轮数，未超出预算时为 0；onProgress 在每段完成时调用，可以为 nil
+func Condense(ctx context.Context, provider Provider, budget TokenBudget, systemPrompt, content string, onProgress func(done, total int)) (string, int, error) {
+	limit := budget.ChunkLimit(systemPrompt)
+
+	for round := 0; ; round++ {
+		if EstimateTokens(content) <= limit {
+			return content, round, nil
+		}
+		if round == summarizeMaxRounds {
+			return "", round, fmt.Errorf("report still exceeds %d tokens after %d rounds of chunked summarization; raise context_tokens or max_tokens", limit, round)
+		}
+
+		chunks := SplitMarkdown(content, budget.ChunkLimit(chunkPrompt))
+		if len(chunks) < 2 {
+			return "", round, fmt.Errorf("report exceeds %d tokens and cannot be split", limit)
+		}
+
+		summaries, err := summarizeChunks(ctx, provider, chunks, onProgress)
+		if err != nil {
+			return "", round, err
+		}
+
+		condensed := strings.Join(summaries, "\n\n")
+		if EstimateTokens(condensed) >= EstimateTokens(content) {
+			return "", round, fmt.Errorf("chunked summarization did not shrink the report")
+		}
+		content = condensed
+	}
+}
+
+// summarizeChunks 并发整理各段内容，结果按原顺序返回
+func summarizeChunks(ctx context.Context, provider Provider, chunks []string, onProgress func(done, total int)) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		done     int
+		firstErr error
+	)
+	summaries := make([]string, len(chunks))
+	sem := make(chan struct{}, summarizeConcurrency)
+
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			summary, err := provider.Complete(ctx, &CompletionRequest{
+				Messages: []ChatMessage{
+					{Role: "system", Content: fmt.Sprintf(chunkPrompt, len(chunks), i+1)},
+					{Role: "user", Content: chunk},
+				},
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("chunk %d/%d: %v", i+1, len(chunks), err)
+					cancel()
+				}
+				return
+			}
+			summaries[i] = summary
+			done++
+			if onProgress != nil && firstErr == nil {
+				onProgress(done, len(chunks))
+			}
+		}(i, chunk)
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return summaries, nil
+}
+
+// SplitMarkdown 把 Markdown 切分为不超过 limit 个token的片段
+// 优先在最高一级的标题处切分（报告中的分类、仓库或成员），标题下的内容仍然过长时逐级细分，最后按行切分
+func SplitMarkdown(text string, limit int) []string {
+	if EstimateTokens(text) <= limit {
+		return []string{text}
+	}
+
+	for level := 1; level <= 6; level++ {
+		sections := splitAtHeading(text, level)
+		if len(sections) < 2 {
+			continue
+		}
+
+		var pieces []string
+		for _, section := range sections {
+			pieces = append(pieces, splitSection(section, limit)...)
+		}
+		return packChunks(pieces, limit)
+	}
+
+	return packChunks(splitLines(text, limit), limit)
+}
+
+// splitSection 切分一个标题下的内容，后续片段重复标题以保留上下文
+func splitSection(section string, limit int) []string {
+	heading, _, _ := strings.Cut(section, "\n")
+	if headingLevel(heading) == 0 {
+		return SplitMarkdown(section, limit)
+	}
+
+	prefix := heading + "（续）\n"
+	parts := SplitMarkdown(section, limit-EstimateTokens(prefix))
+	for i := 1; i < len(parts); i++ {
+		parts[i] = prefix + parts[i]
+	}
+	return parts
+}
+
+// splitAtHeading 在指定级别的标题处切分，代码块中的 # 不视为标题
+func splitAtHeading(text string, level int) []string {
+	var (
+		sections []string
+		current  []string
+		inCode   bool
+	)
+
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+		}
+		if !inCode && headingLevel(line) == level && len(current) > 0 {
+			if section := strings.Join(current, "\n"); strings.TrimSpace(section) != "" {
+				sections = append(sections, section)
+			}
+			current = nil
+		}
+		current = append(current, line)
+	}
+	if section := strings.Join(current, "\n"); strings.TrimSpace(section) != "" {
+		sections = append(sections, section)
+	}
+
+	return sections
+}
+
+// headingLevel 返回 Markdown 标题的级别，不是标题时返回 0
+func headingLevel(line string) int {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(line) && line[level] != ' ') {
+		return 0
+	}
+	return level
+}
+
+// splitLines 按行切分，单行超出 limit 时按字符切分
+func splitLines(text string, limit int) []string {
+	var pieces []string
+	for _, line := range strings.Split(text, "\n") {
+		if EstimateTokens(line) <= limit {
+			pieces = append(pieces, line)
+			continue
+		}
+
+		units, start := 0, 0
+		for i, r := range line {
+			u := runeTokenUnits(r)
+			if units+u > limit*10 {
+				pieces = append(pieces, line[start:i])
+				units, start = 0, i
+			}
+			units += u
+		}
+		pieces = append(pieces, line[start:])
+	}
+	return pieces
+}
+
+// packChunks 把相邻的片段合并为不超过 limit 的段
+func packChunks(pieces []string, limit int) []string {
+	var (
+		chunks  []string
+		current strings.Builder
+		tokens  int
+	)
+
+	for _, piece := range pieces {
+		n := EstimateTokens(piece) + 1
+		if current.Len() > 0 && tokens+n > limit {
+			chunks = append(chunks, current.String())
+			current.Reset()
+			tokens = 0
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n")
+		}
+		current.WriteString(piece)
+		tokens += n
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+
+	return chunks
+}
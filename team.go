@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Roster 团队花名册，把多个Git用户名/邮箱映射到同一个成员
+type Roster struct {
+	Members []*RosterMember `json:"members"`
+
+	aliases map[string]string // 小写别名 -> 成员名
+}
+
+// RosterMember 团队成员
+type RosterMember struct {
+	Name    string   `json:"name"`    // 报告中显示的名称
+	Aliases []string `json:"aliases"` // Git用户名或邮箱
+}
+
+// MemberReport 团队报告中单个成员的部分
+type MemberReport struct {
+	Name       string                  // 成员名称
+	Commits    []*GitCommit            // 成员的提交
+	Summary    *ReportSummary          // 成员统计
+	Categories map[string][]*GitCommit // 成员按类别分组的提交
+}
+
+// TeamRanking 团队排行榜
+type TeamRanking struct {
+	Title   string       // 排行榜标题
+	Entries []*RankEntry // 按数值从高到低排序
+}
+
+// RankEntry 排行榜条目
+type RankEntry struct {
+	Rank  int
+	Name  string
+	Value int
+}
+
+// LoadRoster 从JSON文件加载花名册
+func LoadRoster(filename string) (*Roster, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("读取花名册失败: %v", err)
+	}
+
+	var roster Roster
+	if err := json.Unmarshal(data, &roster); err != nil {
+		return nil, fmt.Errorf("解析花名册失败: %v", err)
+	}
+
+	roster.aliases = make(map[string]string)
+	for i, member := range roster.Members {
+		if member.Name == "" {
+			return nil, fmt.Errorf("花名册第%d个成员缺少name", i+1)
+		}
+		for _, alias := range append([]string{member.Name}, member.Aliases...) {
+			key := strings.ToLower(strings.TrimSpace(alias))
+			if key == "" {
+				continue
+			}
+			if existing, ok := roster.aliases[key]; ok && existing != member.Name {
+				return nil, fmt.Errorf("花名册中 %s 同时属于 %s 和 %s", alias, existing, member.Name)
+			}
+			roster.aliases[key] = member.Name
+		}
+	}
+
+	return &roster, nil
+}
+
+// Resolve 根据用户名或邮箱查找成员名，未登记时返回用户名本身
+func (r *Roster) Resolve(name, email string) string {
+	if r != nil {
+		if member, ok := r.aliases[strings.ToLower(strings.TrimSpace(email))]; ok && email != "" {
+			return member
+		}
+		if member, ok := r.aliases[strings.ToLower(strings.TrimSpace(name))]; ok {
+			return member
+		}
+	}
+	return name
+}
+
+// selectCommits 按作者过滤提交，团队模式或未指定作者时保留全部
+// 作者或任一共同作者匹配即保留；使用花名册时按成员匹配，否则与 git log --author 一样按正则匹配 "Name <email>"
+func (rg *ReportGenerator) selectCommits(commits []*GitCommit) []*GitCommit {
+	if rg.team || rg.author == "" {
+		return commits
+	}
+
+	if rg.roster != nil {
+		return rg.filterByMember(commits, rg.roster.Resolve(rg.author, rg.author))
+	}
+
+	match := func(identity Identity) bool {
+		return strings.Contains(identity.Name+" <"+identity.Email+">", rg.author)
+	}
+	if re, err := regexp.Compile(rg.author); err == nil {
+		match = func(identity Identity) bool {
+			return re.MatchString(identity.Name + " <" + identity.Email + ">")
+		}
+	}
+
+	var filtered []*GitCommit
+	for _, commit := range commits {
+		for _, identity := range commit.Identities() {
+			if match(identity) {
+				filtered = append(filtered, commit)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// commitMembers 返回提交归属的成员，结对编程的提交归属于作者和所有共同作者
+func (rg *ReportGenerator) commitMembers(commit *GitCommit) []string {
+	var members []string
+	seen := make(map[string]bool)
+	for _, identity := range commit.Identities() {
+		name := rg.roster.Resolve(identity.Name, identity.Email)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			members = append(members, name)
+		}
+	}
+	return members
+}
+
+// filterByMember 只保留归属于指定成员的提交
+func (rg *ReportGenerator) filterByMember(commits []*GitCommit, member string) []*GitCommit {
+	var filtered []*GitCommit
+	for _, commit := range commits {
+		for _, name := range rg.commitMembers(commit) {
+			if name == member {
+				filtered = append(filtered, commit)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// buildMemberReports 按成员分组提交并生成各成员的统计
+func (rg *ReportGenerator) buildMemberReports(commits []*GitCommit, reportType string, report *Report) []*MemberReport {
+	grouped := make(map[string][]*GitCommit)
+	for _, commit := range commits {
+		for _, name := range rg.commitMembers(commit) {
+			grouped[name] = append(grouped[name], commit)
+		}
+	}
+
+	var members []*MemberReport
+	for name, memberCommits := range grouped {
+		members = append(members, &MemberReport{
+			Name:       name,
+			Commits:    memberCommits,
+			Summary:    rg.generateSummary(memberCommits, reportType, report.Since, report.Until),
+			Categories: rg.categorizeCommits(memberCommits),
+		})
+	}
+
+	// 按提交数从高到低排列，提交数相同时按名称排列
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Summary.TotalCommits != members[j].Summary.TotalCommits {
+			return members[i].Summary.TotalCommits > members[j].Summary.TotalCommits
+		}
+		return members[i].Name < members[j].Name
+	})
+
+	return members
+}
+
+// buildTeamRankings 生成团队排行榜
+func buildTeamRankings(members []*MemberReport) []*TeamRanking {
+	metrics := []struct {
+		title string
+		value func(s *ReportSummary) int
+	}{
+		{"提交次数", func(s *ReportSummary) int { return s.TotalCommits }},
+		{"新增代码行数", func(s *ReportSummary) int { return s.TotalAdditions }},
+		{"代码变更行数", func(s *ReportSummary) int { return s.TotalAdditions + s.TotalDeletions }},
+		{"修改文件数", func(s *ReportSummary) int { return s.TotalFiles }},
+	}
+
+	var rankings []*TeamRanking
+	for _, metric := range metrics {
+		ranking := &TeamRanking{Title: metric.title}
+		for _, member := range members {
+			ranking.Entries = append(ranking.Entries, &RankEntry{
+				Name:  member.Name,
+				Value: metric.value(member.Summary),
+			})
+		}
+
+		sort.SliceStable(ranking.Entries, func(i, j int) bool {
+			return ranking.Entries[i].Value > ranking.Entries[j].Value
+		})
+
+		// 数值相同的成员并列
+		for i, entry := range ranking.Entries {
+			if i > 0 && entry.Value == ranking.Entries[i-1].Value {
+				entry.Rank = ranking.Entries[i-1].Rank
+			} else {
+				entry.Rank = i + 1
+			}
+		}
+
+		rankings = append(rankings, ranking)
+	}
+
+	return rankings
+}
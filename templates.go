@@ -0,0 +1,111 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// builtinTemplateFS 内置的报告模板
+//
+//go:embed templates/builtin/*.tmpl
+var builtinTemplateFS embed.FS
+
+// builtinTemplateDir 内置模板在 builtinTemplateFS 中的目录
+const builtinTemplateDir = "templates/builtin"
+
+// DefaultTemplateName 未指定模板时使用的内置模板
+const DefaultTemplateName = "minimal"
+
+// templateFileExt 报告模板文件扩展名
+const templateFileExt = ".tmpl"
+
+// templateDescriptionRegex 匹配模板首行的注释，如 {{/* 简洁列表 */ -}}，作为模板说明
+var templateDescriptionRegex = regexp.MustCompile(`^\{\{-?\s*/\*\s*(.*?)\s*\*/\s*-?\}\}`)
+
+// BuiltinTemplate 内置报告模板
+type BuiltinTemplate struct {
+	Name        string `json:"name"`        // 模板名，即不含扩展名的文件名
+	Description string `json:"description"` // 模板首行注释中的说明
+	Default     bool   `json:"default"`     // 是否为未指定模板时使用的默认模板
+
+	content string
+}
+
+// BuiltinTemplates 返回按名称排序的内置模板，默认模板排在最前
+func BuiltinTemplates() []*BuiltinTemplate {
+	entries, err := fs.ReadDir(builtinTemplateFS, builtinTemplateDir)
+	if err != nil {
+		return nil
+	}
+
+	var templates []*BuiltinTemplate
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != templateFileExt {
+			continue
+		}
+		data, err := fs.ReadFile(builtinTemplateFS, path.Join(builtinTemplateDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), templateFileExt)
+		tmpl := &BuiltinTemplate{Name: name, Default: name == DefaultTemplateName, content: string(data)}
+		if matches := templateDescriptionRegex.FindStringSubmatch(tmpl.content); matches != nil {
+			tmpl.Description = matches[1]
+		}
+		templates = append(templates, tmpl)
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].Default != templates[j].Default {
+			return templates[i].Default
+		}
+		return templates[i].Name < templates[j].Name
+	})
+	return templates
+}
+
+// LookupBuiltinTemplate 按名称查找内置模板
+func LookupBuiltinTemplate(name string) (*BuiltinTemplate, bool) {
+	for _, tmpl := range BuiltinTemplates() {
+		if tmpl.Name == name {
+			return tmpl, true
+		}
+	}
+	return nil, false
+}
+
+// builtinTemplateNames 返回所有内置模板名，用于错误提示
+func builtinTemplateNames() string {
+	var names []string
+	for _, tmpl := range BuiltinTemplates() {
+		names = append(names, tmpl.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// loadTemplate 读取模板内容，nameOrPath 为内置模板名或模板文件路径，为空时使用默认模板
+// 内置模板优先，与内置模板同名的文件需要写成 ./minimal 这样的路径
+func loadTemplate(nameOrPath string) (string, error) {
+	if nameOrPath == "" {
+		nameOrPath = DefaultTemplateName
+	}
+	if tmpl, ok := LookupBuiltinTemplate(nameOrPath); ok {
+		return tmpl.content, nil
+	}
+
+	content, err := os.ReadFile(nameOrPath)
+	if err != nil {
+		if os.IsNotExist(err) && !strings.ContainsAny(nameOrPath, `/\.`) {
+			return "", fmt.Errorf("未知的模板: %s，可用的内置模板: %s", nameOrPath, builtinTemplateNames())
+		}
+		return "", fmt.Errorf("读取模板文件失败: %v", err)
+	}
+	return string(content), nil
+}